@@ -0,0 +1,8 @@
+package constant
+
+const (
+	AdminRole = "admin"
+
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
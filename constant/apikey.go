@@ -0,0 +1,12 @@
+package constant
+
+const (
+	// APIKeyPrefix tells api keys from jwt tokens and makes leaked keys easy to find
+	APIKeyPrefix = "gas_"
+
+	OwnerUser           = "user"
+	OwnerServiceAccount = "service_account"
+
+	// ScopeAdmin lets an api key use the admin api, the owner must be an admin too
+	ScopeAdmin = "admin"
+)
@@ -0,0 +1,32 @@
+package constant
+
+// Audit log actions
+const (
+	AuditSignIn  = "auth.sign_in"
+	AuditSignOut = "auth.sign_out"
+	AuditUnlock  = "auth.unlock"
+	// AuditPasswordReset is the reset of the password by the emailed link
+	AuditPasswordReset = "auth.password_reset"
+
+	AuditUserCreate        = "user.create"
+	AuditUserUpdate        = "user.update"
+	AuditUserDelete        = "user.delete"
+	AuditUserDisable       = "user.disable"
+	AuditUserEnable        = "user.enable"
+	AuditUserResetPassword = "user.reset_password"
+	AuditUserUnlock        = "user.unlock"
+	AuditUserEnableMFA     = "user.enable_mfa"
+	AuditUserSetRoles      = "user.set_roles"
+
+	AuditAPIKeyCreate         = "api_key.create"
+	AuditAPIKeyRevoke         = "api_key.revoke"
+	AuditServiceAccountCreate = "service_account.create"
+	AuditServiceAccountDelete = "service_account.delete"
+	AuditWebhookCreate        = "webhook.create"
+	AuditWebhookDelete        = "webhook.delete"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
@@ -7,6 +7,18 @@ const (
 	MongoDBTokenFlagName  = "mongo-db-token"
 	MongoDBTokenFlagUsage = "connection string for MongoDB"
 
+	PostgresFlagName  = "postgres"
+	PostgresFlagUsage = "connection string for PostgreSQL"
+
 	SigningKeyFlagName  = "signing-key"
 	SigningKeyFlagUsage = "jwt signing key"
+
+	SMTPPasswordFlagName  = "smtp-password"
+	SMTPPasswordFlagUsage = "password for the smtp server"
+
+	LDAPBindPasswordFlagName  = "ldap-bind-password"
+	LDAPBindPasswordFlagUsage = "password of the ldap service account"
+
+	RedisPasswordFlagName  = "redis-password"
+	RedisPasswordFlagUsage = "password for the redis server"
 )
@@ -0,0 +1,7 @@
+package constant
+
+const (
+	RefreshTokenCookie = "refresh_token"
+	// BindingCookie ties passwordless sign in to the browser that requested it
+	BindingCookie = "gas_binding"
+)
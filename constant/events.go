@@ -0,0 +1,62 @@
+package constant
+
+// Event types published on changes of users and sessions
+const (
+	EventUserCreated               = "user.created"
+	EventUserUpdated               = "user.updated"
+	EventUserDeleted               = "user.deleted"
+	EventUserDisabled              = "user.disabled"
+	EventUserEnabled               = "user.enabled"
+	EventUserLocked                = "user.locked"
+	EventUserUnlocked              = "user.unlocked"
+	EventUserSignedIn              = "user.signed_in"
+	EventUserPasswordChanged       = "user.password_changed"
+	EventUserPasswordResetRequired = "user.password_reset_required"
+	EventUserMFAEnabled            = "user.mfa_enabled"
+	EventSessionRevoked            = "session.revoked"
+	EventAPIKeyCreated             = "api_key.created"
+	EventAPIKeyRevoked             = "api_key.revoked"
+	EventServiceAccountCreated     = "service_account.created"
+	EventServiceAccountDeleted     = "service_account.deleted"
+
+	// EventAll subscribes a webhook to every event type
+	EventAll = "*"
+)
+
+// EventTypes are the event types webhooks can subscribe to
+var EventTypes = []string{
+	EventUserCreated,
+	EventUserUpdated,
+	EventUserDeleted,
+	EventUserDisabled,
+	EventUserEnabled,
+	EventUserLocked,
+	EventUserUnlocked,
+	EventUserSignedIn,
+	EventUserPasswordChanged,
+	EventUserPasswordResetRequired,
+	EventUserMFAEnabled,
+	EventSessionRevoked,
+	EventAPIKeyCreated,
+	EventAPIKeyRevoked,
+	EventServiceAccountCreated,
+	EventServiceAccountDeleted,
+}
+
+const (
+	// WebhookSignatureHeader is t=<unix time>,v1=<hex hmac-sha256 of "<unix time>.<body>" with the webhook secret>
+	WebhookSignatureHeader = "X-Gas-Signature"
+	WebhookEventHeader     = "X-Gas-Event"
+	WebhookDeliveryHeader  = "X-Gas-Delivery"
+
+	// WebhookSecretPrefix tells webhook secrets from other credentials
+	WebhookSecretPrefix = "whsec_"
+)
+
+// Sinks of the outbox relay
+const (
+	SinkWebhooks = "webhooks"
+	SinkNATS     = "nats"
+	SinkKafka    = "kafka"
+	SinkStdout   = "stdout"
+)
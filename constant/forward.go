@@ -0,0 +1,10 @@
+package constant
+
+// headers forward-auth answers the proxy with, the proxy passes them on to the upstream
+const (
+	UserIDHeader    = "X-User-Id"
+	UserEmailHeader = "X-User-Email"
+	UserRolesHeader = "X-User-Roles"
+	// ForwardedHostHeader is the host of the original request, set by traefik and by the nginx config
+	ForwardedHostHeader = "X-Forwarded-Host"
+)
@@ -0,0 +1,7 @@
+package constant
+
+// Second factors of the sign in, the user has one of them when two-factor authentication is enabled
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
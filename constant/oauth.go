@@ -0,0 +1,6 @@
+package constant
+
+const (
+	OAuthTypeOIDC   = "oidc"
+	OAuthTypeOAuth2 = "oauth2"
+)
@@ -0,0 +1,8 @@
+package constant
+
+const (
+	RateLimitKeyIP   = "ip"
+	RateLimitKeyUser = "user"
+	// RateLimitKeyClient keys by the api key or the service account the key belongs to
+	RateLimitKeyClient = "client"
+)
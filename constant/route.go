@@ -7,4 +7,71 @@ const (
 	VerifyRoute  = "/verify"
 	RefreshRoute = "/refresh"
 	SignOutRoute = "/sign-out"
+	UnlockRoute  = "/unlock"
+	ForwardRoute = "/forward"
+	// IntrospectRoute describes a token for the clients that can't verify it themselves
+	IntrospectRoute = "/introspect"
+
+	PasswordResetRoute        = "/password-reset"
+	PasswordResetConfirmRoute = "/password-reset/confirm"
+
+	MagicLinkRoute       = "/magic-link"
+	MagicLinkVerifyRoute = "/magic-link/verify"
+	OTPRoute             = "/otp"
+	OTPVerifyRoute       = "/otp/verify"
+
+	OAuthRoute         = "/oauth/{provider}"
+	OAuthCallbackRoute = "/oauth/{provider}/callback"
+	OAuthProviderParam = "provider"
+
+	SAMLRoute         = "/saml"
+	SAMLMetadataRoute = "/metadata"
+	SAMLLoginRoute    = "/login"
+	SAMLACSRoute      = "/acs"
+
+	APIKeysRoute  = "/api-keys"
+	APIKeyRoute   = "/api-keys/{key_id}"
+	APIKeyIDParam = "key_id"
+
+	MFARoute               = "/mfa"
+	TOTPEnrollRoute        = "/totp/enroll"
+	TOTPConfirmRoute       = "/totp/confirm"
+	MFAVerifyRoute         = "/verify"
+	MFAWebAuthnBeginRoute  = "/webauthn/begin"
+	MFAWebAuthnFinishRoute = "/webauthn/finish"
+
+	WebAuthnRoute                   = "/webauthn"
+	WebAuthnBeginRegistrationRoute  = "/register/begin"
+	WebAuthnFinishRegistrationRoute = "/register/finish"
+	WebAuthnBeginLoginRoute         = "/login/begin"
+	WebAuthnFinishLoginRoute        = "/login/finish"
+)
+
+// JWKSRoute is mounted at the root like the other well-known documents
+const JWKSRoute = "/.well-known/jwks.json"
+
+const (
+	AdminRoute             = "/admin"
+	UsersRoute             = "/users"
+	UserRoute              = "/users/{id}"
+	UserDisableRoute       = "/users/{id}/disable"
+	UserEnableRoute        = "/users/{id}/enable"
+	UserResetPasswordRoute = "/users/{id}/reset-password"
+	UserUnlockRoute        = "/users/{id}/unlock"
+	UserIDParam            = "id"
+
+	ServiceAccountsRoute       = "/service-accounts"
+	ServiceAccountRoute        = "/service-accounts/{id}"
+	ServiceAccountAPIKeysRoute = "/service-accounts/{id}/api-keys"
+	ServiceAccountAPIKeyRoute  = "/service-accounts/{id}/api-keys/{key_id}"
+	ServiceAccountIDParam      = "id"
+
+	WebhooksRoute          = "/webhooks"
+	WebhookRoute           = "/webhooks/{id}"
+	WebhookDeliveriesRoute = "/webhooks/{id}/deliveries"
+	WebhookIDParam         = "id"
+
+	AuditRoute       = "/audit"
+	AuditExportRoute = "/audit/export"
+	AuditVerifyRoute = "/audit/verify"
 )
@@ -0,0 +1,12 @@
+package constant
+
+// Storage backends, the stores of lockout counters, ceremonies and revoked tokens may also be kept in memory
+// or in redis with any backend
+const (
+	StoreMemory   = "memory"
+	StoreMongoDB  = "mongodb"
+	StorePostgres = "postgres"
+	StoreSQLite   = "sqlite"
+	// StoreRedis is not a backend, it keeps only the stores which expire
+	StoreRedis = "redis"
+)
@@ -6,26 +6,314 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	MongoConnectionString string
-	Env                   string `yaml:"env" env-default:"local"`
-	HTTPServer            `yaml:"http_server"`
-	JwtSettings           `yaml:"jwt_settings"`
+	MongoConnectionString    string
+	PostgresConnectionString string
+	Storage                  StorageSettings `yaml:"storage"`
+	Redis                    RedisSettings   `yaml:"redis"`
+	Cache                    CacheSettings   `yaml:"cache"`
+	Env                      string          `yaml:"env" env-default:"local"`
+	HTTPServer               `yaml:"http_server"`
+	JwtSettings              `yaml:"jwt_settings"`
+	Admin                    AdminSettings         `yaml:"admin"`
+	Lockout                  LockoutSettings       `yaml:"lockout"`
+	PasswordReset            PasswordResetSettings `yaml:"password_reset"`
+	Mail                     MailSettings          `yaml:"mail"`
+	RateLimit                RateLimitSettings     `yaml:"rate_limit"`
+	MFA                      MFASettings           `yaml:"mfa"`
+	WebAuthn                 WebAuthnSettings      `yaml:"webauthn"`
+	Passwordless             PasswordlessSettings  `yaml:"passwordless"`
+	OAuth                    OAuthSettings         `yaml:"oauth"`
+	LDAP                     LDAPSettings          `yaml:"ldap"`
+	SAML                     SAMLSettings          `yaml:"saml"`
+	ForwardAuth              ForwardAuthSettings   `yaml:"forward_auth"`
+	ExtAuthz                 ExtAuthzSettings      `yaml:"ext_authz"`
+	GRPCServer               GRPCServerSettings    `yaml:"grpc_server"`
+	Webhooks                 WebhookSettings       `yaml:"webhooks"`
+	Outbox                   OutboxSettings        `yaml:"outbox"`
+}
+
+type StorageSettings struct {
+	// Backend is the database of users and the rest of gas state: mongodb, postgres, sqlite or memory
+	Backend string `yaml:"backend" env-default:"mongodb"`
+	// SQLitePath is the database file of the sqlite backend, it is created on the first start
+	SQLitePath string `yaml:"sqlite_path" env-default:"gas.db"`
+	// SnapshotPath is the file the memory backend loads its state from and saves it to, the state is lost without it
+	SnapshotPath     string        `yaml:"snapshot_path"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env-default:"1m"`
+}
+
+// RedisSettings is the server of the stores set to redis, the replicas of gas share them through it
+type RedisSettings struct {
+	Address  string `yaml:"address" env-default:"localhost:6379"`
+	Password string
+	DB       int `yaml:"db"`
+	// KeyPrefix keeps the keys of gas apart from the other data on the server
+	KeyPrefix string `yaml:"key_prefix" env-default:"gas:"`
+	// Embedded starts an in-process redis-compatible server instead of connecting to Address,
+	// nothing is shared with the other replicas, it is meant for tests and local runs
+	Embedded bool `yaml:"embedded"`
+}
+
+// CacheSettings bounds the in-process caches of the users and the verified tokens
+type CacheSettings struct {
+	// Size is the number of entries of each cache, zero turns the caching off
+	Size int `yaml:"size" env-default:"10000"`
+	// TTL is how long a cached user is used. The changes made by the other replicas of gas are dropped from
+	// the cache through redis when any store is kept there, and seen only after the ttl otherwise
+	TTL time.Duration `yaml:"ttl" env-default:"30s"`
+}
+
+type OutboxSettings struct {
+	// Sinks the relay delivers events to: webhooks, nats, kafka or stdout
+	Sinks        []string      `yaml:"sinks" env-default:"webhooks"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	// Lease hides the claimed events from the relays of other replicas while they are sent, it has to be
+	// longer than the webhooks timeout
+	Lease time.Duration `yaml:"lease" env-default:"30s"`
+	// InitialBackoff doubles with every failed attempt of an event up to MaxBackoff, events are never dropped
+	// but the webhooks give up on an event after their max attempts
+	InitialBackoff time.Duration `yaml:"initial_backoff" env-default:"5s"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env-default:"10m"`
+	NATS           NATSSettings  `yaml:"nats"`
+	Kafka          KafkaSettings `yaml:"kafka"`
+}
+
+type NATSSettings struct {
+	URL string `yaml:"url" env-default:"nats://localhost:4222"`
+	// Subject is the prefix of the subjects, the event type is appended, e.g. gas.events.user.created
+	Subject string `yaml:"subject" env-default:"gas.events"`
+}
+
+type KafkaSettings struct {
+	Brokers []string `yaml:"brokers" env-default:"localhost:9092"`
+	Topic   string   `yaml:"topic" env-default:"gas.events"`
+}
+
+type WebhookSettings struct {
+	// Workers is the number of webhooks an event is posted to at the same time
+	Workers int `yaml:"workers" env-default:"4"`
+	// MaxAttempts of an event per webhook includes the first attempt, the delay between attempts is
+	// the backoff of the outbox
+	MaxAttempts int           `yaml:"max_attempts" env-default:"8"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+type GRPCServerSettings struct {
+	// Enabled runs the gas.v1.AuthService grpc api next to the http server
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address" env-default:"localhost:2024"`
+}
+
+type ExtAuthzSettings struct {
+	// Enabled runs the envoy external authorization grpc server, it follows the forward-auth settings
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address" env-default:"localhost:9191"`
+}
+
+type ForwardAuthSettings struct {
+	// Hosts maps the hosts behind the proxy to the roles the user needs one of, a host listed without roles
+	// lets any signed in user pass. The hosts are matched case-insensitively. Without trust_proxy_headers
+	// the host is the Host header of the request gas gets, so the rules apply per host only when the proxy
+	// passes the original host in it, otherwise every request has the host of gas
+	Hosts map[string][]string `yaml:"hosts"`
+	// DenyUnlisted refuses the hosts that are not listed, any signed in user passes for them otherwise
+	DenyUnlisted bool `yaml:"deny_unlisted"`
+}
+
+type SAMLSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// EntityID identifies gas at the identity provider, the metadata url by default
+	EntityID string `yaml:"entity_id"`
+	// MetadataURL and ACSURL are the public urls of the saml endpoints of gas
+	MetadataURL string `yaml:"metadata_url"`
+	ACSURL      string `yaml:"acs_url"`
+	// CertificateFile and KeyFile are the pem files of the rsa key pair gas signs requests with
+	CertificateFile string `yaml:"certificate_file"`
+	KeyFile         string `yaml:"key_file"`
+	// IDPMetadataURL is fetched on the first use, IDPMetadataFile is used instead when it is set
+	IDPMetadataURL  string `yaml:"idp_metadata_url"`
+	IDPMetadataFile string `yaml:"idp_metadata_file"`
+	// AllowIDPInitiated accepts assertions the user did not start the sign in for at gas
+	AllowIDPInitiated bool          `yaml:"allow_idp_initiated"`
+	RequestDuration   time.Duration `yaml:"request_duration" env-default:"10m"`
+	// EmailAttribute is the attribute with the email, the name id is used by default
+	EmailAttribute string `yaml:"email_attribute"`
+	// Attributes maps assertion attributes to user info fields by name or friendly name
+	Attributes map[string]string `yaml:"attributes"`
+	// GroupAttribute holds the groups of the user
+	GroupAttribute string `yaml:"group_attribute"`
+	// GroupRoles maps groups to roles, the identity provider manages only these roles of the user
+	GroupRoles map[string]string `yaml:"group_roles"`
+}
+
+type LDAPSettings struct {
+	// Enabled checks passwords in the directory instead of the local password hashes
+	Enabled  bool   `yaml:"enabled"`
+	URL      string `yaml:"url" env-default:"ldap://localhost:389"`
+	StartTLS bool   `yaml:"start_tls"`
+	// BindDN is the service account users are searched with, its password is passed by the flag
+	BindDN       string `yaml:"bind_dn"`
+	BindPassword string
+	BaseDN       string `yaml:"base_dn"`
+	// UserFilter finds the user by email, %s is replaced with the escaped email
+	UserFilter string `yaml:"user_filter" env-default:"(mail=%s)"`
+	// Attributes maps directory attributes to user info fields, e.g. displayName: name
+	Attributes map[string]string `yaml:"attributes"`
+	// GroupAttribute holds the dns of the groups of the user
+	GroupAttribute string `yaml:"group_attribute" env-default:"memberOf"`
+	// GroupRoles maps group dns to roles, the directory manages only these roles of the user
+	GroupRoles map[string]string `yaml:"group_roles"`
+	Timeout    time.Duration     `yaml:"timeout" env-default:"5s"`
+}
+
+type OAuthSettings struct {
+	// StateDuration is how long the user has to complete the sign in at the provider
+	StateDuration time.Duration `yaml:"state_duration" env-default:"10m"`
+	// AutoCreate creates accounts for verified emails that are not registered yet
+	AutoCreate bool `yaml:"auto_create"`
+	// LinkByEmail links the provider account to the local account with the same email when the provider
+	// reports the email as verified, enable it only for providers whose email_verified claim can be trusted
+	LinkByEmail bool `yaml:"link_by_email"`
+	// Providers by the name used in the sign in url, e.g. google or github
+	Providers map[string]OAuthProvider `yaml:"providers"`
+}
+
+type OAuthProvider struct {
+	// Type is oidc for providers with discovery or oauth2 for plain oauth2 providers, oidc by default
+	Type     string `yaml:"type"`
+	ClientID string `yaml:"client_id"`
+	// ClientSecretEnv is the environment variable the client secret is read from
+	ClientSecretEnv string   `yaml:"client_secret_env"`
+	ClientSecret    string   `yaml:"-"`
+	RedirectURL     string   `yaml:"redirect_url"`
+	Scopes          []string `yaml:"scopes"`
+
+	// Issuer is used by oidc providers
+	Issuer string `yaml:"issuer"`
+
+	// AuthURL, TokenURL and UserInfoURL are used by oauth2 providers
+	AuthURL     string `yaml:"auth_url"`
+	TokenURL    string `yaml:"token_url"`
+	UserInfoURL string `yaml:"user_info_url"`
+	// SubjectField and EmailField are the user info fields, id and email by default
+	SubjectField string `yaml:"subject_field"`
+	EmailField   string `yaml:"email_field"`
+	// EmailVerified tells that the provider returns only verified emails
+	EmailVerified bool `yaml:"email_verified"`
+}
+
+type PasswordlessSettings struct {
+	// MagicLinkURL is the page of the app that sends the token from the token query parameter to gas
+	MagicLinkURL      string        `yaml:"magic_link_url"`
+	MagicLinkDuration time.Duration `yaml:"magic_link_duration" env-default:"10m"`
+	OTPDuration       time.Duration `yaml:"otp_duration" env-default:"5m"`
+	// OTPAttempts is the number of wrong codes for the email within otp_duration after which codes are discarded,
+	// new codes do not reset it. Wrong codes are also counted by the lockout like wrong passwords
+	OTPAttempts int `yaml:"otp_attempts" env-default:"5"`
+	// AutoCreate creates accounts for unknown emails
+	AutoCreate bool `yaml:"auto_create"`
+}
+
+type WebAuthnSettings struct {
+	// RPID is the domain passkeys are bound to
+	RPID          string        `yaml:"rp_id" env-default:"localhost"`
+	RPDisplayName string        `yaml:"rp_display_name" env-default:"gas"`
+	RPOrigins     []string      `yaml:"rp_origins" env-default:"http://localhost:2023"`
+	Timeout       time.Duration `yaml:"timeout" env-default:"5m"`
+	// SessionStore is where ceremonies state is kept between the begin and finish requests: memory, redis or the storage backend
+	SessionStore string `yaml:"session_store" env-default:"memory"`
+}
+
+type MFASettings struct {
+	// Issuer is the account name prefix shown in authenticator apps
+	Issuer            string        `yaml:"issuer" env-default:"gas"`
+	ChallengeDuration time.Duration `yaml:"challenge_duration" env-default:"5m"`
+	// Skew is the number of 30 seconds steps around the current one the codes are accepted for
+	Skew          int `yaml:"skew" env-default:"1"`
+	RecoveryCodes int `yaml:"recovery_codes" env-default:"10"`
+}
+
+type RateLimitSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// Store is where token buckets are kept: memory or redis
+	Store   string        `yaml:"store" env-default:"memory"`
+	Default RateLimitRule `yaml:"default"`
+	// Routes are rules by route pattern, e.g. /auth/sign-in or /admin/users/{id}, the default rule is used
+	// for the other routes and the requests no route matches
+	Routes map[string]RateLimitRule `yaml:"routes"`
+}
+
+type RateLimitRule struct {
+	// Limit is the number of requests per period, zero disables the limit
+	Limit  int           `yaml:"limit" env-default:"100"`
+	Period time.Duration `yaml:"period" env-default:"1m"`
+	// Key is what clients are told apart by: ip, user or client. The client is the service account of the api key
+	// or the api key of a user, it is never taken from a header. The requests without a valid access token or
+	// api key are keyed by ip
+	Key string `yaml:"key" env-default:"ip"`
+}
+
+type LockoutSettings struct {
+	// Store is where failed attempts counters are kept: memory, redis or the storage backend
+	Store            string        `yaml:"store" env-default:"memory"`
+	AccountThreshold int           `yaml:"account_threshold" env-default:"5"`
+	IPThreshold      int           `yaml:"ip_threshold" env-default:"20"`
+	Window           time.Duration `yaml:"window" env-default:"15m"`
+	LockDuration     time.Duration `yaml:"lock_duration" env-default:"15m"`
+	DelayStep        time.Duration `yaml:"delay_step" env-default:"250ms"`
+	MaxDelay         time.Duration `yaml:"max_delay" env-default:"4s"`
+	// UnlockURL is sent to a locked user by email with the unlock token in the token query parameter
+	UnlockURL      string        `yaml:"unlock_url"`
+	UnlockDuration time.Duration `yaml:"unlock_duration" env-default:"1h"`
+}
+
+type PasswordResetSettings struct {
+	// URL is the page of the app that asks for the new password and sends it to gas with the token
+	// from the token query parameter
+	URL      string        `yaml:"url"`
+	Duration time.Duration `yaml:"duration" env-default:"1h"`
+}
+
+type MailSettings struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password string
+	From     string `yaml:"from" env-default:"gas@localhost"`
+}
+
+type AdminSettings struct {
+	// Emails of users that are treated as admins regardless of their roles
+	Emails []string `yaml:"emails"`
 }
 
 type JwtSettings struct {
 	SigningKey      []byte
 	AccessDuration  time.Duration `yaml:"access_duration" env-default:"300s"`
-	RefreshDuration time.Duration `yaml:"access_duration" env-default:"604800s"`
+	RefreshDuration time.Duration `yaml:"refresh_duration" env-default:"604800s"`
+	// RefreshCookieDomain shares the refresh cookie with the subdomains, e.g. example.com for forward auth
+	// of blog.example.com, the cookie is sent only to the host of gas by default
+	RefreshCookieDomain string `yaml:"refresh_cookie_domain"`
+	// RevocationStore is where the ids of signed out refresh tokens are kept: memory, redis or the storage backend
+	RevocationStore string `yaml:"revocation_store" env-default:"memory"`
+	// PrivateKeyFile is the pem rsa or ecdsa p-256 key access tokens are signed with instead of the signing key,
+	// its public key is served at /.well-known/jwks.json
+	PrivateKeyFile string `yaml:"private_key_file"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:2023"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// TrustProxyHeaders takes the client ip from X-Forwarded-For and X-Real-IP and the host of forward-auth
+	// from X-Forwarded-Host, without it forward-auth uses the Host header of the request to gas
+	TrustProxyHeaders bool `yaml:"trust_proxy_headers"`
 }
 
 func MustLoad() Config {
@@ -35,11 +323,6 @@ func MustLoad() Config {
 		constant.ConfigPathFlagUsage,
 	)
 
-	// check if file exists
-	if _, err := os.Stat(*configPath); os.IsNotExist(err) {
-		log.Fatalf("config file does not exist: %s", *configPath)
-	}
-
 	// reading flags
 	mongoConnectionString := flag.String(
 		constant.MongoDBTokenFlagName,
@@ -47,19 +330,44 @@ func MustLoad() Config {
 		constant.MongoDBTokenFlagUsage,
 	)
 
+	postgresConnectionString := flag.String(
+		constant.PostgresFlagName,
+		"",
+		constant.PostgresFlagUsage,
+	)
+
 	jwtSigningKey := flag.String(
 		constant.SigningKeyFlagName,
 		"",
 		constant.SigningKeyFlagUsage,
 	)
 
+	smtpPassword := flag.String(
+		constant.SMTPPasswordFlagName,
+		"",
+		constant.SMTPPasswordFlagUsage,
+	)
+
+	ldapBindPassword := flag.String(
+		constant.LDAPBindPasswordFlagName,
+		"",
+		constant.LDAPBindPasswordFlagUsage,
+	)
+
+	redisPassword := flag.String(
+		constant.RedisPasswordFlagName,
+		"",
+		constant.RedisPasswordFlagUsage,
+	)
+
 	flag.Parse()
 
-	// checking for flags
-	if *mongoConnectionString == "" {
-		log.Fatal("mongo connection string is not specified")
+	// check if file exists
+	if _, err := os.Stat(*configPath); os.IsNotExist(err) {
+		log.Fatalf("config file does not exist: %s", *configPath)
 	}
 
+	// checking for flags
 	if *jwtSigningKey == "" {
 		log.Fatal("jwt signing key is not specified")
 	}
@@ -67,11 +375,43 @@ func MustLoad() Config {
 	var cfg Config
 
 	cfg.MongoConnectionString = *mongoConnectionString
+	cfg.PostgresConnectionString = *postgresConnectionString
 	cfg.JwtSettings.SigningKey = []byte(*jwtSigningKey)
+	cfg.Mail.Password = *smtpPassword
+	cfg.LDAP.BindPassword = *ldapBindPassword
+	cfg.Redis.Password = *redisPassword
 
 	if err := cleanenv.ReadConfig(*configPath, &cfg); err != nil {
 		log.Fatalf("cannot read config: %s", err)
 	}
 
+	// the connection string of the backend in use is required
+	switch cfg.Storage.Backend {
+	case constant.StoreMongoDB:
+		if cfg.MongoConnectionString == "" {
+			log.Fatal("mongo connection string is not specified")
+		}
+	case constant.StorePostgres:
+		if cfg.PostgresConnectionString == "" {
+			log.Fatal("postgres connection string is not specified")
+		}
+	case constant.StoreSQLite, constant.StoreMemory:
+	default:
+		log.Fatalf("unknown storage backend: %s", cfg.Storage.Backend)
+	}
+
+	// the hosts are looked up in lowercase
+	hosts := make(map[string][]string, len(cfg.ForwardAuth.Hosts))
+	for host, roles := range cfg.ForwardAuth.Hosts {
+		hosts[strings.ToLower(host)] = append(hosts[strings.ToLower(host)], roles...)
+	}
+
+	cfg.ForwardAuth.Hosts = hosts
+
+	for name, provider := range cfg.OAuth.Providers {
+		provider.ClientSecret = os.Getenv(provider.ClientSecretEnv)
+		cfg.OAuth.Providers[name] = provider
+	}
+
 	return cfg
 }
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	gasv1 "github.com/degeboman/gas/api/gas/v1"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/usecase"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log/slog"
+	"net"
+)
+
+// Auth is the part of the usecase the http handlers of /auth call
+type Auth interface {
+	CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error)
+	Signin(ctx context.Context, cfg config.Config, email, password, ip string) (usecase.Tokens, error)
+	RefreshToken(ctx context.Context, cfg config.Config, refreshToken string) (string, error)
+	VerifyToken(ctx context.Context, signingKey []byte, token string) (interface{}, error)
+	SignOut(ctx context.Context, cfg config.Config, refreshToken string) error
+	VerifyMFA(ctx context.Context, cfg config.Config, mfaToken, code, ip string) (usecase.Tokens, error)
+}
+
+// Server is the gas.v1.AuthService, every method does what the http handler with the same name does
+type Server struct {
+	gasv1.UnimplementedAuthServiceServer
+
+	log  *slog.Logger
+	cfg  config.Config
+	auth Auth
+}
+
+func New(log *slog.Logger, cfg config.Config, auth Auth) *Server {
+	return &Server{
+		log:  log.With(slog.String("component", "grpc-server/auth")),
+		cfg:  cfg,
+		auth: auth,
+	}
+}
+
+func (s *Server) SignUp(ctx context.Context, req *gasv1.SignUpRequest) (*gasv1.SignUpResponse, error) {
+	const op = "grpc.auth.Server.SignUp"
+
+	log := s.log.With(slog.String("op", op))
+
+	var userInfo interface{}
+	if req.GetUserInfo() != nil {
+		userInfo = req.GetUserInfo().AsMap()
+	}
+
+	id, err := s.auth.CreateUser(ctx, req.GetEmail(), req.GetPassword(), userInfo)
+	if err != nil {
+		log.Error("failed to sign up", sl.Err(err))
+
+		return nil, status.Error(errorCode(err), "failed to sign up")
+	}
+
+	log.Info("user created", slog.String("id", id))
+
+	return &gasv1.SignUpResponse{Id: id}, nil
+}
+
+func (s *Server) SignIn(ctx context.Context, req *gasv1.SignInRequest) (*gasv1.SignInResponse, error) {
+	const op = "grpc.auth.Server.SignIn"
+
+	log := s.log.With(slog.String("op", op))
+
+	tokens, err := s.auth.Signin(ctx, s.cfg, req.GetEmail(), req.GetPassword(), peerIP(ctx))
+	if errors.Is(err, usecase.ErrTemporarilyLocked) {
+		log.Warn("sign in is temporarily locked", slog.String("email", req.GetEmail()))
+
+		return nil, status.Error(codes.ResourceExhausted, usecase.ErrTemporarilyLocked.Error())
+	}
+	if err != nil {
+		log.Info("failed to sign in", sl.Err(err))
+
+		return nil, status.Error(codes.Unauthenticated, "failed to sign in")
+	}
+
+	if tokens.MFA != "" {
+		return &gasv1.SignInResponse{
+			MfaRequired: true,
+			MfaToken:    tokens.MFA,
+			MfaMethod:   tokens.MFAMethod,
+		}, nil
+	}
+
+	return &gasv1.SignInResponse{
+		AccessToken:  tokens.Access,
+		RefreshToken: tokens.Refresh,
+	}, nil
+}
+
+func (s *Server) VerifyMFA(ctx context.Context, req *gasv1.VerifyMFARequest) (*gasv1.VerifyMFAResponse, error) {
+	const op = "grpc.auth.Server.VerifyMFA"
+
+	log := s.log.With(slog.String("op", op))
+
+	tokens, err := s.auth.VerifyMFA(ctx, s.cfg, req.GetMfaToken(), req.GetCode(), peerIP(ctx))
+	if errors.Is(err, usecase.ErrTemporarilyLocked) {
+		log.Warn("mfa verification is temporarily locked")
+
+		return nil, status.Error(codes.ResourceExhausted, usecase.ErrTemporarilyLocked.Error())
+	}
+	if errors.Is(err, usecase.ErrPasskeyRequired) {
+		log.Info("mfa verification needs a passkey")
+
+		return nil, status.Error(codes.FailedPrecondition, usecase.ErrPasskeyRequired.Error())
+	}
+	if err != nil {
+		log.Info("failed to verify mfa", sl.Err(err))
+
+		return nil, status.Error(codes.Unauthenticated, "failed to verify mfa")
+	}
+
+	return &gasv1.VerifyMFAResponse{
+		AccessToken:  tokens.Access,
+		RefreshToken: tokens.Refresh,
+	}, nil
+}
+
+func (s *Server) Refresh(ctx context.Context, req *gasv1.RefreshRequest) (*gasv1.RefreshResponse, error) {
+	const op = "grpc.auth.Server.Refresh"
+
+	log := s.log.With(slog.String("op", op))
+
+	access, err := s.auth.RefreshToken(ctx, s.cfg, req.GetRefreshToken())
+	if err != nil {
+		log.Info("failed to refresh token", sl.Err(err))
+
+		return nil, status.Error(codes.Unauthenticated, "failed to refresh token")
+	}
+
+	return &gasv1.RefreshResponse{AccessToken: access}, nil
+}
+
+func (s *Server) Verify(ctx context.Context, req *gasv1.VerifyRequest) (*gasv1.VerifyResponse, error) {
+	const op = "grpc.auth.Server.Verify"
+
+	log := s.log.With(slog.String("op", op))
+
+	if _, err := s.auth.VerifyToken(ctx, s.cfg.SigningKey, req.GetToken()); err != nil {
+		log.Info("failed to verify token", sl.Err(err))
+
+		return nil, status.Error(codes.Unauthenticated, "failed to verify token")
+	}
+
+	return &gasv1.VerifyResponse{}, nil
+}
+
+func (s *Server) SignOut(ctx context.Context, req *gasv1.SignOutRequest) (*gasv1.SignOutResponse, error) {
+	const op = "grpc.auth.Server.SignOut"
+
+	log := s.log.With(slog.String("op", op))
+
+	if err := s.auth.SignOut(ctx, s.cfg, req.GetRefreshToken()); err != nil {
+		log.Info("failed to sign out", sl.Err(err))
+
+		return nil, status.Error(codes.Unauthenticated, "failed to sign out")
+	}
+
+	return &gasv1.SignOutResponse{}, nil
+}
+
+func (s *Server) Introspect(ctx context.Context, req *gasv1.IntrospectRequest) (*gasv1.IntrospectResponse, error) {
+	const op = "grpc.auth.Server.Introspect"
+
+	log := s.log.With(slog.String("op", op))
+
+	data, err := s.auth.VerifyToken(ctx, s.cfg.SigningKey, req.GetToken())
+	if err != nil {
+		log.Info("token is not active", sl.Err(err))
+
+		return &gasv1.IntrospectResponse{Active: false}, nil
+	}
+
+	claims, ok := data.(*usecase.UserClaims)
+	if !ok {
+		log.Error("unexpected claims type")
+
+		return nil, status.Error(codes.Internal, "unexpected claims type")
+	}
+
+	resp := &gasv1.IntrospectResponse{
+		Active:   true,
+		UserId:   claims.UserID(),
+		Email:    claims.Email(),
+		Roles:    claims.Roles(),
+		ApiKeyId: claims.APIKeyID(),
+		Scopes:   claims.Scopes(),
+	}
+
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+
+	if info, ok := claims.UserInfo.(map[string]interface{}); ok {
+		if resp.UserInfo, err = structpb.NewStruct(info); err != nil {
+			log.Error("failed to convert user info", sl.Err(err))
+
+			return nil, status.Error(codes.Internal, "failed to convert user info")
+		}
+	}
+
+	return resp, nil
+}
+
+// errorCode maps the errors of the usecase and the storage to the codes of the statuses,
+// the unknown errors are failures of gas
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, usecase.ErrEmailNotValid), errors.Is(err, usecase.ErrPasswordTooShort):
+		return codes.InvalidArgument
+	case errors.Is(err, storage.ErrEmailTaken):
+		return codes.AlreadyExists
+	case errors.Is(err, storage.ErrNotFound):
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gasv1 "github.com/degeboman/gas/api/gas/v1"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/usecase"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+)
+
+type fakeAuth struct {
+	usecase.Usecase
+}
+
+func (fakeAuth) CreateUser(_ context.Context, email, _ string, _ interface{}) (string, error) {
+	switch email {
+	case "taken@mail.ru":
+		return "", fmt.Errorf("usecase.usecase.CreateUser: %w", storage.ErrEmailTaken)
+	case "invalid":
+		return "", fmt.Errorf("usecase.usecase.CreateUser: %w", usecase.ErrEmailNotValid)
+	case "down@mail.ru":
+		return "", errors.New("connection refused")
+	}
+
+	return "1", nil
+}
+
+func (fakeAuth) Signin(_ context.Context, _ config.Config, email, password, _ string) (usecase.Tokens, error) {
+	if email == "locked@mail.ru" {
+		return usecase.Tokens{}, usecase.ErrTemporarilyLocked
+	}
+
+	if email == "mfa@mail.ru" {
+		return usecase.Tokens{MFA: "mfa", MFAMethod: constant.MFAMethodTOTP}, nil
+	}
+
+	if password != "password" {
+		return usecase.Tokens{}, errors.New("wrong password")
+	}
+
+	return usecase.Tokens{Access: "access", Refresh: "refresh"}, nil
+}
+
+func (fakeAuth) VerifyMFA(_ context.Context, _ config.Config, mfaToken, code, _ string) (usecase.Tokens, error) {
+	switch {
+	case mfaToken == "passkey":
+		return usecase.Tokens{}, usecase.ErrPasskeyRequired
+	case mfaToken != "mfa" || code != "123456":
+		return usecase.Tokens{}, errors.New("code is not valid")
+	}
+
+	return usecase.Tokens{Access: "access", Refresh: "refresh"}, nil
+}
+
+func (fakeAuth) VerifyToken(_ context.Context, _ []byte, token string) (interface{}, error) {
+	if token != "access" {
+		return nil, errors.New("token is not valid")
+	}
+
+	return &usecase.UserClaims{UserInfo: map[string]interface{}{
+		"_id":   "1",
+		"email": "rupychman@mail.ru",
+		"roles": []interface{}{"admin"},
+	}}, nil
+}
+
+func client(t *testing.T) gasv1.AuthServiceClient {
+	lis := bufconn.Listen(1 << 20)
+
+	s := grpc.NewServer()
+	gasv1.RegisterAuthServiceServer(s, New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Config{}, fakeAuth{}))
+
+	go func() {
+		_ = s.Serve(lis)
+	}()
+
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return gasv1.NewAuthServiceClient(conn)
+}
+
+func TestSignIn(t *testing.T) {
+	c := client(t)
+
+	data := []struct {
+		name     string
+		email    string
+		password string
+		code     codes.Code
+	}{
+		{
+			name:     "correct",
+			email:    "rupychman@mail.ru",
+			password: "password",
+			code:     codes.OK,
+		},
+		{
+			name:     "wrong password",
+			email:    "rupychman@mail.ru",
+			password: "wrong",
+			code:     codes.Unauthenticated,
+		},
+		{
+			name:     "locked",
+			email:    "locked@mail.ru",
+			password: "password",
+			code:     codes.ResourceExhausted,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp, err := c.SignIn(context.Background(), &gasv1.SignInRequest{Email: d.email, Password: d.password})
+			if status.Code(err) != d.code {
+				t.Fatalf("Expected %v, got %v", d.code, status.Code(err))
+			}
+
+			if err == nil && resp.GetRefreshToken() != "refresh" {
+				t.Errorf("Expected %v, got %v", "refresh", resp.GetRefreshToken())
+			}
+		})
+	}
+
+	resp, err := c.SignIn(context.Background(), &gasv1.SignInRequest{Email: "mfa@mail.ru", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.GetMfaRequired() || resp.GetMfaToken() != "mfa" || resp.GetMfaMethod() != constant.MFAMethodTOTP {
+		t.Errorf("Expected the mfa token for %v, got %v", constant.MFAMethodTOTP, resp)
+	}
+}
+
+func TestSignUp(t *testing.T) {
+	c := client(t)
+
+	data := []struct {
+		email string
+		code  codes.Code
+	}{
+		{"rupychman@mail.ru", codes.OK},
+		{"taken@mail.ru", codes.AlreadyExists},
+		{"invalid", codes.InvalidArgument},
+		{"down@mail.ru", codes.Internal},
+	}
+
+	for _, d := range data {
+		_, err := c.SignUp(context.Background(), &gasv1.SignUpRequest{Email: d.email, Password: "password"})
+		if status.Code(err) != d.code {
+			t.Errorf("%s: expected %v, got %v", d.email, d.code, status.Code(err))
+		}
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	c := client(t)
+
+	data := []struct {
+		name     string
+		mfaToken string
+		code     string
+		expected codes.Code
+	}{
+		{"correct", "mfa", "123456", codes.OK},
+		{"wrong code", "mfa", "000000", codes.Unauthenticated},
+		{"passkey", "passkey", "123456", codes.FailedPrecondition},
+	}
+
+	for _, d := range data {
+		resp, err := c.VerifyMFA(context.Background(), &gasv1.VerifyMFARequest{MfaToken: d.mfaToken, Code: d.code})
+		if status.Code(err) != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, status.Code(err))
+		}
+
+		if err == nil && (resp.GetAccessToken() != "access" || resp.GetRefreshToken() != "refresh") {
+			t.Errorf("%s: expected the token pair, got %v", d.name, resp)
+		}
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	c := client(t)
+
+	resp, err := c.Introspect(context.Background(), &gasv1.IntrospectRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.GetActive() || resp.GetUserId() != "1" || len(resp.GetRoles()) != 1 || resp.GetRoles()[0] != "admin" {
+		t.Errorf("Expected the active token of user 1 with the admin role, got %v", resp)
+	}
+
+	if resp.GetUserInfo().GetFields()["email"].GetStringValue() != "rupychman@mail.ru" {
+		t.Errorf("Expected %v, got %v", "rupychman@mail.ru", resp.GetUserInfo())
+	}
+
+	resp, err = c.Introspect(context.Background(), &gasv1.IntrospectRequest{Token: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetActive() {
+		t.Errorf("Expected an inactive token, got %v", resp)
+	}
+}
@@ -0,0 +1,126 @@
+package extauthz
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type Authorizer interface {
+	Authorize(ctx context.Context, cfg config.Config, host, token string) (*usecase.UserClaims, error)
+	AuthorizeRefresh(ctx context.Context, cfg config.Config, host, refreshToken string) (*usecase.UserClaims, error)
+}
+
+// Server is the envoy.service.auth.v3.Authorization service, it makes the same decision as forward-auth
+type Server struct {
+	auth.UnimplementedAuthorizationServer
+
+	log        *slog.Logger
+	cfg        config.Config
+	authorizer Authorizer
+}
+
+func New(log *slog.Logger, cfg config.Config, authorizer Authorizer) *Server {
+	return &Server{
+		log:        log.With(slog.String("component", "grpc-server/extauthz")),
+		cfg:        cfg,
+		authorizer: authorizer,
+	}
+}
+
+func (s *Server) Check(ctx context.Context, req *auth.CheckRequest) (*auth.CheckResponse, error) {
+	const op = "grpc.extauthz.Server.Check"
+
+	log := s.log.With(slog.String("op", op))
+
+	request := req.GetAttributes().GetRequest().GetHttp()
+
+	log = log.With(slog.String("request_id", request.GetId()))
+
+	token, refresh := token(request.GetHeaders())
+	if token == "" {
+		log.Info("token is missing")
+
+		return denied(codes.Unauthenticated, typev3.StatusCode_Unauthorized), nil
+	}
+
+	authorize := s.authorizer.Authorize
+	if refresh {
+		authorize = s.authorizer.AuthorizeRefresh
+	}
+
+	claims, err := authorize(ctx, s.cfg, request.GetHost(), token)
+	if errors.Is(err, usecase.ErrAccessDenied) {
+		log.Info("access denied", slog.String("host", request.GetHost()))
+
+		return denied(codes.PermissionDenied, typev3.StatusCode_Forbidden), nil
+	}
+	if err != nil {
+		log.Info("failed to verify token", sl.Err(err))
+
+		return denied(codes.Unauthenticated, typev3.StatusCode_Unauthorized), nil
+	}
+
+	return &auth.CheckResponse{
+		Status: &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &auth.CheckResponse_OkResponse{
+			OkResponse: &auth.OkHttpResponse{
+				// the headers replace the ones the client sent so the identity can't be spoofed
+				Headers: []*core.HeaderValueOption{
+					header(constant.UserIDHeader, claims.UserID()),
+					header(constant.UserEmailHeader, claims.Email()),
+					header(constant.UserRolesHeader, strings.Join(claims.Roles(), ",")),
+				},
+			},
+		},
+	}, nil
+}
+
+// token is the bearer token or the refresh cookie like in forward-auth, refresh tells that it is the cookie,
+// envoy sends the header names in lowercase
+func token(headers map[string]string) (string, bool) {
+	r := http.Request{Header: http.Header{}}
+
+	r.Header.Set("Authorization", headers["authorization"])
+	r.Header.Set("Cookie", headers["cookie"])
+
+	if t := mwAuth.BearerToken(&r); t != "" {
+		return t, false
+	}
+
+	if c, err := r.Cookie(constant.RefreshTokenCookie); err == nil {
+		return c.Value, true
+	}
+
+	return "", false
+}
+
+func header(key, value string) *core.HeaderValueOption {
+	return &core.HeaderValueOption{
+		Header:       &core.HeaderValue{Key: key, Value: value},
+		AppendAction: core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+	}
+}
+
+func denied(code codes.Code, httpStatus typev3.StatusCode) *auth.CheckResponse {
+	return &auth.CheckResponse{
+		Status: &status.Status{Code: int32(code)},
+		HttpResponse: &auth.CheckResponse_DeniedResponse{
+			DeniedResponse: &auth.DeniedHttpResponse{
+				Status: &typev3.HttpStatus{Code: httpStatus},
+			},
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package extauthz
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/usecase"
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/grpc/codes"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+type fakeAuthorizer struct{}
+
+func (fakeAuthorizer) Authorize(_ context.Context, _ config.Config, host, token string) (*usecase.UserClaims, error) {
+	if token != "good" {
+		return nil, errors.New("token is not valid")
+	}
+
+	if host == "grafana.example.com" {
+		return nil, usecase.ErrAccessDenied
+	}
+
+	return &usecase.UserClaims{UserInfo: map[string]interface{}{
+		"_id":   "1",
+		"email": "rupychman@mail.ru",
+		"roles": []interface{}{"user", "ops"},
+	}}, nil
+}
+
+func (a fakeAuthorizer) AuthorizeRefresh(ctx context.Context, cfg config.Config, host, refreshToken string) (*usecase.UserClaims, error) {
+	if refreshToken != "refresh" {
+		return nil, errors.New("refresh token is not valid")
+	}
+
+	return a.Authorize(ctx, cfg, host, "good")
+}
+
+func TestCheck(t *testing.T) {
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Config{}, fakeAuthorizer{})
+
+	data := []struct {
+		name    string
+		host    string
+		headers map[string]string
+		code    codes.Code
+	}{
+		{
+			name:    "bearer token",
+			host:    "blog.example.com",
+			headers: map[string]string{"authorization": "Bearer good"},
+			code:    codes.OK,
+		},
+		{
+			name:    "refresh cookie",
+			host:    "blog.example.com",
+			headers: map[string]string{"cookie": "theme=dark; refresh_token=refresh"},
+			code:    codes.OK,
+		},
+		{
+			name:    "access token in the refresh cookie",
+			host:    "blog.example.com",
+			headers: map[string]string{"cookie": "refresh_token=good"},
+			code:    codes.Unauthenticated,
+		},
+		{
+			name: "no token",
+			host: "blog.example.com",
+			code: codes.Unauthenticated,
+		},
+		{
+			name:    "bad token",
+			host:    "blog.example.com",
+			headers: map[string]string{"authorization": "Bearer bad"},
+			code:    codes.Unauthenticated,
+		},
+		{
+			name:    "missing role",
+			host:    "grafana.example.com",
+			headers: map[string]string{"authorization": "Bearer good"},
+			code:    codes.PermissionDenied,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp, err := s.Check(context.Background(), &auth.CheckRequest{
+				Attributes: &auth.AttributeContext{
+					Request: &auth.AttributeContext_Request{
+						Http: &auth.AttributeContext_HttpRequest{
+							Host:    d.host,
+							Headers: d.headers,
+						},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if codes.Code(resp.GetStatus().GetCode()) != d.code {
+				t.Fatalf("Expected %v, got %v", d.code, codes.Code(resp.GetStatus().GetCode()))
+			}
+
+			if d.code != codes.OK {
+				return
+			}
+
+			headers := map[string]string{}
+			for _, h := range resp.GetOkResponse().GetHeaders() {
+				headers[h.GetHeader().GetKey()] = h.GetHeader().GetValue()
+			}
+
+			if headers["X-User-Roles"] != "user,ops" {
+				t.Errorf("Expected %v, got %v", "user,ops", headers["X-User-Roles"])
+			}
+
+			if headers["X-User-Email"] != "rupychman@mail.ru" {
+				t.Errorf("Expected %v, got %v", "rupychman@mail.ru", headers["X-User-Email"])
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/audit/list"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Exporter interface {
+	ExportAudit(ctx context.Context, filter storage.AuditFilter, w io.Writer) error
+}
+
+// New streams the audit log matching the query filter as json lines, oldest first
+func New(log *slog.Logger, exporter Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.audit.export.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		filter, err := list.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Error("failed to parse query", sl.Err(err))
+
+			render.JSON(w, r, response.Error("invalid query"))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+
+		// the status is sent with the first line, a failure after it only cuts the export short
+		if err := exporter.ExportAudit(r.Context(), filter, w); err != nil {
+			log.Error("failed to export audit log", sl.Err(err))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package list
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Response struct {
+	Entries []storage.AuditEntry `json:"entries"`
+}
+
+type EntriesProvider interface {
+	AuditEntries(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error)
+}
+
+// New returns a page of the audit log, the newest entries first unless the after cursor is set
+func New(log *slog.Logger, entriesProvider EntriesProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.audit.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		filter, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Error("failed to parse query", sl.Err(err))
+
+			render.JSON(w, r, response.Error("invalid query"))
+
+			return
+		}
+
+		filter.Descending = filter.AfterSeq == 0
+
+		entries, err := entriesProvider.AuditEntries(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list audit entries", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to list audit entries"))
+
+			return
+		}
+
+		if entries == nil {
+			entries = []storage.AuditEntry{}
+		}
+
+		render.JSON(w, r, Response{
+			Entries: entries,
+		})
+	}
+}
+
+// ParseFilter reads the audit filter from the query, the export uses it too
+func ParseFilter(q url.Values) (storage.AuditFilter, error) {
+	var (
+		filter storage.AuditFilter
+		err    error
+	)
+
+	filter.Action = q.Get("action")
+	filter.ActorID = q.Get("actor_id")
+	filter.Target = q.Get("target")
+	filter.Outcome = q.Get("outcome")
+
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("after"); v != "" {
+		if filter.AfterSeq, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("before"); v != "" {
+		if filter.BeforeSeq, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,59 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/audit"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	Valid   bool   `json:"valid"`
+	Checked int64  `json:"checked"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Verifier interface {
+	VerifyAudit(ctx context.Context) (int64, error)
+}
+
+// New checks the hash chain of the whole audit log, checked is the number of entries before the break
+func New(log *slog.Logger, verifier Verifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.audit.verify.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		checked, err := verifier.VerifyAudit(r.Context())
+		if errors.Is(err, audit.ErrBrokenChain) {
+			log.Error("audit log is tampered with", slog.Int64("checked", checked), sl.Err(err))
+
+			render.JSON(w, r, Response{
+				Checked: checked,
+				Error:   "audit log chain is broken",
+			})
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to verify audit log", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to verify audit log"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Valid:   true,
+			Checked: checked,
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package list
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	APIKeys []storage.APIKey `json:"api_keys"`
+}
+
+type APIKeysProvider interface {
+	APIKeys(ctx context.Context, ownerID string) ([]storage.APIKey, error)
+}
+
+func New(log *slog.Logger, apiKeysProvider APIKeysProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.serviceaccounts.apikeys.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.ServiceAccountIDParam)
+
+		keys, err := apiKeysProvider.APIKeys(r.Context(), id)
+		if err != nil {
+			log.Error("failed to list api keys", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to list api keys"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			APIKeys: keys,
+		})
+	}
+}
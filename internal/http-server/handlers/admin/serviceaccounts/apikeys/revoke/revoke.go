@@ -0,0 +1,44 @@
+package revoke
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type APIKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, ownerID, id string) error
+}
+
+func New(log *slog.Logger, apiKeyRevoker APIKeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.serviceaccounts.apikeys.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.ServiceAccountIDParam)
+		keyID := chi.URLParam(r, constant.APIKeyIDParam)
+
+		if err := apiKeyRevoker.RevokeAPIKey(r.Context(), id, keyID); err != nil {
+			log.Error("failed to revoke api key", slog.String("id", id), slog.String("api_key_id", keyID), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to revoke api key"))
+
+			return
+		}
+
+		log.Info("api key revoked", slog.String("id", id), slog.String("api_key_id", keyID))
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -0,0 +1,72 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+type Response struct {
+	ID string `json:"id"`
+}
+
+type ServiceAccountCreator interface {
+	CreateServiceAccount(ctx context.Context, name, description string, roles []string) (string, error)
+}
+
+func New(log *slog.Logger, serviceAccountCreator ServiceAccountCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.serviceaccounts.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		id, err := serviceAccountCreator.CreateServiceAccount(r.Context(), req.Name, req.Description, req.Roles)
+		if err != nil {
+			log.Error("failed to create service account", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to create service account"))
+
+			return
+		}
+
+		log.Info("service account created", slog.String("id", id))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			ID: id,
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package get
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type ServiceAccountProvider interface {
+	ServiceAccount(ctx context.Context, id string) (storage.ServiceAccount, error)
+}
+
+func New(log *slog.Logger, serviceAccountProvider ServiceAccountProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.serviceaccounts.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.ServiceAccountIDParam)
+
+		account, err := serviceAccountProvider.ServiceAccount(r.Context(), id)
+		if err != nil {
+			log.Error("failed to get service account", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to get service account"))
+
+			return
+		}
+
+		render.JSON(w, r, account)
+	}
+}
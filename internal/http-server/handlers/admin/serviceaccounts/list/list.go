@@ -0,0 +1,44 @@
+package list
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	ServiceAccounts []storage.ServiceAccount `json:"service_accounts"`
+}
+
+type ServiceAccountsProvider interface {
+	ServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error)
+}
+
+func New(log *slog.Logger, serviceAccountsProvider ServiceAccountsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.serviceaccounts.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		accounts, err := serviceAccountsProvider.ServiceAccounts(r.Context())
+		if err != nil {
+			log.Error("failed to list service accounts", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to list service accounts"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			ServiceAccounts: accounts,
+		})
+	}
+}
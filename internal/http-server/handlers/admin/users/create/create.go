@@ -0,0 +1,73 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Email    string      `json:"email"`
+	Password string      `json:"password"`
+	UserInfo interface{} `json:"user_info"`
+}
+
+type Response struct {
+	ID string `json:"id"`
+}
+
+type UserCreator interface {
+	CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error)
+}
+
+func New(log *slog.Logger, userCreator UserCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.users.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		id, err := userCreator.CreateUser(r.Context(), req.Email, req.Password, req.UserInfo)
+		if err != nil {
+			log.Error("failed to create user", sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to create user"))
+
+			return
+		}
+
+		log.Info("user created", slog.String("id", id))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			ID: id,
+		})
+	}
+}
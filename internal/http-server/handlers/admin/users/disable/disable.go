@@ -0,0 +1,43 @@
+package disable
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type UserDisabler interface {
+	DisableUser(ctx context.Context, id string) error
+}
+
+func New(log *slog.Logger, userDisabler UserDisabler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.users.disable.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.UserIDParam)
+
+		if err := userDisabler.DisableUser(r.Context(), id); err != nil {
+			log.Error("failed to disable user", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to disable user"))
+
+			return
+		}
+
+		log.Info("user disabled", slog.String("id", id))
+
+		render.JSON(w, r, response.OK())
+	}
+}
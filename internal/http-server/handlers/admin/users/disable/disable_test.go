@@ -0,0 +1,61 @@
+package disable
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/enable"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDisableEnable(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	u := usecase.Usecase{Storage: s}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := chi.NewRouter()
+	router.Post(constant.UserDisableRoute, New(log, u))
+	router.Post(constant.UserEnableRoute, enable.New(log, u))
+
+	data := []struct {
+		path     string
+		expected int
+		locked   bool
+	}{
+		{"/users/" + id + "/disable", http.StatusOK, true},
+		{"/users/" + id + "/disable", http.StatusOK, true},
+		{"/users/" + id + "/enable", http.StatusOK, false},
+		{"/users/404/disable", http.StatusNotFound, false},
+		{"/users/404/enable", http.StatusNotFound, false},
+	}
+
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, d.path, nil))
+
+		if w.Code != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.path, d.expected, w.Code)
+		}
+
+		user, err := s.UserByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.Locked != d.locked {
+			t.Errorf("%s: expected locked %v, got %v", d.path, d.locked, user.Locked)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package get
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	s := memory.New()
+
+	id, err := s.CreateUser(context.Background(), "rupychman@mail.ru", "hash", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := chi.NewRouter()
+	router.Get(constant.UserRoute, New(slog.New(slog.NewTextHandler(io.Discard, nil)), usecase.Usecase{Storage: s}))
+
+	data := []struct {
+		name     string
+		id       string
+		expected int
+	}{
+		{"existing", id, http.StatusOK},
+		{"unknown", "404", http.StatusNotFound},
+	}
+
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+d.id, nil))
+
+		if w.Code != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, w.Code)
+		}
+	}
+}
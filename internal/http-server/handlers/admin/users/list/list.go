@@ -0,0 +1,114 @@
+package list
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Response struct {
+	Users []storage.User `json:"users"`
+	Total int64          `json:"total"`
+}
+
+type UsersProvider interface {
+	Users(ctx context.Context, filter storage.UserFilter) ([]storage.User, int64, error)
+}
+
+func New(log *slog.Logger, usersProvider UsersProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.users.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			log.Error("failed to parse query", sl.Err(err))
+
+			render.JSON(w, r, response.Error("invalid query"))
+
+			return
+		}
+
+		users, total, err := usersProvider.Users(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list users", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to list users"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Users: users,
+			Total: total,
+		})
+	}
+}
+
+func parseFilter(q url.Values) (storage.UserFilter, error) {
+	var (
+		filter storage.UserFilter
+		err    error
+	)
+
+	filter.EmailPrefix = q.Get("email_prefix")
+
+	if v := q.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("created_after"); v != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := q.Get("created_before"); v != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, err
+		}
+	}
+
+	if filter.Verified, err = parseBool(q.Get("verified")); err != nil {
+		return filter, err
+	}
+
+	if filter.Locked, err = parseBool(q.Get("locked")); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseBool(v string) (*bool, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
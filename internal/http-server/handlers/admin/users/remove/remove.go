@@ -0,0 +1,43 @@
+package remove
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type UserRemover interface {
+	DeleteUser(ctx context.Context, id string) error
+}
+
+func New(log *slog.Logger, userRemover UserRemover) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.users.remove.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.UserIDParam)
+
+		if err := userRemover.DeleteUser(r.Context(), id); err != nil {
+			log.Error("failed to delete user", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to delete user"))
+
+			return
+		}
+
+		log.Info("user deleted", slog.String("id", id))
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -0,0 +1,43 @@
+package unlock
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type UserUnlocker interface {
+	UnlockUser(ctx context.Context, id string) error
+}
+
+func New(log *slog.Logger, userUnlocker UserUnlocker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.users.unlock.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.UserIDParam)
+
+		if err := userUnlocker.UnlockUser(r.Context(), id); err != nil {
+			log.Error("failed to unlock user", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to unlock user"))
+
+			return
+		}
+
+		log.Info("user unlocked", slog.String("id", id))
+
+		render.JSON(w, r, response.OK())
+	}
+}
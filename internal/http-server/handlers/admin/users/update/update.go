@@ -0,0 +1,82 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Request replaces the user info unless only the roles are sent, the roles are kept when they are missing
+// and removed by an empty list
+type Request struct {
+	UserInfo interface{} `json:"user_info"`
+	Roles    []string    `json:"roles"`
+}
+
+type UserUpdater interface {
+	UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error
+	SetUserRoles(ctx context.Context, id string, roles []string) error
+}
+
+func New(log *slog.Logger, userUpdater UserUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.users.update.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.UserIDParam)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		if req.UserInfo != nil || req.Roles == nil {
+			if err := userUpdater.UpdateUserInfo(r.Context(), id, req.UserInfo); err != nil {
+				log.Error("failed to update user info", slog.String("id", id), sl.Err(err))
+
+				response.ErrorStatus(r, err)
+				render.JSON(w, r, response.Error("failed to update user info"))
+
+				return
+			}
+		}
+
+		if req.Roles != nil {
+			if err := userUpdater.SetUserRoles(r.Context(), id, req.Roles); err != nil {
+				log.Error("failed to set user roles", slog.String("id", id), sl.Err(err))
+
+				response.ErrorStatus(r, err)
+				render.JSON(w, r, response.Error("failed to set user roles"))
+
+				return
+			}
+		}
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -0,0 +1,93 @@
+package update
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", map[string]interface{}{"name": "Rupychman"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := chi.NewRouter()
+	router.Patch(constant.UserRoute, New(slog.New(slog.NewTextHandler(io.Discard, nil)), usecase.Usecase{Storage: s}))
+
+	data := []struct {
+		name     string
+		id       string
+		body     string
+		expected int
+		info     interface{}
+		roles    []string
+	}{
+		{
+			name:     "roles only",
+			id:       id,
+			body:     `{"roles": ["admin", "support"]}`,
+			expected: http.StatusOK,
+			info:     map[string]interface{}{"name": "Rupychman"},
+			roles:    []string{"admin", "support"},
+		},
+		{
+			name:     "user info only",
+			id:       id,
+			body:     `{"user_info": {"name": "Rupy"}}`,
+			expected: http.StatusOK,
+			info:     map[string]interface{}{"name": "Rupy"},
+			roles:    []string{"admin", "support"},
+		},
+		{
+			name:     "roles removed",
+			id:       id,
+			body:     `{"roles": []}`,
+			expected: http.StatusOK,
+			info:     map[string]interface{}{"name": "Rupy"},
+			roles:    []string{},
+		},
+		{
+			name:     "unknown user",
+			id:       "404",
+			body:     `{"roles": ["admin"]}`,
+			expected: http.StatusNotFound,
+			info:     map[string]interface{}{"name": "Rupy"},
+			roles:    []string{},
+		},
+	}
+
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/users/"+d.id, strings.NewReader(d.body)))
+
+		if w.Code != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, w.Code)
+		}
+
+		user, err := s.UserByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(user.UserInfo, d.info) {
+			t.Errorf("%s: expected user info %v, got %v", d.name, d.info, user.UserInfo)
+		}
+
+		if len(user.Roles) != len(d.roles) || (len(d.roles) > 0 && !reflect.DeepEqual(user.Roles, d.roles)) {
+			t.Errorf("%s: expected roles %v, got %v", d.name, d.roles, user.Roles)
+		}
+	}
+}
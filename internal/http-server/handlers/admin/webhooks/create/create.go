@@ -0,0 +1,75 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+}
+
+type Response struct {
+	ID string `json:"id"`
+	// Secret signs the payloads, it is shown only once
+	Secret string `json:"secret"`
+}
+
+type WebhookCreator interface {
+	CreateWebhook(ctx context.Context, url, description string, eventTypes []string) (string, string, error)
+}
+
+func New(log *slog.Logger, webhookCreator WebhookCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.webhooks.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		id, secret, err := webhookCreator.CreateWebhook(r.Context(), req.URL, req.Description, req.Events)
+		if err != nil {
+			log.Error("failed to create webhook", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to create webhook"))
+
+			return
+		}
+
+		log.Info("webhook created", slog.String("id", id))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			ID:     id,
+			Secret: secret,
+		})
+	}
+}
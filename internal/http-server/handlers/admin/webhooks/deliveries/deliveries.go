@@ -0,0 +1,64 @@
+package deliveries
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Response struct {
+	Deliveries []storage.WebhookDelivery `json:"deliveries"`
+}
+
+type DeliveriesProvider interface {
+	WebhookDeliveries(ctx context.Context, id string, limit int64) ([]storage.WebhookDelivery, error)
+}
+
+func New(log *slog.Logger, deliveriesProvider DeliveriesProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.webhooks.deliveries.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.WebhookIDParam)
+
+		var limit int64
+
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+
+			if limit, err = strconv.ParseInt(v, 10, 64); err != nil {
+				log.Error("failed to parse query", sl.Err(err))
+
+				render.JSON(w, r, response.Error("invalid query"))
+
+				return
+			}
+		}
+
+		deliveries, err := deliveriesProvider.WebhookDeliveries(r.Context(), id, limit)
+		if err != nil {
+			log.Error("failed to list webhook deliveries", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to list webhook deliveries"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Deliveries: deliveries,
+		})
+	}
+}
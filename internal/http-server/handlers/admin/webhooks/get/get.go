@@ -0,0 +1,43 @@
+package get
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type WebhookProvider interface {
+	Webhook(ctx context.Context, id string) (storage.Webhook, error)
+}
+
+func New(log *slog.Logger, webhookProvider WebhookProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.webhooks.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, constant.WebhookIDParam)
+
+		webhook, err := webhookProvider.Webhook(r.Context(), id)
+		if err != nil {
+			log.Error("failed to get webhook", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to get webhook"))
+
+			return
+		}
+
+		render.JSON(w, r, webhook)
+	}
+}
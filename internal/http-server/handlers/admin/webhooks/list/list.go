@@ -0,0 +1,44 @@
+package list
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	Webhooks []storage.Webhook `json:"webhooks"`
+}
+
+type WebhooksProvider interface {
+	Webhooks(ctx context.Context) ([]storage.Webhook, error)
+}
+
+func New(log *slog.Logger, webhooksProvider WebhooksProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.webhooks.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		webhooks, err := webhooksProvider.Webhooks(r.Context())
+		if err != nil {
+			log.Error("failed to list webhooks", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to list webhooks"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Webhooks: webhooks,
+		})
+	}
+}
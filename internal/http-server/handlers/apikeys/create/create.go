@@ -0,0 +1,112 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Request struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type Response struct {
+	// Key is shown only once
+	Key    string         `json:"key"`
+	APIKey storage.APIKey `json:"api_key"`
+}
+
+type APIKeyCreator interface {
+	CreateAPIKey(
+		ctx context.Context,
+		ownerType, ownerID, name string,
+		scopes []string,
+		expiresAt *time.Time,
+	) (string, storage.APIKey, error)
+}
+
+// New creates an api key of the signed in user, it is not allowed to api keys
+// so that a key can't get a key with more scopes
+func New(log *slog.Logger, apiKeyCreator APIKeyCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		if claims.APIKeyID() != "" {
+			log.Info("api key tried to create an api key", slog.String("api_key_id", claims.APIKeyID()))
+
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, response.Error(usecase.ErrAPIKeyNotAllowed.Error()))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		key, apiKey, err := apiKeyCreator.CreateAPIKey(
+			r.Context(),
+			constant.OwnerUser,
+			claims.UserID(),
+			req.Name,
+			req.Scopes,
+			req.ExpiresAt,
+		)
+		if err != nil {
+			log.Error("failed to create api key", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to create api key"))
+
+			return
+		}
+
+		log.Info("api key created", slog.String("id", apiKey.ID))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			Key:    key,
+			APIKey: apiKey,
+		})
+	}
+}
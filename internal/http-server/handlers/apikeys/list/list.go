@@ -0,0 +1,55 @@
+package list
+
+import (
+	"context"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	APIKeys []storage.APIKey `json:"api_keys"`
+}
+
+type APIKeysProvider interface {
+	APIKeys(ctx context.Context, ownerID string) ([]storage.APIKey, error)
+}
+
+// New lists the api keys of the signed in user
+func New(log *slog.Logger, apiKeysProvider APIKeysProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		keys, err := apiKeysProvider.APIKeys(r.Context(), claims.UserID())
+		if err != nil {
+			log.Error("failed to list api keys", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to list api keys"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			APIKeys: keys,
+		})
+	}
+}
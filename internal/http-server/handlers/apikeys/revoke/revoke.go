@@ -0,0 +1,54 @@
+package revoke
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type APIKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, ownerID, id string) error
+}
+
+// New revokes an api key of the signed in user
+func New(log *slog.Logger, apiKeyRevoker APIKeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		id := chi.URLParam(r, constant.APIKeyIDParam)
+
+		if err := apiKeyRevoker.RevokeAPIKey(r.Context(), claims.UserID(), id); err != nil {
+			log.Error("failed to revoke api key", slog.String("id", id), sl.Err(err))
+
+			response.ErrorStatus(r, err)
+			render.JSON(w, r, response.Error("failed to revoke api key"))
+
+			return
+		}
+
+		log.Info("api key revoked", slog.String("id", id))
+
+		render.JSON(w, r, response.OK())
+	}
+}
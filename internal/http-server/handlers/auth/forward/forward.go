@@ -0,0 +1,86 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type Authorizer interface {
+	Authorize(ctx context.Context, cfg config.Config, host, token string) (*usecase.UserClaims, error)
+	AuthorizeRefresh(ctx context.Context, cfg config.Config, host, refreshToken string) (*usecase.UserClaims, error)
+}
+
+// New answers nginx auth_request and traefik ForwardAuth, the token is the bearer token or the refresh cookie,
+// the user is passed to the upstream in the X-User-* headers. The refresh cookie lets browsers in without
+// an access token, it is checked against the current state of the user on every request. The host is taken
+// from X-Forwarded-Host only when the proxy headers are trusted, otherwise it is the Host of the request
+// to gas, which is the host of gas itself unless the proxy passes the original one
+func New(log *slog.Logger, cfg config.Config, authorizer Authorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.forward.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		authorize := authorizer.Authorize
+
+		token := mwAuth.BearerToken(r)
+		if token == "" {
+			if c, err := r.Cookie(constant.RefreshTokenCookie); err == nil {
+				token = c.Value
+				authorize = authorizer.AuthorizeRefresh
+			}
+		}
+
+		if token == "" {
+			log.Info("token is missing")
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		host := r.Host
+		if h := r.Header.Get(constant.ForwardedHostHeader); h != "" && cfg.TrustProxyHeaders {
+			host = h
+		}
+
+		claims, err := authorize(r.Context(), cfg, host, token)
+		if errors.Is(err, usecase.ErrAccessDenied) {
+			log.Info("access denied", slog.String("host", host))
+
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, response.Error("access denied"))
+
+			return
+		}
+		if err != nil {
+			log.Info("failed to verify token", sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		w.Header().Set(constant.UserIDHeader, claims.UserID())
+		w.Header().Set(constant.UserEmailHeader, claims.Email())
+		w.Header().Set(constant.UserRolesHeader, strings.Join(claims.Roles(), ","))
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -0,0 +1,113 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/usecase"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeAuthorizer lets the access token in only for blog.example.com and the refresh token for any host
+type fakeAuthorizer struct{}
+
+func (fakeAuthorizer) Authorize(_ context.Context, _ config.Config, host, token string) (*usecase.UserClaims, error) {
+	if token != "access" {
+		return nil, errors.New("token is not valid")
+	}
+
+	if host != "blog.example.com" {
+		return nil, usecase.ErrAccessDenied
+	}
+
+	return &usecase.UserClaims{UserInfo: map[string]interface{}{"_id": "1"}}, nil
+}
+
+func (fakeAuthorizer) AuthorizeRefresh(_ context.Context, _ config.Config, _, refreshToken string) (*usecase.UserClaims, error) {
+	if refreshToken != "refresh" {
+		return nil, errors.New("refresh token is not valid")
+	}
+
+	return &usecase.UserClaims{UserInfo: map[string]interface{}{"_id": "1"}}, nil
+}
+
+func TestForward(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var cfg config.Config
+
+	trusted := cfg
+	trusted.TrustProxyHeaders = true
+
+	data := []struct {
+		name          string
+		cfg           config.Config
+		authorization string
+		cookie        string
+		forwardedHost string
+		expected      int
+	}{
+		{
+			name:          "access token",
+			cfg:           cfg,
+			authorization: "Bearer access",
+			expected:      http.StatusOK,
+		},
+		{
+			name:     "refresh cookie",
+			cfg:      cfg,
+			cookie:   "refresh",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "access token in the refresh cookie",
+			cfg:      cfg,
+			cookie:   "access",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "no token",
+			cfg:      cfg,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:          "untrusted forwarded host",
+			cfg:           cfg,
+			authorization: "Bearer access",
+			forwardedHost: "grafana.example.com",
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "trusted forwarded host",
+			cfg:           trusted,
+			authorization: "Bearer access",
+			forwardedHost: "grafana.example.com",
+			expected:      http.StatusForbidden,
+		},
+	}
+
+	for _, d := range data {
+		r := httptest.NewRequest(http.MethodGet, "http://blog.example.com"+constant.ForwardRoute, nil)
+		if d.authorization != "" {
+			r.Header.Set("Authorization", d.authorization)
+		}
+		if d.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: constant.RefreshTokenCookie, Value: d.cookie})
+		}
+		if d.forwardedHost != "" {
+			r.Header.Set(constant.ForwardedHostHeader, d.forwardedHost)
+		}
+
+		w := httptest.NewRecorder()
+		New(log, d.cfg, fakeAuthorizer{}).ServeHTTP(w, r)
+
+		if w.Code != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package introspect
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Request struct {
+	Token string `json:"token"`
+}
+
+type Response struct {
+	Active   bool        `json:"active"`
+	UserID   string      `json:"user_id,omitempty"`
+	Email    string      `json:"email,omitempty"`
+	Roles    []string    `json:"roles,omitempty"`
+	APIKeyID string      `json:"api_key_id,omitempty"`
+	Scopes   []string    `json:"scopes,omitempty"`
+	Expires  *time.Time  `json:"expires_at,omitempty"`
+	UserInfo interface{} `json:"user_info,omitempty"`
+}
+
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, signingKey []byte, token string) (interface{}, error)
+}
+
+// New describes the access token or the api key, an invalid token is answered as not active
+func New(log *slog.Logger, cfg config.Config, tokenVerifier TokenVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.introspect.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		data, err := tokenVerifier.VerifyToken(r.Context(), cfg.SigningKey, req.Token)
+		if err != nil {
+			log.Info("token is not active", sl.Err(err))
+
+			render.JSON(w, r, Response{Active: false})
+
+			return
+		}
+
+		claims, ok := data.(*usecase.UserClaims)
+		if !ok {
+			log.Error("unexpected claims type")
+
+			render.JSON(w, r, Response{Active: false})
+
+			return
+		}
+
+		resp := Response{
+			Active:   true,
+			UserID:   claims.UserID(),
+			Email:    claims.Email(),
+			Roles:    claims.Roles(),
+			APIKeyID: claims.APIKeyID(),
+			Scopes:   claims.Scopes(),
+			UserInfo: claims.UserInfo,
+		}
+
+		if claims.ExpiresAt != nil {
+			resp.Expires = &claims.ExpiresAt.Time
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
@@ -0,0 +1,73 @@
+package send
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/binding"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Email string `json:"email"`
+}
+
+type MagicLinkSender interface {
+	SendMagicLink(ctx context.Context, cfg config.Config, email, binding string) error
+}
+
+// New always responds ok for a valid request so that it can not be used to find out registered emails
+func New(log *slog.Logger, cfg config.Config, sender MagicLinkSender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.magiclink.send.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		b, err := binding.Ensure(w, r, cfg.Env == constant.EnvProd)
+		if err != nil {
+			log.Error("failed to bind browser", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to send magic link"))
+
+			return
+		}
+
+		if err := sender.SendMagicLink(r.Context(), cfg, req.Email, b); err != nil {
+			log.Error("failed to send magic link", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to send magic link"))
+
+			return
+		}
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -0,0 +1,66 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/binding"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Token string `json:"token"`
+}
+
+type MagicLinkVerifier interface {
+	SigninWithMagicLink(ctx context.Context, cfg config.Config, token, binding string) (usecase.Tokens, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, verifier MagicLinkVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.magiclink.verify.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		tokens, err := verifier.SigninWithMagicLink(r.Context(), cfg, req.Token, binding.FromRequest(r))
+		if err != nil {
+			log.Error("failed to sign in with magic link", sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("magic link is not valid"))
+
+			return
+		}
+
+		signin.ResponseTokens(w, r, cfg, tokens)
+	}
+}
@@ -0,0 +1,71 @@
+package beginwebauthn
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type Response struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+type MFAStarter interface {
+	BeginWebAuthnMFA(ctx context.Context, cfg config.Config, mfaToken string) (usecase.WebAuthnCeremony, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, mfaStarter MFAStarter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.mfa.beginwebauthn.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		ceremony, err := mfaStarter.BeginWebAuthnMFA(r.Context(), cfg, req.MFAToken)
+		if err != nil {
+			log.Error("failed to begin passkey verification", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to begin passkey verification"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			SessionID: ceremony.SessionID,
+			Options:   ceremony.Options,
+		})
+	}
+}
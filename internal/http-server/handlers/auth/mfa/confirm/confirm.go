@@ -0,0 +1,80 @@
+package confirm
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Code string `json:"code"`
+}
+
+type Response struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TOTPConfirmer interface {
+	ConfirmTOTP(ctx context.Context, cfg config.Config, userID, code string) ([]string, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, totpConfirmer TOTPConfirmer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.mfa.confirm.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		codes, err := totpConfirmer.ConfirmTOTP(r.Context(), cfg, claims.UserID(), req.Code)
+		if err != nil {
+			log.Error("failed to confirm totp", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to confirm totp"))
+
+			return
+		}
+
+		log.Info("two-factor authentication enabled")
+
+		render.JSON(w, r, Response{
+			RecoveryCodes: codes,
+		})
+	}
+}
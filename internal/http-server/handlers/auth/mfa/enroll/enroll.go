@@ -0,0 +1,61 @@
+package enroll
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// QRCode is a data url of the png image
+	QRCode string `json:"qr_code"`
+}
+
+type TOTPEnroller interface {
+	EnrollTOTP(ctx context.Context, cfg config.Config, userID string) (usecase.TOTPEnrollment, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, totpEnroller TOTPEnroller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.mfa.enroll.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		enrollment, err := totpEnroller.EnrollTOTP(r.Context(), cfg, claims.UserID())
+		if err != nil {
+			log.Error("failed to enroll totp", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to enroll totp"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Secret:          enrollment.Secret,
+			ProvisioningURI: enrollment.URI,
+			QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(enrollment.QRCode),
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/clientip"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	MFAToken string `json:"mfa_token"`
+	// Code is a totp code or a recovery code
+	Code string `json:"code"`
+}
+
+type MFAVerifier interface {
+	VerifyMFA(ctx context.Context, cfg config.Config, mfaToken, code, ip string) (usecase.Tokens, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, mfaVerifier MFAVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.mfa.verify.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		tokens, err := mfaVerifier.VerifyMFA(r.Context(), cfg, req.MFAToken, req.Code, clientip.FromRequest(r))
+		if errors.Is(err, usecase.ErrTemporarilyLocked) {
+			log.Warn("mfa verification is temporarily locked")
+
+			render.Status(r, http.StatusTooManyRequests)
+			render.JSON(w, r, response.Error(usecase.ErrTemporarilyLocked.Error()))
+
+			return
+		}
+		if errors.Is(err, usecase.ErrPasskeyRequired) {
+			log.Info("mfa verification needs a passkey")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(usecase.ErrPasskeyRequired.Error()))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to verify mfa", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to verify mfa"))
+
+			return
+		}
+
+		signin.SetRefreshCookie(w, cfg, tokens.Refresh)
+
+		render.JSON(w, r, signin.Response{
+			AccessToken: tokens.Access,
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package begin
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/binding"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type SocialLoginStarter interface {
+	BeginSocialLogin(ctx context.Context, cfg config.Config, provider, binding string) (string, error)
+}
+
+// New redirects the user to the sign in page of the provider
+func New(log *slog.Logger, cfg config.Config, starter SocialLoginStarter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.oauth.begin.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		provider := chi.URLParam(r, constant.OAuthProviderParam)
+
+		b, err := binding.Ensure(w, r, cfg.Env == constant.EnvProd)
+		if err != nil {
+			log.Error("failed to bind browser", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to start sign in"))
+
+			return
+		}
+
+		authURL, err := starter.BeginSocialLogin(r.Context(), cfg, provider, b)
+		if errors.Is(err, usecase.ErrUnknownProvider) {
+			log.Warn("unknown provider", slog.String("provider", provider))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(usecase.ErrUnknownProvider.Error()))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to start sign in", slog.String("provider", provider), sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to start sign in"))
+
+			return
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
@@ -0,0 +1,68 @@
+package callback
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/binding"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type SocialLoginFinisher interface {
+	FinishSocialLogin(ctx context.Context, cfg config.Config, provider, code, state, binding string) (usecase.Tokens, error)
+}
+
+// New handles the redirect back from the provider
+func New(log *slog.Logger, cfg config.Config, finisher SocialLoginFinisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.oauth.callback.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		provider := chi.URLParam(r, constant.OAuthProviderParam)
+		query := r.URL.Query()
+
+		if providerErr := query.Get("error"); providerErr != "" {
+			log.Warn("provider returned an error",
+				slog.String("provider", provider),
+				slog.String("error", providerErr),
+				slog.String("description", query.Get("error_description")),
+			)
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("sign in was cancelled"))
+
+			return
+		}
+
+		tokens, err := finisher.FinishSocialLogin(
+			r.Context(),
+			cfg,
+			provider,
+			query.Get("code"),
+			query.Get("state"),
+			binding.FromRequest(r),
+		)
+		if err != nil {
+			log.Error("failed to sign in", slog.String("provider", provider), sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("failed to sign in"))
+
+			return
+		}
+
+		signin.ResponseTokens(w, r, cfg, tokens)
+	}
+}
@@ -0,0 +1,75 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/binding"
+	"github.com/degeboman/gas/internal/lib/api/clientip"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Code string `json:"code"`
+}
+
+type OTPVerifier interface {
+	SigninWithOTP(ctx context.Context, cfg config.Config, code, binding, ip string) (usecase.Tokens, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, verifier OTPVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.otp.verify.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		tokens, err := verifier.SigninWithOTP(r.Context(), cfg, req.Code, binding.FromRequest(r), clientip.FromRequest(r))
+		if errors.Is(err, usecase.ErrTemporarilyLocked) {
+			log.Warn("sign in with code is temporarily locked")
+
+			render.Status(r, http.StatusTooManyRequests)
+			render.JSON(w, r, response.Error(usecase.ErrTemporarilyLocked.Error()))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to sign in with code", sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("code is not valid"))
+
+			return
+		}
+
+		signin.ResponseTokens(w, r, cfg, tokens)
+	}
+}
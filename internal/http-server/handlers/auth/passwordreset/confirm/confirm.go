@@ -0,0 +1,61 @@
+package confirm
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type PasswordResetter interface {
+	ResetPassword(ctx context.Context, token, password string) error
+}
+
+func New(log *slog.Logger, resetter PasswordResetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.passwordreset.confirm.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		if err := resetter.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+			log.Error("failed to reset password", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to reset password"))
+
+			return
+		}
+
+		render.JSON(w, r, response.OK())
+	}
+}
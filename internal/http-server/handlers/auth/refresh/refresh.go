@@ -1,6 +1,7 @@
 package refresh
 
 import (
+	"context"
 	"errors"
 	"github.com/degeboman/gas/internal/config"
 	"github.com/degeboman/gas/internal/lib/api/response"
@@ -21,7 +22,7 @@ type Response struct {
 }
 
 type providerRefresh interface {
-	RefreshToken(cfg config.Config, refreshToken string) (string, error)
+	RefreshToken(ctx context.Context, cfg config.Config, refreshToken string) (string, error)
 }
 
 func New(log *slog.Logger, cfg config.Config, providerRefresh providerRefresh) http.HandlerFunc {
@@ -54,7 +55,7 @@ func New(log *slog.Logger, cfg config.Config, providerRefresh providerRefresh) h
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		access, err := providerRefresh.RefreshToken(cfg, req.RefreshToken)
+		access, err := providerRefresh.RefreshToken(r.Context(), cfg, req.RefreshToken)
 
 		if err != nil {
 			log.Error("failed to sign in", sl.Err(err))
@@ -0,0 +1,50 @@
+package acs
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type SAMLLoginFinisher interface {
+	FinishSAMLLogin(ctx context.Context, cfg config.Config, samlResponse, relayState string) (usecase.Tokens, error)
+}
+
+// New is the assertion consumer service the identity provider posts the response to
+func New(log *slog.Logger, cfg config.Config, finisher SAMLLoginFinisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.saml.acs.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := r.ParseForm(); err != nil {
+			log.Error("failed to parse form", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		tokens, err := finisher.FinishSAMLLogin(r.Context(), cfg, r.PostForm.Get("SAMLResponse"), r.PostForm.Get("RelayState"))
+		if err != nil {
+			log.Error("failed to sign in", sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("failed to sign in"))
+
+			return
+		}
+
+		signin.ResponseTokens(w, r, cfg, tokens)
+	}
+}
@@ -0,0 +1,47 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type SAMLLoginStarter interface {
+	BeginSAMLLogin(ctx context.Context, cfg config.Config) (string, error)
+}
+
+// New redirects the user to the identity provider
+func New(log *slog.Logger, cfg config.Config, starter SAMLLoginStarter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.saml.login.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		redirectURL, err := starter.BeginSAMLLogin(r.Context(), cfg)
+		if errors.Is(err, usecase.ErrSAMLDisabled) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(usecase.ErrSAMLDisabled.Error()))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to start sign in", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to start sign in"))
+
+			return
+		}
+
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+	}
+}
@@ -0,0 +1,45 @@
+package metadata
+
+import (
+	"errors"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type MetadataProvider interface {
+	SAMLMetadata() ([]byte, error)
+}
+
+func New(log *slog.Logger, metadataProvider MetadataProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.saml.metadata.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		metadata, err := metadataProvider.SAMLMetadata()
+		if errors.Is(err, usecase.ErrSAMLDisabled) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(usecase.ErrSAMLDisabled.Error()))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get metadata", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to get metadata"))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/samlmetadata+xml")
+		_, _ = w.Write(metadata)
+	}
+}
@@ -1,10 +1,14 @@
 package signin
 
 import (
+	"context"
 	"errors"
+	"github.com/degeboman/gas/constant"
 	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/clientip"
 	"github.com/degeboman/gas/internal/lib/api/response"
 	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"io"
@@ -17,13 +21,20 @@ type Response struct {
 	AccessToken string `json:"access_token"`
 }
 
+type MFAResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	// MFAMethod is the second factor to complete the sign in with: totp or webauthn
+	MFAMethod string `json:"mfa_method"`
+}
+
 type Request struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
 type LoginProvider interface {
-	Signin(cgf config.Config, email, password string) (access string, refresh string, err error)
+	Signin(ctx context.Context, cgf config.Config, email, password, ip string) (usecase.Tokens, error)
 }
 
 func New(log *slog.Logger, cfg config.Config, loginProvider LoginProvider) http.HandlerFunc {
@@ -56,14 +67,15 @@ func New(log *slog.Logger, cfg config.Config, loginProvider LoginProvider) http.
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		access, refresh, err := loginProvider.Signin(cfg, req.Email, req.Password)
+		tokens, err := loginProvider.Signin(r.Context(), cfg, req.Email, req.Password, clientip.FromRequest(r))
+		if errors.Is(err, usecase.ErrTemporarilyLocked) {
+			log.Warn("sign in is temporarily locked", slog.String("email", req.Email))
 
-		http.SetCookie(w, &http.Cookie{
-			Name:    "refresh_token",
-			Value:   refresh,
-			Expires: time.Now().Add(cfg.RefreshDuration),
-		})
+			render.Status(r, http.StatusTooManyRequests)
+			render.JSON(w, r, response.Error(usecase.ErrTemporarilyLocked.Error()))
 
+			return
+		}
 		if err != nil {
 			log.Error("failed to sign in", sl.Err(err))
 
@@ -72,7 +84,52 @@ func New(log *slog.Logger, cfg config.Config, loginProvider LoginProvider) http.
 			return
 		}
 
-		responseOK(w, r, access)
+		ResponseTokens(w, r, cfg, tokens)
+	}
+}
+
+// ResponseTokens writes either the mfa challenge or the access token with the refresh cookie,
+// it is shared by the handlers that sign in
+func ResponseTokens(w http.ResponseWriter, r *http.Request, cfg config.Config, tokens usecase.Tokens) {
+	if tokens.MFA != "" {
+		render.JSON(w, r, MFAResponse{
+			MFARequired: true,
+			MFAToken:    tokens.MFA,
+			MFAMethod:   tokens.MFAMethod,
+		})
+
+		return
+	}
+
+	SetRefreshCookie(w, cfg, tokens.Refresh)
+
+	responseOK(w, r, tokens.Access)
+}
+
+// SetRefreshCookie is shared by the handlers that complete a sign in, scripts can't read the cookie
+// and it is sent only over https in production
+func SetRefreshCookie(w http.ResponseWriter, cfg config.Config, refresh string) {
+	http.SetCookie(w, refreshCookie(cfg, refresh, time.Now().Add(cfg.RefreshDuration)))
+}
+
+// ClearRefreshCookie removes the cookie set by SetRefreshCookie, the path and the domain must be the same
+func ClearRefreshCookie(w http.ResponseWriter, cfg config.Config) {
+	c := refreshCookie(cfg, "", time.Unix(0, 0))
+	c.MaxAge = -1
+
+	http.SetCookie(w, c)
+}
+
+func refreshCookie(cfg config.Config, refresh string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     constant.RefreshTokenCookie,
+		Value:    refresh,
+		Path:     "/",
+		Domain:   cfg.RefreshCookieDomain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   cfg.Env == constant.EnvProd,
+		SameSite: http.SameSiteLaxMode,
 	}
 }
 
@@ -0,0 +1,44 @@
+package signin
+
+import (
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRefreshCookie(t *testing.T) {
+	var cfg config.Config
+
+	cfg.Env = constant.EnvProd
+	cfg.RefreshDuration = time.Hour
+	cfg.RefreshCookieDomain = "example.com"
+
+	w := httptest.NewRecorder()
+	SetRefreshCookie(w, cfg, "refresh")
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected 1 cookie, got %d", len(cookies))
+	}
+
+	c := cookies[0]
+
+	if c.Value != "refresh" || c.Path != "/" || c.Domain != "example.com" {
+		t.Errorf("Expected the refresh cookie of example.com at /, got %v", c)
+	}
+
+	if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected an http only, secure and same site cookie, got %v", c)
+	}
+
+	w = httptest.NewRecorder()
+	ClearRefreshCookie(w, cfg)
+
+	cookies = w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge >= 0 || cookies[0].Domain != "example.com" || cookies[0].Path != "/" {
+		t.Errorf("Expected the cookie of example.com at / to be removed, got %v", cookies)
+	}
+}
@@ -0,0 +1,67 @@
+package signout
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type SignOuter interface {
+	SignOut(ctx context.Context, cfg config.Config, refreshToken string) error
+}
+
+// New revokes the refresh token from the body or from the refresh cookie and clears the cookie
+func New(log *slog.Logger, cfg config.Config, signOuter SignOuter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.signout.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		// the body is optional, browsers sign out by the cookie
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		if req.RefreshToken == "" {
+			if c, err := r.Cookie(constant.RefreshTokenCookie); err == nil {
+				req.RefreshToken = c.Value
+			}
+		}
+
+		signin.ClearRefreshCookie(w, cfg)
+
+		if err := signOuter.SignOut(r.Context(), cfg, req.RefreshToken); err != nil {
+			log.Info("failed to sign out", sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		render.JSON(w, r, response.OK())
+	}
+}
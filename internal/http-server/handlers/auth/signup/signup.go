@@ -1,6 +1,7 @@
 package signup
 
 import (
+	"context"
 	"errors"
 	"github.com/degeboman/gas/internal/lib/api/response"
 	"github.com/degeboman/gas/internal/lib/logger/sl"
@@ -25,7 +26,7 @@ type Request struct {
 }
 
 type UserCreator interface {
-	CreateUser(email, password string, userInfo interface{}) (string, error)
+	CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error)
 }
 
 func New(log *slog.Logger, userCreator UserCreator) http.HandlerFunc {
@@ -59,10 +60,11 @@ func New(log *slog.Logger, userCreator UserCreator) http.HandlerFunc {
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		_, err = userCreator.CreateUser(req.Email, req.Password, req.UserInfo)
+		_, err = userCreator.CreateUser(r.Context(), req.Email, req.Password, req.UserInfo)
 		if err != nil {
 			log.Error("failed to sign up", sl.Err(err))
 
+			response.ErrorStatus(r, err)
 			render.JSON(w, r, response.Error("failed to sign up"+sl.Err(err).String()))
 
 			return
@@ -0,0 +1,61 @@
+package unlock
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Token string `json:"token"`
+}
+
+type Unlocker interface {
+	Unlock(ctx context.Context, cfg config.Config, token string) error
+}
+
+func New(log *slog.Logger, cfg config.Config, unlocker Unlocker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.unlock.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		if err := unlocker.Unlock(r.Context(), cfg, req.Token); err != nil {
+			log.Error("failed to unlock account", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to unlock account"))
+
+			return
+		}
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -1,6 +1,7 @@
 package verify
 
 import (
+	"context"
 	"errors"
 	"github.com/degeboman/gas/internal/config"
 	"github.com/degeboman/gas/internal/lib/api/response"
@@ -17,7 +18,7 @@ type Request struct {
 }
 
 type ProviderVerify interface {
-	VerifyToken(signingKey []byte, token string) (interface{}, error)
+	VerifyToken(ctx context.Context, signingKey []byte, token string) (interface{}, error)
 }
 
 func New(log *slog.Logger, cfg config.Config, providerVerify ProviderVerify) http.HandlerFunc {
@@ -50,7 +51,7 @@ func New(log *slog.Logger, cfg config.Config, providerVerify ProviderVerify) htt
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		_, err = providerVerify.VerifyToken(cfg.SigningKey, req.Token)
+		_, err = providerVerify.VerifyToken(r.Context(), cfg.SigningKey, req.Token)
 		if err != nil {
 			log.Error("failed to verify token", sl.Err(err))
 
@@ -0,0 +1,65 @@
+package beginlogin
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	// Email is optional, without it the client picks any discoverable passkey
+	Email string `json:"email"`
+}
+
+type Response struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+type LoginStarter interface {
+	BeginWebAuthnLogin(ctx context.Context, cfg config.Config, email string) (usecase.WebAuthnCeremony, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, loginStarter LoginStarter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.webauthn.beginlogin.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		// the body is optional
+		if err := render.DecodeJSON(r.Body, &req); err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		ceremony, err := loginStarter.BeginWebAuthnLogin(r.Context(), cfg, req.Email)
+		if err != nil {
+			log.Error("failed to begin passkey login", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to begin passkey login"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			SessionID: ceremony.SessionID,
+			Options:   ceremony.Options,
+		})
+	}
+}
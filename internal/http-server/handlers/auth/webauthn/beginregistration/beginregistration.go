@@ -0,0 +1,57 @@
+package beginregistration
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+type RegistrationStarter interface {
+	BeginWebAuthnRegistration(ctx context.Context, cfg config.Config, userID string) (usecase.WebAuthnCeremony, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, registrationStarter RegistrationStarter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.webauthn.beginregistration.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		ceremony, err := registrationStarter.BeginWebAuthnRegistration(r.Context(), cfg, claims.UserID())
+		if err != nil {
+			log.Error("failed to begin passkey registration", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to begin passkey registration"))
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			SessionID: ceremony.SessionID,
+			Options:   ceremony.Options,
+		})
+	}
+}
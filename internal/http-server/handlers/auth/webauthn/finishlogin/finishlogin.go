@@ -0,0 +1,71 @@
+package finishlogin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	SessionID string `json:"session_id"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.get
+	Credential json.RawMessage `json:"credential"`
+}
+
+type LoginFinisher interface {
+	FinishWebAuthnLogin(ctx context.Context, cfg config.Config, sessionID string, credential []byte) (usecase.Tokens, error)
+}
+
+func New(log *slog.Logger, cfg config.Config, loginFinisher LoginFinisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.webauthn.finishlogin.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		tokens, err := loginFinisher.FinishWebAuthnLogin(r.Context(), cfg, req.SessionID, req.Credential)
+		if err != nil {
+			log.Error("failed to finish passkey login", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to finish passkey login"))
+
+			return
+		}
+
+		signin.SetRefreshCookie(w, cfg, tokens.Refresh)
+
+		render.JSON(w, r, signin.Response{
+			AccessToken: tokens.Access,
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package finishregistration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	SessionID string `json:"session_id"`
+	// SecondFactor makes the passkey required after the password on sign in
+	SecondFactor bool `json:"second_factor"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.create
+	Credential json.RawMessage `json:"credential"`
+}
+
+type RegistrationFinisher interface {
+	FinishWebAuthnRegistration(
+		ctx context.Context,
+		cfg config.Config,
+		userID, sessionID string,
+		credential []byte,
+		secondFactor bool,
+	) error
+}
+
+func New(log *slog.Logger, cfg config.Config, registrationFinisher RegistrationFinisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.webauthn.finishregistration.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		claims, ok := mwAuth.Claims(r.Context())
+		if !ok {
+			log.Error("claims are missing")
+
+			render.JSON(w, r, response.Error("unauthorized"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, response.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to decode request"))
+
+			return
+		}
+
+		err = registrationFinisher.FinishWebAuthnRegistration(
+			r.Context(),
+			cfg,
+			claims.UserID(),
+			req.SessionID,
+			req.Credential,
+			req.SecondFactor,
+		)
+		if err != nil {
+			log.Error("failed to finish passkey registration", sl.Err(err))
+
+			render.JSON(w, r, response.Error("failed to finish passkey registration"))
+
+			return
+		}
+
+		log.Info("passkey registered")
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -0,0 +1,21 @@
+package jwks
+
+import (
+	"github.com/go-chi/render"
+	"github.com/go-jose/go-jose/v3"
+	"log/slog"
+	"net/http"
+)
+
+type KeySetProvider interface {
+	JWKS() jose.JSONWebKeySet
+}
+
+// New serves the public keys access tokens are signed with, the clients cache them for a while
+func New(log *slog.Logger, keySetProvider KeySetProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+
+		render.JSON(w, r, keySetProvider.JWKS())
+	}
+}
@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"github.com/degeboman/gas/internal/lib/api/clientip"
+	"github.com/degeboman/gas/internal/lib/audit"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+)
+
+// New stores the client ip, the user agent and the request id for the audit log, must be mounted
+// after the chi RequestID and RealIP middlewares
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := audit.WithRequest(r.Context(), audit.Request{
+				IP:        clientip.FromRequest(r),
+				UserAgent: r.UserAgent(),
+				RequestID: middleware.GetReqID(r.Context()),
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
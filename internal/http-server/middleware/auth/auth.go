@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/audit"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type claimsKey struct{}
+
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, signingKey []byte, token string) (interface{}, error)
+}
+
+// New authenticates the request by the bearer access token and stores its claims in the request context
+func New(log *slog.Logger, cfg config.Config, verifier TokenVerifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			token := BearerToken(r)
+			if token == "" {
+				log.Info("bearer token is missing")
+
+				unauthorized(w, r)
+
+				return
+			}
+
+			data, err := verifier.VerifyToken(r.Context(), cfg.SigningKey, token)
+			if err != nil {
+				log.Info("failed to verify token", sl.Err(err))
+
+				unauthorized(w, r)
+
+				return
+			}
+
+			claims, ok := data.(*usecase.UserClaims)
+			if !ok {
+				log.Error("unexpected claims type")
+
+				unauthorized(w, r)
+
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), claimsKey{}, claims)
+			ctx = audit.WithActor(ctx, claims.UserID(), claims.APIKeyID())
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Admin allows only users with the admin role or listed in the admin settings, api keys need the admin scope too,
+// must be mounted after New
+func Admin(log *slog.Logger, cfg config.Config) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := Claims(r.Context())
+			if !ok || !isAdmin(cfg, claims) || !claims.HasScope(constant.ScopeAdmin) {
+				log.Info("access denied",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error("access denied"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// UserOnly rejects api keys, so that a leaked key can't enroll a second factor, register a passkey
+// or manage api keys of its owner, must be mounted after New
+func UserOnly(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := Claims(r.Context())
+			if !ok || claims.APIKeyID() != "" {
+				log.Info("api key is not allowed",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(usecase.ErrAPIKeyNotAllowed.Error()))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func Claims(ctx context.Context) (*usecase.UserClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*usecase.UserClaims)
+	return claims, ok
+}
+
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func isAdmin(cfg config.Config, claims *usecase.UserClaims) bool {
+	for _, role := range claims.Roles() {
+		if role == constant.AdminRole {
+			return true
+		}
+	}
+
+	email := claims.Email()
+	for _, e := range cfg.Admin.Emails {
+		if email != "" && strings.EqualFold(e, email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, response.Error("unauthorized"))
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/usecase"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeVerifier map[string]*usecase.UserClaims
+
+func (v fakeVerifier) VerifyToken(_ context.Context, _ []byte, token string) (interface{}, error) {
+	claims, ok := v[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+func claims(info map[string]interface{}) *usecase.UserClaims {
+	return &usecase.UserClaims{UserInfo: info}
+}
+
+func TestAdmin(t *testing.T) {
+	var cfg config.Config
+
+	cfg.Admin.Emails = []string{"Root@mail.ru"}
+
+	verifier := fakeVerifier{
+		"user":  claims(map[string]interface{}{"_id": "1", "email": "rupychman@mail.ru"}),
+		"admin": claims(map[string]interface{}{"_id": "2", "roles": []interface{}{constant.AdminRole}}),
+		"email": claims(map[string]interface{}{"_id": "3", "email": "root@mail.ru"}),
+		"key": claims(map[string]interface{}{
+			"_id": "2", "roles": []interface{}{constant.AdminRole}, "api_key_id": "k1",
+		}),
+		"admin key": claims(map[string]interface{}{
+			"_id": "2", "roles": []interface{}{constant.AdminRole}, "api_key_id": "k2",
+			"scopes": []interface{}{constant.ScopeAdmin},
+		}),
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := New(log, cfg, verifier)(Admin(log, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	data := []struct {
+		token    string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"forged", http.StatusUnauthorized},
+		{"user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+		{"email", http.StatusOK},
+		{"key", http.StatusForbidden},
+		{"admin key", http.StatusOK},
+	}
+
+	for _, d := range data {
+		r := httptest.NewRequest(http.MethodGet, constant.AdminRoute+constant.UsersRoute, nil)
+		if d.token != "" {
+			r.Header.Set("Authorization", "Bearer "+d.token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != d.expected {
+			t.Errorf("%q: expected %v, got %v", d.token, d.expected, w.Code)
+		}
+	}
+}
+
+func TestUserOnly(t *testing.T) {
+	var cfg config.Config
+
+	verifier := fakeVerifier{
+		"user": claims(map[string]interface{}{"_id": "1"}),
+		"key":  claims(map[string]interface{}{"_id": "1", "api_key_id": "k1"}),
+		"admin key": claims(map[string]interface{}{
+			"_id": "2", "roles": []interface{}{constant.AdminRole}, "api_key_id": "k2",
+			"scopes": []interface{}{constant.ScopeAdmin},
+		}),
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := New(log, cfg, verifier)(UserOnly(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	data := []struct {
+		token    string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"user", http.StatusOK},
+		{"key", http.StatusForbidden},
+		{"admin key", http.StatusForbidden},
+	}
+
+	for _, d := range data {
+		r := httptest.NewRequest(http.MethodPost, constant.MFARoute+constant.TOTPEnrollRoute, nil)
+		if d.token != "" {
+			r.Header.Set("Authorization", "Bearer "+d.token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != d.expected {
+			t.Errorf("%q: expected %v, got %v", d.token, d.expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
+	"github.com/degeboman/gas/internal/lib/api/clientip"
+	"github.com/degeboman/gas/internal/lib/api/response"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func New(
+	log *slog.Logger,
+	cfg config.Config,
+	store storage.RateLimitStorage,
+	verifier mwAuth.TokenVerifier,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/ratelimit"),
+		)
+
+		log.Info("rate limit middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			route := routePattern(r)
+
+			rule, ok := cfg.RateLimit.Routes[route]
+			if route == "" || !ok {
+				route = "default"
+				rule = cfg.RateLimit.Default
+			}
+
+			if rule.Limit <= 0 || rule.Period <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := route + ":" + clientKey(r, cfg, rule.Key, verifier)
+
+			res, err := store.Take(r.Context(), key, storage.Rate{
+				Limit:  rule.Limit,
+				Period: rule.Period,
+			})
+			if err != nil {
+				// the limiter must not take the service down with it
+				log.Error("failed to take a token",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					sl.Err(err),
+				)
+
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(res.ResetAfter))
+
+			if !res.Allowed {
+				log.Warn("rate limit exceeded",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("key", key),
+				)
+
+				w.Header().Set("Retry-After", seconds(res.RetryAfter))
+
+				render.Status(r, http.StatusTooManyRequests)
+				render.JSON(w, r, response.Error("too many requests"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// clientKey tells clients apart by the user of the access token when the rule is keyed by user and by the service
+// account or the api key when it is keyed by client, the requests without a valid token and the other rules are
+// keyed by the ip
+func clientKey(r *http.Request, cfg config.Config, key string, verifier mwAuth.TokenVerifier) string {
+	if key == constant.RateLimitKeyUser || key == constant.RateLimitKeyClient {
+		if claims := verifiedClaims(r, cfg, verifier); claims != nil {
+			switch {
+			case key == constant.RateLimitKeyUser && claims.UserID() != "":
+				return "user:" + claims.UserID()
+			case key == constant.RateLimitKeyClient && claims.ServiceAccount() && claims.UserID() != "":
+				return "service_account:" + claims.UserID()
+			case key == constant.RateLimitKeyClient && claims.APIKeyID() != "":
+				return "api_key:" + claims.APIKeyID()
+			}
+		}
+	}
+
+	return "ip:" + clientip.FromRequest(r)
+}
+
+func verifiedClaims(r *http.Request, cfg config.Config, verifier mwAuth.TokenVerifier) *usecase.UserClaims {
+	token := mwAuth.BearerToken(r)
+	if token == "" {
+		return nil
+	}
+
+	data, err := verifier.VerifyToken(r.Context(), cfg.SigningKey, token)
+	if err != nil {
+		return nil
+	}
+
+	claims, _ := data.(*usecase.UserClaims)
+
+	return claims
+}
+
+// routePattern finds the route the router will take for the request, e.g. /admin/users/{id}, the middleware runs
+// before the routing so the pattern is not in the route context yet. It is empty when no route matches
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+
+	path := rctx.RoutePath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return ""
+	}
+
+	return tctx.RoutePattern()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/degeboman/gas/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeVerifier struct{}
+
+// VerifyToken takes the token for the user id, the key tokens are the api keys of alice and the keys of
+// the service account
+func (fakeVerifier) VerifyToken(_ context.Context, _ []byte, token string) (interface{}, error) {
+	switch {
+	case token == "forged":
+		return nil, errors.New("invalid token")
+	case strings.HasPrefix(token, "key_"):
+		return &usecase.UserClaims{UserInfo: map[string]interface{}{"_id": "alice", "api_key_id": token}}, nil
+	case strings.HasPrefix(token, "sa_"):
+		return &usecase.UserClaims{UserInfo: map[string]interface{}{
+			"_id":             "robot",
+			"api_key_id":      token,
+			"service_account": true,
+		}}, nil
+	}
+
+	return &usecase.UserClaims{UserInfo: map[string]interface{}{"_id": token}}, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	var cfg config.Config
+
+	cfg.RateLimit = config.RateLimitSettings{
+		Enabled: true,
+		Default: config.RateLimitRule{Limit: 1, Period: time.Minute, Key: constant.RateLimitKeyIP},
+		Routes: map[string]config.RateLimitRule{
+			constant.AuthRoute + constant.SignInRoute: {Limit: 2, Period: time.Minute, Key: constant.RateLimitKeyIP},
+			constant.UserRoute:                        {Limit: 1, Period: time.Minute, Key: constant.RateLimitKeyUser},
+			constant.APIKeysRoute:                     {Limit: 1, Period: time.Minute, Key: constant.RateLimitKeyClient},
+		},
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	router := chi.NewRouter()
+	router.Use(New(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, memory.NewRateLimitStorage(), fakeVerifier{}))
+	router.Route(constant.AuthRoute, func(r chi.Router) {
+		r.Post(constant.SignInRoute, ok)
+	})
+	router.Get(constant.UserRoute, ok)
+	router.Get(constant.JWKSRoute, ok)
+	router.Get(constant.APIKeysRoute, ok)
+
+	data := []struct {
+		name       string
+		method     string
+		path       string
+		ip         string
+		token      string
+		expected   int
+		remaining  string
+		retryAfter string
+	}{
+		{"first", http.MethodPost, "/auth/sign-in", "10.0.0.1", "", http.StatusOK, "1", ""},
+		{"second", http.MethodPost, "/auth/sign-in", "10.0.0.1", "", http.StatusOK, "0", ""},
+		{"exceeded", http.MethodPost, "/auth/sign-in", "10.0.0.1", "", http.StatusTooManyRequests, "0", "30"},
+		{"other ip", http.MethodPost, "/auth/sign-in", "10.0.0.2", "", http.StatusOK, "1", ""},
+		// the unmatched paths share the default bucket instead of getting a fresh one each
+		{"trailing slash", http.MethodPost, "/auth/sign-in/", "10.0.0.3", "", http.StatusNotFound, "0", ""},
+		{"other case", http.MethodPost, "/auth/Sign-In", "10.0.0.3", "", http.StatusTooManyRequests, "0", "60"},
+		{"default rule", http.MethodGet, constant.JWKSRoute, "10.0.0.3", "", http.StatusTooManyRequests, "0", "60"},
+		{"user", http.MethodGet, "/users/1", "10.0.0.4", "alice", http.StatusOK, "0", ""},
+		{"same user other id", http.MethodGet, "/users/2", "10.0.0.5", "alice", http.StatusTooManyRequests, "0", "60"},
+		{"other user", http.MethodGet, "/users/1", "10.0.0.4", "bob", http.StatusOK, "0", ""},
+		{"invalid token by ip", http.MethodGet, "/users/1", "10.0.0.4", "forged", http.StatusOK, "0", ""},
+		{"invalid token same ip", http.MethodGet, "/users/1", "10.0.0.4", "forged", http.StatusTooManyRequests, "0", "60"},
+		{"api key", http.MethodGet, constant.APIKeysRoute, "10.0.0.6", "key_1", http.StatusOK, "0", ""},
+		{"same api key other ip", http.MethodGet, constant.APIKeysRoute, "10.0.0.7", "key_1", http.StatusTooManyRequests, "0", "60"},
+		{"other api key of the user", http.MethodGet, constant.APIKeysRoute, "10.0.0.6", "key_2", http.StatusOK, "0", ""},
+		{"service account", http.MethodGet, constant.APIKeysRoute, "10.0.0.6", "sa_1", http.StatusOK, "0", ""},
+		{"other key of the service account", http.MethodGet, constant.APIKeysRoute, "10.0.0.8", "sa_2", http.StatusTooManyRequests, "0", "60"},
+		{"access token by ip", http.MethodGet, constant.APIKeysRoute, "10.0.0.9", "alice", http.StatusOK, "0", ""},
+		{"access token same ip", http.MethodGet, constant.APIKeysRoute, "10.0.0.9", "bob", http.StatusTooManyRequests, "0", "60"},
+	}
+
+	for _, d := range data {
+		r := httptest.NewRequest(d.method, d.path, nil)
+		r.RemoteAddr = d.ip + ":1234"
+		// a header of the client's choice must not give a fresh bucket
+		r.Header.Set("X-Client-Id", d.name)
+		if d.token != "" {
+			r.Header.Set("Authorization", "Bearer "+d.token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, w.Code)
+		}
+
+		if remaining := w.Header().Get("RateLimit-Remaining"); remaining != d.remaining {
+			t.Errorf("%s: expected remaining %v, got %v", d.name, d.remaining, remaining)
+		}
+
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != d.retryAfter {
+			t.Errorf("%s: expected retry after %v, got %v", d.name, d.retryAfter, retryAfter)
+		}
+
+		if w.Header().Get("RateLimit-Limit") == "" || w.Header().Get("RateLimit-Reset") == "" {
+			t.Errorf("%s: expected the limit and reset headers", d.name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package binding
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/degeboman/gas/constant"
+	"net/http"
+)
+
+// Ensure returns the binding of the browser, a new one is set as a cookie if the request has none
+func Ensure(w http.ResponseWriter, r *http.Request, secure bool) (string, error) {
+	if b := FromRequest(r); b != "" {
+		return b, nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	b := base64.RawURLEncoding.EncodeToString(raw)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     constant.BindingCookie,
+		Value:    b,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return b, nil
+}
+
+func FromRequest(r *http.Request) string {
+	c, err := r.Cookie(constant.BindingCookie)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
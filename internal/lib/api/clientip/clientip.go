@@ -0,0 +1,17 @@
+package clientip
+
+import (
+	"net"
+	"net/http"
+)
+
+// FromRequest returns the client ip without port, chi RealIP middleware should be mounted
+// when gas is behind a trusted proxy
+func FromRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -1,5 +1,12 @@
 package response
 
+import (
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/render"
+	"net/http"
+)
+
 type Response struct {
 	Status string `json:"status"`
 	Error  string `json:"error,omitempty"`
@@ -22,3 +29,13 @@ func OK() Response {
 		Status: StatusOK,
 	}
 }
+
+// ErrorStatus sets the status code of the storage errors, the response of the other errors keeps the default status
+func ErrorStatus(r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		render.Status(r, http.StatusNotFound)
+	case errors.Is(err, storage.ErrEmailTaken):
+		render.Status(r, http.StatusConflict)
+	}
+}
@@ -0,0 +1,37 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-chi/render"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	data := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"not found", fmt.Errorf("usecase.admin.User: %w", storage.ErrNotFound), http.StatusNotFound},
+		{"email taken", fmt.Errorf("usecase.usecase.CreateUser: %w", storage.ErrEmailTaken), http.StatusConflict},
+		{"other", errors.New("connection refused"), http.StatusOK},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			w := httptest.NewRecorder()
+
+			ErrorStatus(r, d.err)
+			render.JSON(w, r, Error("failed"))
+
+			if w.Code != d.expected {
+				t.Errorf("Expected %v, got %v", d.expected, w.Code)
+			}
+		})
+	}
+}
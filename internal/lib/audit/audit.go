@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// appendAttempts bounds the retries of an append raced by other replicas
+	appendAttempts = 5
+	// batchSize is the page size of the export and the verification
+	batchSize = 500
+)
+
+var ErrBrokenChain = errors.New("audit log chain is broken")
+
+// Log is the append-only audit log, every entry is chained to the previous one by its hash
+type Log struct {
+	log     *slog.Logger
+	storage storage.AuditStorage
+	// mu orders the appends of the replica, the storage orders the appends of different replicas
+	mu sync.Mutex
+}
+
+func New(log *slog.Logger, storage storage.AuditStorage) *Log {
+	return &Log{
+		log:     log.With(slog.String("component", "audit")),
+		storage: storage,
+	}
+}
+
+// Record appends the entry, the actor and the request fields that are not set are taken from ctx.
+// A failed append is logged, the audited action is done already
+func (l *Log) Record(ctx context.Context, entry storage.AuditEntry) {
+	const op = "lib.audit.Log.Record"
+
+	req := FromContext(ctx)
+
+	if entry.ActorID == "" {
+		entry.ActorID = req.ActorID
+		entry.ActorAPIKeyID = req.ActorAPIKeyID
+	}
+	if entry.IP == "" {
+		entry.IP = req.IP
+	}
+	if entry.UserAgent == "" {
+		entry.UserAgent = req.UserAgent
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = req.RequestID
+	}
+
+	// the storage keeps milliseconds, the hash must survive the round trip
+	entry.Time = time.Now().UTC().Truncate(time.Millisecond)
+
+	if err := l.append(ctx, entry); err != nil {
+		l.log.Error("failed to append audit entry",
+			slog.String("op", op),
+			slog.String("action", entry.Action),
+			slog.String("request_id", entry.RequestID),
+			sl.Err(err),
+		)
+	}
+}
+
+func (l *Log) append(ctx context.Context, entry storage.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+
+	for i := 0; i < appendAttempts; i++ {
+		var last storage.AuditEntry
+
+		if last, err = l.storage.LastAuditEntry(ctx); err != nil {
+			return err
+		}
+
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+		entry.Hash = Hash(entry)
+
+		if err = l.storage.AddAuditEntry(ctx, entry); !errors.Is(err, storage.ErrAuditSeqTaken) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Entries returns a page of the log
+func (l *Log) Entries(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	return l.storage.AuditEntries(ctx, filter)
+}
+
+// Export writes the entries matching the filter as json lines in the order of the log
+func (l *Log) Export(ctx context.Context, filter storage.AuditFilter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+
+	filter.Descending = false
+	filter.Limit = batchSize
+
+	for {
+		entries, err := l.storage.AuditEntries(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < batchSize {
+			return nil
+		}
+
+		filter.AfterSeq = entries[len(entries)-1].Seq
+	}
+}
+
+// Verify walks the whole log and returns the number of entries checked, the error names the first
+// entry that was changed or follows a removed one
+func (l *Log) Verify(ctx context.Context) (int64, error) {
+	var (
+		prev    storage.AuditEntry
+		checked int64
+	)
+
+	for {
+		entries, err := l.storage.AuditEntries(ctx, storage.AuditFilter{AfterSeq: prev.Seq, Limit: batchSize})
+		if err != nil {
+			return checked, err
+		}
+
+		for _, e := range entries {
+			if e.Seq != prev.Seq+1 || e.PrevHash != prev.Hash || Hash(e) != e.Hash {
+				return checked, fmt.Errorf("%w at entry %d", ErrBrokenChain, e.Seq)
+			}
+
+			prev = e
+			checked++
+		}
+
+		if len(entries) < batchSize {
+			return checked, nil
+		}
+	}
+}
+
+// Hash is the sha-256 of the entry fields and the hash of the previous entry
+func Hash(e storage.AuditEntry) string {
+	h := sha256.New()
+
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.Time.UTC().Format(time.RFC3339Nano),
+		e.Action,
+		e.Outcome,
+		e.Error,
+		e.ActorID,
+		e.ActorAPIKeyID,
+		e.Target,
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+		e.PrevHash,
+	} {
+		// the length prefix keeps the fields apart, a|bc and ab|c hash differently
+		h.Write([]byte(strconv.Itoa(len(field)) + ":" + field))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
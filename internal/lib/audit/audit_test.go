@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// fakeStorage keeps the log in a slice, conflicts makes the next appends fail as if another replica won the seq
+type fakeStorage struct {
+	entries   []storage.AuditEntry
+	conflicts int
+}
+
+func (s *fakeStorage) LastAuditEntry(_ context.Context) (storage.AuditEntry, error) {
+	if len(s.entries) == 0 {
+		return storage.AuditEntry{}, nil
+	}
+
+	return s.entries[len(s.entries)-1], nil
+}
+
+func (s *fakeStorage) AddAuditEntry(_ context.Context, entry storage.AuditEntry) error {
+	if s.conflicts > 0 {
+		s.conflicts--
+
+		last, _ := s.LastAuditEntry(context.Background())
+
+		other := storage.AuditEntry{Seq: entry.Seq, Time: entry.Time, Action: constant.AuditSignOut, PrevHash: last.Hash}
+		other.Hash = Hash(other)
+
+		s.entries = append(s.entries, other)
+
+		return storage.ErrAuditSeqTaken
+	}
+
+	s.entries = append(s.entries, entry)
+
+	return nil
+}
+
+func (s *fakeStorage) AuditEntries(_ context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	var entries []storage.AuditEntry
+
+	for _, e := range s.entries {
+		if e.Seq <= filter.AfterSeq || (filter.Action != "" && e.Action != filter.Action) {
+			continue
+		}
+
+		entries = append(entries, e)
+
+		if filter.Limit > 0 && int64(len(entries)) == filter.Limit {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+func newLog(s *fakeStorage) *Log {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+}
+
+func TestRecord(t *testing.T) {
+	s := &fakeStorage{}
+	l := newLog(s)
+
+	ctx := WithRequest(context.Background(), Request{IP: "10.0.0.1", UserAgent: "curl/8.0", RequestID: "req-1"})
+	ctx = WithActor(ctx, "admin", "key")
+
+	l.Record(ctx, storage.AuditEntry{Action: constant.AuditUserDelete, Outcome: constant.AuditSuccess, Target: "42"})
+	l.Record(ctx, storage.AuditEntry{Action: constant.AuditSignIn, Outcome: constant.AuditFailure, ActorID: "42"})
+
+	if len(s.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(s.entries))
+	}
+
+	first, second := s.entries[0], s.entries[1]
+
+	if first.Seq != 1 || second.Seq != 2 {
+		t.Errorf("Expected seq 1 and 2, got %d and %d", first.Seq, second.Seq)
+	}
+
+	if first.PrevHash != "" || second.PrevHash != first.Hash {
+		t.Errorf("Expected the second entry to point to the first one")
+	}
+
+	if first.ActorID != "admin" || first.ActorAPIKeyID != "key" || first.IP != "10.0.0.1" || first.UserAgent != "curl/8.0" || first.RequestID != "req-1" {
+		t.Errorf("Expected the request fields from the context, got %+v", first)
+	}
+
+	if second.ActorID != "42" || second.ActorAPIKeyID != "" {
+		t.Errorf("Expected the actor of the entry, got %s %s", second.ActorID, second.ActorAPIKeyID)
+	}
+
+	if n, err := l.Verify(context.Background()); err != nil || n != 2 {
+		t.Errorf("Expected 2 valid entries, got %d %v", n, err)
+	}
+}
+
+func TestRecordSeqConflict(t *testing.T) {
+	s := &fakeStorage{conflicts: 2}
+	l := newLog(s)
+
+	l.Record(context.Background(), storage.AuditEntry{Action: constant.AuditSignIn, Outcome: constant.AuditSuccess})
+
+	if len(s.entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(s.entries))
+	}
+
+	if s.entries[2].Action != constant.AuditSignIn || s.entries[2].Seq != 3 {
+		t.Errorf("Expected the entry to be appended after the other ones, got %+v", s.entries[2])
+	}
+
+	if _, err := l.Verify(context.Background()); err != nil {
+		t.Errorf("Expected a valid chain, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := []struct {
+		name   string
+		tamper func(s *fakeStorage)
+		seq    string
+	}{
+		{"changed field", func(s *fakeStorage) { s.entries[1].Target = "someone else" }, "entry 2"},
+		{"recomputed hash", func(s *fakeStorage) {
+			s.entries[1].Outcome = constant.AuditSuccess
+			s.entries[1].Hash = Hash(s.entries[1])
+		}, "entry 3"},
+		{"removed entry", func(s *fakeStorage) { s.entries = append(s.entries[:1], s.entries[2:]...) }, "entry 3"},
+		{"removed first entry", func(s *fakeStorage) { s.entries = s.entries[1:] }, "entry 2"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			s := &fakeStorage{}
+			l := newLog(s)
+
+			for i := 0; i < 3; i++ {
+				l.Record(context.Background(), storage.AuditEntry{Action: constant.AuditUserUpdate, Outcome: constant.AuditFailure, Target: "42"})
+			}
+
+			d.tamper(s)
+
+			_, err := l.Verify(context.Background())
+			if !errors.Is(err, ErrBrokenChain) {
+				t.Fatalf("Expected %v, got %v", ErrBrokenChain, err)
+			}
+
+			if !bytes.HasSuffix([]byte(err.Error()), []byte(d.seq)) {
+				t.Errorf("Expected the error at %s, got %v", d.seq, err)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	s := &fakeStorage{}
+	l := newLog(s)
+
+	for i := 0; i < batchSize+2; i++ {
+		action := constant.AuditSignIn
+		if i%2 == 0 {
+			action = constant.AuditSignOut
+		}
+
+		l.Record(context.Background(), storage.AuditEntry{Action: action, Outcome: constant.AuditSuccess})
+	}
+
+	var out bytes.Buffer
+
+	if err := l.Export(context.Background(), storage.AuditFilter{Action: constant.AuditSignIn}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		lines int
+		prev  int64
+	)
+
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e storage.AuditEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+
+		if e.Action != constant.AuditSignIn || e.Seq <= prev {
+			t.Errorf("Expected ascending sign in entries, got %s %d after %d", e.Action, e.Seq, prev)
+		}
+
+		if Hash(e) != e.Hash {
+			t.Errorf("Expected the exported entry %d to keep its hash", e.Seq)
+		}
+
+		prev = e.Seq
+		lines++
+	}
+
+	if expected := (batchSize + 2) / 2; lines != expected {
+		t.Errorf("Expected %d lines, got %d", expected, lines)
+	}
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+)
+
+// Request describes who made the request the audited action comes from
+type Request struct {
+	IP            string
+	UserAgent     string
+	RequestID     string
+	ActorID       string
+	ActorAPIKeyID string
+}
+
+type requestKey struct{}
+
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// WithActor adds the authenticated user or service account to the request of ctx
+func WithActor(ctx context.Context, actorID, apiKeyID string) context.Context {
+	req := FromContext(ctx)
+	req.ActorID = actorID
+	req.ActorAPIKeyID = apiKeyID
+
+	return WithRequest(ctx, req)
+}
+
+func FromContext(ctx context.Context) Request {
+	req, _ := ctx.Value(requestKey{}).(Request)
+	return req
+}
+
+// UnaryServerInterceptor adds the peer ip and the user agent of grpc calls to the context
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	var r Request
+
+	if p, ok := peer.FromContext(ctx); ok {
+		r.IP = p.Addr.String()
+
+		if host, _, err := net.SplitHostPort(r.IP); err == nil {
+			r.IP = host
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			r.UserAgent = ua[0]
+		}
+
+		if id := md.Get("x-request-id"); len(id) > 0 {
+			r.RequestID = id[0]
+		}
+	}
+
+	return handler(WithRequest(ctx, r), req)
+}
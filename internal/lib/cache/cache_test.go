@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+
+	c := New(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// a is used, so b is the least recently used one when c comes
+	c.Get("a")
+	c.Set("c", 3)
+
+	c.SetWithTTL("a", 4, time.Second)
+
+	data := []struct {
+		key      string
+		after    time.Duration
+		expected interface{}
+	}{
+		{"a", 0, 4},
+		{"b", 0, nil},
+		{"c", 0, 3},
+		{"a", 2 * time.Second, nil},
+		{"c", time.Minute, nil},
+	}
+
+	for _, d := range data {
+		now = now.Add(d.after)
+
+		value, _ := c.Get(d.key)
+		if value != d.expected {
+			t.Errorf("%s after %v: expected %v, got %v", d.key, d.after, d.expected, value)
+		}
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Expected the expired values to be evicted, got %d", c.Len())
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := New(2, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Clear()
+
+	if c.Len() != 0 {
+		t.Errorf("Expected the cache to be empty, got %d", c.Len())
+	}
+
+	c.Set("c", 3)
+
+	if value, _ := c.Get("c"); value != 3 {
+		t.Errorf("Expected %v, got %v", 3, value)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	c := New(0, time.Minute)
+
+	c.Set("a", 1)
+	c.Delete("a")
+	c.Clear()
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected the disabled cache to keep nothing")
+	}
+}
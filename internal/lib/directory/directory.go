@@ -0,0 +1,150 @@
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Entry is the directory user mapped to gas
+type Entry struct {
+	DN       string
+	UserInfo map[string]interface{}
+	Roles    []string
+}
+
+// LDAP checks passwords by binding as the user found by email
+type LDAP struct {
+	cfg config.LDAPSettings
+}
+
+func New(cfg config.LDAPSettings) *LDAP {
+	return &LDAP{cfg: cfg}
+}
+
+func (l *LDAP) Authenticate(ctx context.Context, email, password string) (Entry, error) {
+	const op = "lib.directory.LDAP.Authenticate"
+
+	// an empty password makes an unauthenticated bind which succeeds for any dn
+	if password == "" {
+		return Entry{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+
+	// the connection is closed when the request is cancelled, pending operations fail right away
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := conn.Bind(l.cfg.BindDN, l.cfg.BindPassword); err != nil {
+		return Entry{}, fmt.Errorf("%s: service account bind: %w", op, err)
+	}
+
+	attributes := []string{l.cfg.GroupAttribute}
+	for attribute := range l.cfg.Attributes {
+		attributes = append(attributes, attribute)
+	}
+
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		int(l.cfg.Timeout.Seconds()),
+		false,
+		fmt.Sprintf(l.cfg.UserFilter, ldap.EscapeFilter(email)),
+		attributes,
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return Entry{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// several entries mean the filter is ambiguous, none of them is trusted
+	if res == nil || len(res.Entries) != 1 {
+		return Entry{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	entry := res.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return Entry{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
+		return Entry{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return l.mapEntry(entry), nil
+}
+
+func (l *LDAP) mapEntry(entry *ldap.Entry) Entry {
+	userInfo := make(map[string]interface{}, len(l.cfg.Attributes))
+
+	for attribute, field := range l.cfg.Attributes {
+		if value := entry.GetAttributeValue(attribute); value != "" {
+			userInfo[field] = value
+		}
+	}
+
+	roles := []string{}
+
+	for _, group := range entry.GetAttributeValues(l.cfg.GroupAttribute) {
+		for groupDN, role := range l.cfg.GroupRoles {
+			// dns are compared case insensitively like the directory does
+			if strings.EqualFold(group, groupDN) {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	return Entry{
+		DN:       entry.DN,
+		UserInfo: userInfo,
+		Roles:    roles,
+	}
+}
+
+func (l *LDAP) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(l.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: l.cfg.Timeout}))
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(l.cfg.Timeout)
+
+	if l.cfg.StartTLS {
+		u, err := url.Parse(l.cfg.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
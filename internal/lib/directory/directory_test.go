@@ -0,0 +1,199 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type directoryUser struct {
+	password   string
+	attributes map[string][]string
+}
+
+// fakeLDAPServer understands just enough of the protocol for simple binds and equality searches
+type fakeLDAPServer struct {
+	listener net.Listener
+	users    map[string]directoryUser
+}
+
+func newFakeLDAPServer(t *testing.T, users map[string]directoryUser) *fakeLDAPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeLDAPServer{listener: listener, users: users}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeLDAPServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeLDAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := request.Children[1].Data.String()
+			password := request.Children[2].Data.String()
+
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if user, ok := s.users[dn]; ok && password != "" && user.password == password {
+				code = ldap.LDAPResultSuccess
+			}
+
+			s.respond(conn, messageID, result(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(request.Children[6])
+			if err != nil {
+				return
+			}
+
+			for dn, user := range s.users {
+				for _, mail := range user.attributes["mail"] {
+					if filter == "(mail="+ldap.EscapeFilter(mail)+")" {
+						s.respond(conn, messageID, searchEntry(dn, user.attributes))
+					}
+				}
+			}
+
+			s.respond(conn, messageID, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *fakeLDAPServer) respond(conn net.Conn, messageID int64, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(op)
+
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+
+	return op
+}
+
+func searchEntry(dn string, attributes map[string][]string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+
+	for name, values := range attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+
+		attribute.AppendChild(set)
+		list.AppendChild(attribute)
+	}
+
+	op.AppendChild(list)
+
+	return op
+}
+
+func TestAuthenticate(t *testing.T) {
+	server := newFakeLDAPServer(t, map[string]directoryUser{
+		"cn=gas,dc=home,dc=lab": {password: "service"},
+		"uid=rupychman,ou=people,dc=home,dc=lab": {
+			password: "secret",
+			attributes: map[string][]string{
+				"mail":        {"rupychman@mail.ru"},
+				"displayName": {"Rupych Man"},
+				"memberOf":    {"CN=Admins,OU=Groups,DC=home,DC=lab", "cn=guests,ou=groups,dc=home,dc=lab"},
+			},
+		},
+	})
+
+	l := New(config.LDAPSettings{
+		URL:            server.url(),
+		BindDN:         "cn=gas,dc=home,dc=lab",
+		BindPassword:   "service",
+		BaseDN:         "dc=home,dc=lab",
+		UserFilter:     "(mail=%s)",
+		Attributes:     map[string]string{"displayName": "name"},
+		GroupAttribute: "memberOf",
+		GroupRoles:     map[string]string{"cn=admins,ou=groups,dc=home,dc=lab": "admin"},
+		Timeout:        time.Second,
+	})
+
+	entry, err := l.Authenticate(context.Background(), "rupychman@mail.ru", "secret")
+	if err != nil {
+		t.Fatalf("Expected authentication to succeed, got %s", err)
+	}
+
+	expected := Entry{
+		DN:       "uid=rupychman,ou=people,dc=home,dc=lab",
+		UserInfo: map[string]interface{}{"name": "Rupych Man"},
+		Roles:    []string{"admin"},
+	}
+
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("Expected %v, got %v", expected, entry)
+	}
+
+	data := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "wrong password", email: "rupychman@mail.ru", password: "wrong"},
+		{name: "empty password", email: "rupychman@mail.ru", password: ""},
+		{name: "unknown email", email: "unknown@mail.ru", password: "secret"},
+		{name: "filter injection", email: "*", password: "secret"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := l.Authenticate(context.Background(), d.email, d.password)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected %v, got %v", ErrInvalidCredentials, err)
+			}
+		})
+	}
+}
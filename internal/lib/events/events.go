@@ -0,0 +1,33 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Event is a change of a user or a session, data never holds secrets
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// Publisher saves events for delivery to the subscribers, publishing must not block the caller for long
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+func New(eventType string, data map[string]interface{}) Event {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return Event{
+		ID:        "evt_" + hex.EncodeToString(b),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
@@ -0,0 +1,125 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/go-jose/go-jose/v3"
+	"os"
+)
+
+// KeySet signs tokens with a private key so that anyone can verify them by the published public key
+type KeySet struct {
+	key    crypto.Signer
+	kid    string
+	method jwt.SigningMethod
+}
+
+// Load reads a pem encoded rsa or ecdsa p-256 private key, pkcs1, sec1 or pkcs8
+func Load(path string) (*KeySet, error) {
+	const op = "jwks.Load"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: %w", op, errors.New("no pem block found"))
+	}
+
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return New(key)
+}
+
+func New(key crypto.Signer) (*KeySet, error) {
+	const op = "jwks.New"
+
+	var method jwt.SigningMethod
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s: %w", op, errors.New("only the p-256 curve is supported"))
+		}
+		method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("%s: %w", op, fmt.Errorf("unsupported key type %T", key))
+	}
+
+	// the thumbprint changes with the key, so the clients notice a rotation by the unknown kid
+	thumbprint, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &KeySet{
+		key:    key,
+		kid:    base64.RawURLEncoding.EncodeToString(thumbprint),
+		method: method,
+	}, nil
+}
+
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+
+	return token.SignedString(k.key)
+}
+
+// Keyfunc returns the public key for the tokens signed by Sign
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.method.Alg() || token.Header["kid"] != k.kid {
+		return nil, errors.New("unknown signing key")
+	}
+
+	return k.key.Public(), nil
+}
+
+// Public is the json web key set served to the clients
+func (k *KeySet) Public() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{
+			Key:       k.key.Public(),
+			KeyID:     k.kid,
+			Algorithm: k.method.Alg(),
+			Use:       "sig",
+		}},
+	}
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("unsupported private key format")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return signer, nil
+}
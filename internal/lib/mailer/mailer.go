@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// New returns an smtp sender, or a sender writing messages to the log if smtp host is not configured
+func New(log *slog.Logger, cfg config.MailSettings) Sender {
+	if cfg.Host == "" {
+		return LogSender{log: log.With(slog.String("component", "mail"))}
+	}
+
+	return SMTPSender{cfg: cfg}
+}
+
+type SMTPSender struct {
+	cfg config.MailSettings
+}
+
+func (s SMTPSender) Send(_ context.Context, to, subject, body string) error {
+	const op = "lib.mailer.SMTPSender.Send"
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + s.cfg.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(addr, auth, s.cfg.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LogSender is used for local development when there is no smtp server
+type LogSender struct {
+	log *slog.Logger
+}
+
+func (s LogSender) Send(_ context.Context, to, subject, body string) error {
+	s.log.Info("mail is not sent, smtp is not configured",
+		slog.String("to", to),
+		slog.String("subject", subject),
+		slog.String("body", body),
+	)
+
+	return nil
+}
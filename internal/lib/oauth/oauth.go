@@ -0,0 +1,52 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+)
+
+// Identity is the user as the provider knows them
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type Provider interface {
+	// AuthCodeURL is the page of the provider the user is redirected to,
+	// verifier is the pkce code verifier and nonce is checked in the id token
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange trades the authorization code for the identity of the user
+	Exchange(ctx context.Context, code, nonce, verifier string) (Identity, error)
+}
+
+func New(cfg config.OAuthProvider) (Provider, error) {
+	switch cfg.Type {
+	case "", constant.OAuthTypeOIDC:
+		return NewOIDC(cfg), nil
+	case constant.OAuthTypeOAuth2:
+		return NewOAuth2(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", cfg.Type)
+	}
+}
+
+// NewProviders returns the providers by name
+func NewProviders(cfg config.OAuthSettings) (map[string]Provider, error) {
+	const op = "lib.oauth.NewProviders"
+
+	providers := make(map[string]Provider, len(cfg.Providers))
+
+	for name, providerCfg := range cfg.Providers {
+		provider, err := New(providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+
+		providers[name] = provider
+	}
+
+	return providers, nil
+}
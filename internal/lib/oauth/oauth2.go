@@ -0,0 +1,102 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"golang.org/x/oauth2"
+	"net/http"
+)
+
+// OAuth2 is a provider without id tokens, the identity is taken from the user info endpoint
+type OAuth2 struct {
+	cfg  config.OAuthProvider
+	conf *oauth2.Config
+}
+
+func NewOAuth2(cfg config.OAuthProvider) *OAuth2 {
+	return &OAuth2{
+		cfg: cfg,
+		conf: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  cfg.AuthURL,
+				TokenURL: cfg.TokenURL,
+			},
+			Scopes: cfg.Scopes,
+		},
+	}
+}
+
+// AuthCodeURL does not use the nonce since there is no id token to check it in
+func (p *OAuth2) AuthCodeURL(_ context.Context, state, _, verifier string) (string, error) {
+	return p.conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *OAuth2) Exchange(ctx context.Context, code, _, verifier string) (Identity, error) {
+	const op = "lib.oauth.OAuth2.Exchange"
+
+	token, err := p.conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.UserInfoURL, nil)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.conf.Client(ctx, token).Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%s: %w", op, fmt.Errorf("user info responded with %s", res.Status))
+	}
+
+	var userInfo map[string]interface{}
+
+	decoder := json.NewDecoder(res.Body)
+	// numeric ids are kept as they are instead of floats
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&userInfo); err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subject := field(userInfo, p.cfg.SubjectField, "id")
+	if subject == "" {
+		return Identity{}, fmt.Errorf("%s: %w", op, errors.New("user info has no subject"))
+	}
+
+	email := field(userInfo, p.cfg.EmailField, "email")
+
+	return Identity{
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: p.cfg.EmailVerified && email != "",
+	}, nil
+}
+
+func field(userInfo map[string]interface{}, name, defaultName string) string {
+	if name == "" {
+		name = defaultName
+	}
+
+	switch v := userInfo[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return ""
+	}
+}
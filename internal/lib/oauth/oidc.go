@@ -0,0 +1,104 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/degeboman/gas/internal/config"
+	"golang.org/x/oauth2"
+	"sync"
+)
+
+// OIDC discovers the provider on the first use, so that gas starts while the provider is unavailable
+type OIDC struct {
+	cfg config.OAuthProvider
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDC(cfg config.OAuthProvider) *OIDC {
+	return &OIDC{cfg: cfg}
+}
+
+func (p *OIDC) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	const op = "lib.oauth.OIDC.AuthCodeURL"
+
+	conf, err := p.config(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return conf.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, nonce, verifier string) (Identity, error) {
+	const op = "lib.oauth.OIDC.Exchange"
+
+	conf, err := p.config(ctx)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, fmt.Errorf("%s: %w", op, errors.New("token response has no id token"))
+	}
+
+	idToken, err := p.provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if idToken.Nonce != nonce {
+		return Identity{}, fmt.Errorf("%s: %w", op, errors.New("id token nonce does not match"))
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+func (p *OIDC) config(ctx context.Context) (*oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+		if err != nil {
+			return nil, err
+		}
+
+		p.provider = provider
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     p.provider.Endpoint(),
+		Scopes:       scopes,
+	}, nil
+}
@@ -0,0 +1,223 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/storage"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sink is where the relay delivers events, a sink may get an event more than once and tells
+// the copies apart by the event id
+type Sink interface {
+	Send(ctx context.Context, event events.Event) error
+}
+
+// TargetSink delivers an event to several targets, e.g. the webhooks, the retries of a failed send
+// go only to the targets that don't have the event yet. The attempt of the event counts from 1
+type TargetSink interface {
+	Sink
+	SendTargets(ctx context.Context, event events.Event, attempt int, progress Progress) error
+}
+
+// Progress is what the relay remembers of the targets of a sink
+type Progress interface {
+	// Done tells if the target already has the event
+	Done(target string) bool
+	// Complete records that the target has the event or is given up on
+	Complete(ctx context.Context, target string) error
+}
+
+// Publisher writes the events to the outbox, the relay delivers them later
+type Publisher struct {
+	storage storage.OutboxStorage
+}
+
+func NewPublisher(storage storage.OutboxStorage) Publisher {
+	return Publisher{storage: storage}
+}
+
+// Publish is a part of the transaction of ctx, the event is saved only with the change it describes
+func (p Publisher) Publish(ctx context.Context, event events.Event) error {
+	return p.storage.AddOutboxEvent(ctx, event)
+}
+
+// Relay delivers the outbox events to every sink at least once, an event stays in the outbox
+// until all the sinks have it. The sinks send synchronously, so an event is never lost with a stopped replica
+type Relay struct {
+	log     *slog.Logger
+	cfg     config.OutboxSettings
+	storage storage.OutboxStorage
+	sinks   map[string]Sink
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewRelay(log *slog.Logger, cfg config.OutboxSettings, storage storage.OutboxStorage, sinks map[string]Sink) *Relay {
+	return &Relay{
+		log:     log.With(slog.String("component", "outbox")),
+		cfg:     cfg,
+		storage: storage,
+		sinks:   sinks,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start polls the outbox until Stop, the events claimed but not sent by a stopped relay are sent after the lease
+func (r *Relay) Start() {
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			// a full batch means there are more due events
+			for r.Relay(context.Background()) == r.cfg.BatchSize {
+				select {
+				case <-r.stop:
+					return
+				default:
+				}
+			}
+
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Relay) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// Relay sends a batch of due events and returns the number of events in it
+func (r *Relay) Relay(ctx context.Context) int {
+	const op = "lib.outbox.Relay.Relay"
+
+	log := r.log.With(slog.String("op", op))
+
+	batch, err := r.storage.ClaimOutboxEvents(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		log.Error("failed to claim events", sl.Err(err))
+
+		return 0
+	}
+
+	for _, e := range batch {
+		if err := r.send(ctx, e); err != nil {
+			log.Warn("failed to relay event",
+				slog.String("event_id", e.Event.ID),
+				slog.String("event_type", e.Event.Type),
+				slog.Int("attempt", e.Attempts+1),
+				sl.Err(err),
+			)
+
+			at := time.Now().Add(Backoff(r.cfg, e.Attempts+1))
+
+			if err := r.storage.RetryOutboxEvent(ctx, e.Event.ID, at, err.Error()); err != nil {
+				log.Error("failed to schedule event retry", slog.String("event_id", e.Event.ID), sl.Err(err))
+			}
+
+			continue
+		}
+
+		if err := r.storage.DeleteOutboxEvent(ctx, e.Event.ID); err != nil {
+			log.Error("failed to delete relayed event", slog.String("event_id", e.Event.ID), sl.Err(err))
+		}
+	}
+
+	return len(batch)
+}
+
+// send delivers the event to the sinks that don't have it yet, a failed sink does not hold the others back,
+// so every sink without the event is tried on every attempt
+func (r *Relay) send(ctx context.Context, e storage.OutboxEvent) error {
+	done := make(map[string]bool, len(e.Sinks))
+	for _, name := range e.Sinks {
+		done[name] = true
+	}
+
+	names := make([]string, 0, len(r.sinks))
+	for name := range r.sinks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var errs []error
+
+	for _, name := range names {
+		if done[name] {
+			continue
+		}
+
+		if err := r.sendSink(ctx, name, e, done); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		if err := r.storage.CompleteOutboxSink(ctx, e.Event.ID, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Relay) sendSink(ctx context.Context, name string, e storage.OutboxEvent, done map[string]bool) error {
+	sink, ok := r.sinks[name].(TargetSink)
+	if !ok {
+		return r.sinks[name].Send(ctx, e.Event)
+	}
+
+	return sink.SendTargets(ctx, e.Event, e.Attempts+1, progress{
+		storage: r.storage,
+		eventID: e.Event.ID,
+		sink:    name,
+		done:    done,
+	})
+}
+
+// progress keeps the targets in the sinks of the event as <sink>:<target>
+type progress struct {
+	storage storage.OutboxStorage
+	eventID string
+	sink    string
+	done    map[string]bool
+}
+
+func (p progress) Done(target string) bool {
+	return p.done[p.sink+":"+target]
+}
+
+func (p progress) Complete(ctx context.Context, target string) error {
+	return p.storage.CompleteOutboxSink(ctx, p.eventID, p.sink+":"+target)
+}
+
+// Backoff is the delay after the failed attempt, it doubles with every attempt up to the max backoff
+func Backoff(cfg config.OutboxSettings, attempt int) time.Duration {
+	delay := cfg.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+
+		if delay >= cfg.MaxBackoff {
+			return cfg.MaxBackoff
+		}
+	}
+
+	return delay
+}
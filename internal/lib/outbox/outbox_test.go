@@ -0,0 +1,290 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeEvent struct {
+	storage.OutboxEvent
+	dueAt time.Time
+}
+
+// fakeStorage keeps the outbox in a map, the relay of the tests is the only one
+type fakeStorage struct {
+	events map[string]*fakeEvent
+}
+
+func (s *fakeStorage) AddOutboxEvent(_ context.Context, event events.Event) error {
+	s.events[event.ID] = &fakeEvent{OutboxEvent: storage.OutboxEvent{Event: event}, dueAt: event.CreatedAt}
+	return nil
+}
+
+func (s *fakeStorage) ClaimOutboxEvents(_ context.Context, limit int, lease time.Duration) ([]storage.OutboxEvent, error) {
+	var due []*fakeEvent
+	for _, e := range s.events {
+		if !e.dueAt.After(time.Now()) {
+			due = append(due, e)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Event.CreatedAt.Before(due[j].Event.CreatedAt) })
+
+	var claimed []storage.OutboxEvent
+	for _, e := range due {
+		if len(claimed) == limit {
+			break
+		}
+
+		e.dueAt = time.Now().Add(lease)
+		claimed = append(claimed, storage.OutboxEvent{
+			Event:    e.Event,
+			Attempts: e.Attempts,
+			Sinks:    append([]string(nil), e.Sinks...),
+		})
+	}
+
+	return claimed, nil
+}
+
+func (s *fakeStorage) CompleteOutboxSink(_ context.Context, id, sink string) error {
+	s.events[id].Sinks = append(s.events[id].Sinks, sink)
+	return nil
+}
+
+func (s *fakeStorage) RetryOutboxEvent(_ context.Context, id string, at time.Time, _ string) error {
+	s.events[id].Attempts++
+	s.events[id].dueAt = at
+	return nil
+}
+
+func (s *fakeStorage) DeleteOutboxEvent(_ context.Context, id string) error {
+	delete(s.events, id)
+	return nil
+}
+
+// fakeSink counts the events it got and fails the first failures sends
+type fakeSink struct {
+	failures int
+	got      map[string]int
+}
+
+func (s *fakeSink) Send(_ context.Context, event events.Event) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("broker is down")
+	}
+
+	s.got[event.ID]++
+
+	return nil
+}
+
+// fakeTargetSink sends to the targets, the failing ones fail once
+type fakeTargetSink struct {
+	fakeSink
+	targets  []string
+	failing  map[string]bool
+	attempts []int
+}
+
+func (s *fakeTargetSink) SendTargets(ctx context.Context, event events.Event, attempt int, progress Progress) error {
+	s.attempts = append(s.attempts, attempt)
+
+	var failed error
+
+	for _, target := range s.targets {
+		if progress.Done(target) {
+			continue
+		}
+
+		if s.failing[target] {
+			s.failing[target] = false
+			failed = errors.New("endpoint is down")
+
+			continue
+		}
+
+		s.got[target]++
+
+		if err := progress.Complete(ctx, target); err != nil {
+			return err
+		}
+	}
+
+	return failed
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+
+	s := &fakeStorage{events: map[string]*fakeEvent{}}
+
+	stable := &fakeSink{got: map[string]int{}}
+	flaky := &fakeSink{failures: 1, got: map[string]int{}}
+
+	var out bytes.Buffer
+
+	cfg := config.OutboxSettings{
+		BatchSize:      10,
+		Lease:          time.Minute,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+
+	r := NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, s, map[string]Sink{
+		"a": stable,
+		"b": flaky,
+		"c": NewWriterSink(&out),
+	})
+
+	publisher := NewPublisher(s)
+
+	event := events.New(constant.EventUserCreated, map[string]interface{}{"id": "42"})
+	if err := publisher.Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.Relay(ctx); got != 1 {
+		t.Fatalf("Expected 1 event in the first batch, got %d", got)
+	}
+
+	// the flaky sink failed, the event waits for the backoff
+	e, ok := s.events[event.ID]
+	if !ok {
+		t.Fatalf("Expected the event to stay in the outbox")
+	}
+
+	if e.Attempts != 1 {
+		t.Errorf("Expected 1 failed attempt, got %d", e.Attempts)
+	}
+
+	// the failed sink does not hold back the sink after it
+	if len(e.Sinks) != 2 || e.Sinks[0] != "a" || e.Sinks[1] != "c" {
+		t.Errorf("Expected sinks a and c to have the event, got %v", e.Sinks)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if got := r.Relay(ctx); got != 1 {
+		t.Fatalf("Expected 1 event in the second batch, got %d", got)
+	}
+
+	if _, ok := s.events[event.ID]; ok {
+		t.Errorf("Expected the event to be removed from the outbox")
+	}
+
+	data := []struct {
+		name string
+		sink *fakeSink
+	}{
+		{"a", stable},
+		{"b", flaky},
+	}
+
+	for _, d := range data {
+		if got := d.sink.got[event.ID]; got != 1 {
+			t.Errorf("Expected sink %s to get the event once, got %d", d.name, got)
+		}
+	}
+
+	var written events.Event
+
+	if err := json.Unmarshal(out.Bytes(), &written); err != nil {
+		t.Fatal(err)
+	}
+
+	if written.ID != event.ID || written.Type != constant.EventUserCreated {
+		t.Errorf("Expected %s %s, got %s %s", event.ID, event.Type, written.ID, written.Type)
+	}
+
+	if got := r.Relay(ctx); got != 0 {
+		t.Errorf("Expected an empty batch, got %d", got)
+	}
+}
+
+func TestRelayTargets(t *testing.T) {
+	ctx := context.Background()
+
+	s := &fakeStorage{events: map[string]*fakeEvent{}}
+
+	sink := &fakeTargetSink{
+		fakeSink: fakeSink{got: map[string]int{}},
+		targets:  []string{"1", "2"},
+		failing:  map[string]bool{"2": true},
+	}
+
+	cfg := config.OutboxSettings{
+		BatchSize:      10,
+		Lease:          time.Minute,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+
+	r := NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, s, map[string]Sink{constant.SinkWebhooks: sink})
+
+	event := events.New(constant.EventUserCreated, nil)
+	if err := NewPublisher(s).Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Relay(ctx)
+
+	e, ok := s.events[event.ID]
+	if !ok {
+		t.Fatalf("Expected the event to stay in the outbox until every target has it")
+	}
+
+	if len(e.Sinks) != 1 || e.Sinks[0] != constant.SinkWebhooks+":1" {
+		t.Errorf("Expected target 1 to have the event, got %v", e.Sinks)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	r.Relay(ctx)
+
+	if _, ok := s.events[event.ID]; ok {
+		t.Errorf("Expected the event to be removed from the outbox")
+	}
+
+	// the retry goes only to the failed target
+	if sink.got["1"] != 1 || sink.got["2"] != 1 {
+		t.Errorf("Expected every target to get the event once, got %v", sink.got)
+	}
+
+	if len(sink.attempts) != 2 || sink.attempts[0] != 1 || sink.attempts[1] != 2 {
+		t.Errorf("Expected attempts [1 2], got %v", sink.attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := config.OutboxSettings{InitialBackoff: 5 * time.Second, MaxBackoff: time.Minute}
+
+	data := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{5, time.Minute},
+		{1000, time.Minute},
+	}
+
+	for _, d := range data {
+		if got := Backoff(cfg, d.attempt); got != d.expected {
+			t.Errorf("Expected %v after attempt %d, got %v", d.expected, d.attempt, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"sync"
+	"time"
+)
+
+// sendTimeout bounds a send to a broker, the relay retries the event later
+const sendTimeout = 10 * time.Second
+
+// WriterSink writes the events as json lines, e.g. to stdout for a log collector
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Send(_ context.Context, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return json.NewEncoder(s.w).Encode(event)
+}
+
+// NATSSink publishes the events to <subject>.<event type>, the event id is the Nats-Msg-Id header
+// so that jetstream streams drop the copies
+type NATSSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewNATSSink(cfg config.NATSSettings) (*NATSSink, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Name("gas"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSSink{conn: conn, subject: cfg.Subject}, nil
+}
+
+func (s *NATSSink) Send(ctx context.Context, event events.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.subject + "." + event.Type)
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	msg.Data = body
+
+	if err := s.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	// the flush makes sure the server has the message before the event is marked as sent
+	return s.conn.FlushWithContext(ctx)
+}
+
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
+
+// KafkaSink writes the events to the topic of a kafka compatible broker, e.g. kafka or redpanda,
+// the message key is the event id
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaSink(cfg config.KafkaSettings) *KafkaSink {
+	return &KafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+func (s *KafkaSink) Send(ctx context.Context, event events.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.ID),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(event.Type)},
+		},
+		Time: event.CreatedAt,
+	})
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
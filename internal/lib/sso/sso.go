@@ -0,0 +1,216 @@
+package sso
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/degeboman/gas/internal/config"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Assertion is the validated statement of the identity provider about the user
+type Assertion struct {
+	ID     string
+	NameID string
+	// ExpiresAt is the time after which the assertion is not accepted anymore
+	ExpiresAt time.Time
+	// Attributes are keyed by both the name and the friendly name
+	Attributes map[string][]string
+}
+
+// ServiceProvider loads the identity provider metadata on the first use, so that gas starts while it is unavailable
+type ServiceProvider struct {
+	cfg config.SAMLSettings
+
+	mu sync.Mutex
+	sp saml.ServiceProvider
+}
+
+func New(cfg config.SAMLSettings) (*ServiceProvider, error) {
+	const op = "lib.sso.New"
+
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertificateFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, errors.New("key is not an rsa key"))
+	}
+
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	metadataURL, err := url.Parse(cfg.MetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	acsURL, err := url.Parse(cfg.ACSURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &ServiceProvider{
+		cfg: cfg,
+		sp: saml.ServiceProvider{
+			EntityID:          cfg.EntityID,
+			Key:               key,
+			Certificate:       certificate,
+			MetadataURL:       *metadataURL,
+			AcsURL:            *acsURL,
+			AllowIDPInitiated: cfg.AllowIDPInitiated,
+		},
+	}, nil
+}
+
+func (p *ServiceProvider) Metadata() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+}
+
+// AuthnRequest returns the url of the identity provider the user is redirected to and the id of the request
+func (p *ServiceProvider) AuthnRequest(ctx context.Context, relayState string) (string, string, error) {
+	const op = "lib.sso.ServiceProvider.AuthnRequest"
+
+	sp, err := p.serviceProvider(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := sp.MakeAuthenticationRequest(
+		sp.GetSSOBindingLocation(saml.HTTPRedirectBinding),
+		saml.HTTPRedirectBinding,
+		saml.HTTPPostBinding,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	redirectURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return redirectURL.String(), req.ID, nil
+}
+
+// ParseResponse checks the signature and the conditions of the base64 encoded response,
+// requestIDs are the ids of the requests the response can answer
+func (p *ServiceProvider) ParseResponse(ctx context.Context, samlResponse string, requestIDs []string) (Assertion, error) {
+	const op = "lib.sso.ServiceProvider.ParseResponse"
+
+	sp, err := p.serviceProvider(ctx)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	assertion, err := sp.ParseXMLResponse(raw, requestIDs)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+
+		return Assertion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := Assertion{
+		ID:         assertion.ID,
+		ExpiresAt:  expiresAt(assertion),
+		Attributes: make(map[string][]string),
+	}
+
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		result.NameID = assertion.Subject.NameID.Value
+	}
+
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			values := make([]string, 0, len(attribute.Values))
+			for _, v := range attribute.Values {
+				values = append(values, v.Value)
+			}
+
+			result.Attributes[attribute.Name] = values
+			if attribute.FriendlyName != "" {
+				result.Attributes[attribute.FriendlyName] = values
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// expiresAt is the earliest of the times the assertion is checked against, with the skew the checks allow
+func expiresAt(assertion *saml.Assertion) time.Time {
+	t := assertion.IssueInstant.Add(saml.MaxIssueDelay)
+
+	if assertion.Conditions != nil && !assertion.Conditions.NotOnOrAfter.IsZero() {
+		if notOnOrAfter := assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew); notOnOrAfter.Before(t) {
+			t = notOnOrAfter
+		}
+	}
+
+	return t
+}
+
+func (p *ServiceProvider) serviceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sp.IDPMetadata != nil {
+		return &p.sp, nil
+	}
+
+	var (
+		metadata *saml.EntityDescriptor
+		err      error
+	)
+
+	if p.cfg.IDPMetadataFile != "" {
+		var data []byte
+
+		if data, err = os.ReadFile(p.cfg.IDPMetadataFile); err != nil {
+			return nil, err
+		}
+
+		metadata, err = samlsp.ParseMetadata(data)
+	} else {
+		var metadataURL *url.URL
+
+		if metadataURL, err = url.Parse(p.cfg.IDPMetadataURL); err != nil {
+			return nil, err
+		}
+
+		metadata, err = samlsp.FetchMetadata(ctx, http.DefaultClient, *metadataURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.sp.IDPMetadata = metadata
+
+	return &p.sp, nil
+}
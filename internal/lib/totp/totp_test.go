@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// test vectors of RFC 6238 appendix B, the last six digits of the sha1 codes
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	data := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, d := range data {
+		code, err := Code(secret, time.Unix(d.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != d.expected {
+			t.Errorf("Expected %s at %d, got %s", d.expected, d.unix, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	code, err := Code(secret, now.Add(-Period))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Validate(secret, code, now, 1) {
+		t.Error("Expected the previous step code to be valid with skew 1")
+	}
+
+	if Validate(secret, code, now, 0) {
+		t.Error("Expected the previous step code to be invalid without skew")
+	}
+}
+
+func TestEmptySecret(t *testing.T) {
+	now := time.Now()
+
+	if _, err := Code("", now); err == nil {
+		t.Error("Expected no code for the empty secret")
+	}
+
+	// the code of an empty hmac key is known to everyone
+	code := hotp(nil, uint64(Step(now)))
+
+	data := []struct {
+		name   string
+		secret string
+	}{
+		{"empty", ""},
+		{"malformed", "not base32!"},
+	}
+
+	for _, d := range data {
+		if Validate(d.secret, code, now, 1) {
+			t.Errorf("%s: expected the code to be rejected", d.name)
+		}
+	}
+}
+
+func TestValidateStep(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	code, err := Code(secret, now.Add(Period))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := ValidateStep(secret, code, now, 1)
+	if !ok || step != Step(now)+1 {
+		t.Errorf("Expected step %d, got %d %v", Step(now)+1, step, ok)
+	}
+}
@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/lib/outbox"
+	"github.com/degeboman/gas/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Storage is where the webhooks and their delivery log are kept
+type Storage interface {
+	Webhooks(ctx context.Context) ([]storage.Webhook, error)
+	AddWebhookDelivery(ctx context.Context, delivery storage.WebhookDelivery) error
+}
+
+// Dispatcher posts events to the subscribed webhooks, every attempt is written to the delivery log. It is
+// the webhooks sink of the outbox relay, the event stays in the outbox until every webhook has it, so the
+// failed deliveries are retried with the backoff of the outbox and survive a restart
+type Dispatcher struct {
+	log     *slog.Logger
+	cfg     config.WebhookSettings
+	storage Storage
+	client  *http.Client
+}
+
+type delivery struct {
+	webhook storage.Webhook
+	event   events.Event
+	body    []byte
+	attempt int
+}
+
+func New(log *slog.Logger, cfg config.WebhookSettings, storage Storage) *Dispatcher {
+	return &Dispatcher{
+		log:     log.With(slog.String("component", "webhooks")),
+		cfg:     cfg,
+		storage: storage,
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Send posts the event once to every webhook subscribed to its type
+func (d *Dispatcher) Send(ctx context.Context, event events.Event) error {
+	return d.SendTargets(ctx, event, 1, nil)
+}
+
+// SendTargets posts the event to the subscribed webhooks that don't have it yet, the webhook is the target.
+// A webhook that fails max attempts of the event is given up on
+func (d *Dispatcher) SendTargets(ctx context.Context, event events.Event, attempt int, progress outbox.Progress) error {
+	const op = "lib.webhook.Dispatcher.SendTargets"
+
+	webhooks, err := d.storage.Webhooks(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+
+	workers := d.cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	sem := make(chan struct{}, workers)
+
+	for _, webhook := range webhooks {
+		if !Subscribed(webhook, event.Type) || progress != nil && progress.Done(webhook.ID) {
+			continue
+		}
+
+		dl := delivery{
+			webhook: webhook,
+			event:   event,
+			body:    body,
+			attempt: attempt,
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := d.deliver(ctx, dl, progress); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("webhook %s: %w", dl.webhook.ID, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// deliver returns the error of the attempt unless it is the last one
+func (d *Dispatcher) deliver(ctx context.Context, dl delivery, progress outbox.Progress) error {
+	start := time.Now()
+
+	status, err := d.send(ctx, dl)
+
+	d.record(dl, status, time.Since(start), err)
+
+	if err != nil && dl.attempt < d.cfg.MaxAttempts {
+		return err
+	}
+
+	if err != nil {
+		d.log.Warn("webhook delivery is given up",
+			slog.String("webhook_id", dl.webhook.ID),
+			slog.String("event_id", dl.event.ID),
+			slog.Int("attempts", dl.attempt),
+			sl.Err(err),
+		)
+	}
+
+	if progress == nil {
+		return nil
+	}
+
+	return progress.Complete(ctx, dl.webhook.ID)
+}
+
+func (d *Dispatcher) send(ctx context.Context, dl delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.webhook.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gas-webhooks/1")
+	req.Header.Set(constant.WebhookEventHeader, dl.event.Type)
+	req.Header.Set(constant.WebhookDeliveryHeader, dl.event.ID)
+	req.Header.Set(constant.WebhookSignatureHeader, Sign(dl.webhook.Secret, time.Now(), dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain a bit of the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(dl delivery, status int, duration time.Duration, err error) {
+	const op = "lib.webhook.Dispatcher.record"
+
+	entry := storage.WebhookDelivery{
+		WebhookID:  dl.webhook.ID,
+		EventID:    dl.event.ID,
+		EventType:  dl.event.Type,
+		Attempt:    dl.attempt,
+		StatusCode: status,
+		Succeeded:  err == nil,
+		Duration:   duration,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := d.storage.AddWebhookDelivery(ctx, entry); err != nil {
+		d.log.Error("failed to save webhook delivery",
+			slog.String("op", op),
+			slog.String("webhook_id", dl.webhook.ID),
+			slog.String("event_id", dl.event.ID),
+			sl.Err(err),
+		)
+	}
+}
+
+// Subscribed reports whether the webhook wants events of the type
+func Subscribed(webhook storage.Webhook, eventType string) bool {
+	for _, e := range webhook.Events {
+		if e == constant.EventAll || e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the signature header value of the body sent at the time
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
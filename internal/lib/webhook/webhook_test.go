@@ -0,0 +1,221 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeStorage struct {
+	webhooks []storage.Webhook
+
+	mu         sync.Mutex
+	deliveries []storage.WebhookDelivery
+}
+
+func (s *fakeStorage) Webhooks(_ context.Context) ([]storage.Webhook, error) {
+	return s.webhooks, nil
+}
+
+func (s *fakeStorage) AddWebhookDelivery(_ context.Context, delivery storage.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = append(s.deliveries, delivery)
+
+	return nil
+}
+
+func (s *fakeStorage) log() []storage.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]storage.WebhookDelivery(nil), s.deliveries...)
+}
+
+// fakeProgress keeps the targets that have the event
+type fakeProgress struct {
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+func (p *fakeProgress) Done(target string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.done[target]
+}
+
+func (p *fakeProgress) Complete(_ context.Context, target string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done[target] = true
+
+	return nil
+}
+
+func testSettings() config.WebhookSettings {
+	return config.WebhookSettings{
+		Workers:     2,
+		MaxAttempts: 3,
+		Timeout:     time.Second,
+	}
+}
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+
+	expected := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("whsec_secret", at, body); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	if got := Sign("whsec_other", at, body); got == expected {
+		t.Errorf("Expected the signature to depend on the secret")
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		signature := r.Header.Get(constant.WebhookSignatureHeader)
+		timestamp := strings.TrimPrefix(strings.Split(signature, ",")[0], "t=")
+
+		unix, _ := strconv.ParseInt(timestamp, 10, 64)
+
+		if Sign("whsec_secret", time.Unix(unix, 0), body) != signature {
+			t.Errorf("Expected a valid signature, got %s", signature)
+		}
+
+		if r.Header.Get(constant.WebhookEventHeader) != constant.EventUserCreated {
+			t.Errorf("Expected %s event header, got %s", constant.EventUserCreated, r.Header.Get(constant.WebhookEventHeader))
+		}
+
+		// the endpoint is down for the first two attempts
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := &fakeStorage{webhooks: []storage.Webhook{
+		{ID: "1", URL: srv.URL, Events: []string{constant.EventUserCreated}, Secret: "whsec_secret"},
+		{ID: "2", URL: srv.URL, Events: []string{constant.EventSessionRevoked}, Secret: "whsec_secret"},
+	}}
+
+	d := New(slog.New(slog.NewTextHandler(io.Discard, nil)), testSettings(), s)
+
+	event := events.New(constant.EventUserCreated, map[string]interface{}{"id": "42"})
+	progress := &fakeProgress{done: map[string]bool{}}
+
+	// the relay keeps the event and sends it again until the webhook has it
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := d.SendTargets(context.Background(), event, attempt, progress); err == nil {
+			t.Errorf("Expected the failed attempt %d to keep the event, got nil", attempt)
+		}
+	}
+
+	if err := d.SendTargets(context.Background(), event, 3, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !progress.Done("1") || progress.Done("2") {
+		t.Errorf("Expected only webhook 1 to have the event, got %v", progress.done)
+	}
+
+	// the webhook that has the event is skipped
+	if err := d.SendTargets(context.Background(), event, 4, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	log := s.log()
+	if len(log) != 3 {
+		t.Fatalf("Expected 3 deliveries, got %d", len(log))
+	}
+
+	data := []struct {
+		succeeded bool
+		status    int
+	}{
+		{false, http.StatusServiceUnavailable},
+		{false, http.StatusServiceUnavailable},
+		{true, http.StatusNoContent},
+	}
+
+	for i, d := range data {
+		if log[i].WebhookID != "1" {
+			t.Errorf("Expected delivery to webhook 1, got %s", log[i].WebhookID)
+		}
+
+		if log[i].Attempt != i+1 {
+			t.Errorf("Expected attempt %d, got %d", i+1, log[i].Attempt)
+		}
+
+		if log[i].Succeeded != d.succeeded || log[i].StatusCode != d.status {
+			t.Errorf("Expected %v with %d at attempt %d, got %v with %d", d.succeeded, d.status, i+1, log[i].Succeeded, log[i].StatusCode)
+		}
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := &fakeStorage{webhooks: []storage.Webhook{
+		{ID: "1", URL: srv.URL, Events: []string{constant.EventAll}, Secret: "whsec_secret"},
+	}}
+
+	cfg := testSettings()
+
+	d := New(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, s)
+
+	event := events.New(constant.EventUserDeleted, nil)
+	progress := &fakeProgress{done: map[string]bool{}}
+
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		err := d.SendTargets(context.Background(), event, attempt, progress)
+
+		// the last attempt gives up, so that the relay lets the event go
+		if last := attempt == cfg.MaxAttempts; (err == nil) != last {
+			t.Errorf("Attempt %d: expected the error only before the last attempt, got %v", attempt, err)
+		}
+	}
+
+	if !progress.Done("1") {
+		t.Errorf("Expected the webhook to be given up on")
+	}
+
+	if got := len(s.log()); got != cfg.MaxAttempts {
+		t.Errorf("Expected %d deliveries, got %d", cfg.MaxAttempts, got)
+	}
+}
@@ -0,0 +1,375 @@
+package cached
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/cache"
+	"github.com/degeboman/gas/internal/storage"
+	"strings"
+	"sync"
+)
+
+// Storage keeps the users found by id and email in the cache, every change of a user through it drops the user
+// from the cache. The changes made by other instances of gas are dropped when the broadcaster delivers them,
+// and seen when the cached user expires without one
+type Storage struct {
+	storage.Storage
+	users       *cache.Cache
+	broadcaster Broadcaster
+
+	// generation grows with every drop of a user, a read that saw it change caches nothing since
+	// the user it read may already be stale
+	mu         sync.Mutex
+	generation uint64
+
+	cancel  context.CancelFunc
+	stopped sync.WaitGroup
+}
+
+// Broadcaster shares the ids of the changed users between the instances of gas
+type Broadcaster interface {
+	PublishUserChange(ctx context.Context, id string) error
+	// SubscribeUserChanges calls drop with the ids published by any instance until ctx is done,
+	// reset is called on every subscription since the ids published without one are lost
+	SubscribeUserChanges(ctx context.Context, drop func(id string), reset func())
+}
+
+// txKey marks the ctx of a transaction, the users read in it may be rolled back and are not cached
+type txKey struct{}
+
+// changes are the users changed in a transaction, they are dropped again when it ends so that a user cached
+// by a concurrent read between the change and the commit does not stay stale
+type changes struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// New wraps the storage, broadcaster is nil when there is no other instance to share the changes with
+func New(s storage.Storage, users *cache.Cache, broadcaster Broadcaster) *Storage {
+	return &Storage{Storage: s, users: users, broadcaster: broadcaster}
+}
+
+// Start drops the users changed by the other instances from the cache until Stop
+func (s *Storage) Start() {
+	if s.broadcaster == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.stopped.Add(1)
+
+	go func() {
+		defer s.stopped.Done()
+
+		s.broadcaster.SubscribeUserChanges(ctx, s.drop, s.reset)
+	}()
+}
+
+func (s *Storage) Stop() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	s.stopped.Wait()
+}
+
+func (s *Storage) UserByID(ctx context.Context, id string) (storage.User, error) {
+	if inTransaction(ctx) {
+		return s.Storage.UserByID(ctx, id)
+	}
+
+	if user, ok := s.users.Get(idKey(id)); ok {
+		return cloneUser(user.(storage.User)), nil
+	}
+
+	generation := s.currentGeneration()
+
+	user, err := s.Storage.UserByID(ctx, id)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	s.remember(generation, "", user)
+
+	return user, nil
+}
+
+// UserByEmail remembers the id of the email and takes the user by it, so a change of the user drops both lookups
+func (s *Storage) UserByEmail(ctx context.Context, email string) (storage.User, error) {
+	if inTransaction(ctx) {
+		return s.Storage.UserByEmail(ctx, email)
+	}
+
+	if id, ok := s.users.Get(emailKey(email)); ok {
+		if user, ok := s.users.Get(idKey(id.(string))); ok {
+			return cloneUser(user.(storage.User)), nil
+		}
+	}
+
+	generation := s.currentGeneration()
+
+	user, err := s.Storage.UserByEmail(ctx, email)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	s.remember(generation, email, user)
+
+	return user, nil
+}
+
+func (s *Storage) UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.UpdateUserInfo(ctx, id, userInfo)
+}
+
+func (s *Storage) SetLocked(ctx context.Context, id string, locked bool) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.SetLocked(ctx, id, locked)
+}
+
+func (s *Storage) SetRoles(ctx context.Context, id string, roles []string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.SetRoles(ctx, id, roles)
+}
+
+func (s *Storage) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.SetPasswordResetRequired(ctx, id, required)
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, id string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.DeleteUser(ctx, id)
+}
+
+func (s *Storage) SetPassword(ctx context.Context, id, passwordHash string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.SetPassword(ctx, id, passwordHash)
+}
+
+func (s *Storage) SetPendingTOTPSecret(ctx context.Context, id, secret string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.SetPendingTOTPSecret(ctx, id, secret)
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, id, secret string, recoveryCodeHashes []string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.EnableTOTP(ctx, id, secret, recoveryCodeHashes)
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, id, recoveryCodeHash string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.UseRecoveryCode(ctx, id, recoveryCodeHash)
+}
+
+func (s *Storage) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.UseTOTPStep(ctx, id, step)
+}
+
+func (s *Storage) SetMFAMethod(ctx context.Context, id, method string) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.SetMFAMethod(ctx, id, method)
+}
+
+func (s *Storage) AddWebAuthnCredential(ctx context.Context, id string, credential storage.WebAuthnCredential) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.AddWebAuthnCredential(ctx, id, credential)
+}
+
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, id string, credentialID []byte, signCount uint32) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.UpdateWebAuthnSignCount(ctx, id, credentialID, signCount)
+}
+
+func (s *Storage) AddIdentity(ctx context.Context, id string, identity storage.Identity) error {
+	defer s.invalidate(ctx, id)
+
+	return s.Storage.AddIdentity(ctx, id, identity)
+}
+
+// Transaction runs fn in the transaction of the wrapped storage, or just runs it when there is none
+func (s *Storage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inTransaction(ctx) {
+		return s.transaction(ctx, fn)
+	}
+
+	c := &changes{}
+
+	defer func() {
+		for _, id := range c.ids {
+			s.drop(id)
+			s.publish(ctx, id)
+		}
+	}()
+
+	return s.transaction(context.WithValue(ctx, txKey{}, c), fn)
+}
+
+func (s *Storage) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if t, ok := s.Storage.(storage.Transactor); ok {
+		return t.Transaction(ctx, fn)
+	}
+
+	return fn(ctx)
+}
+
+// invalidate drops the user at once and, inside a transaction, once more when the transaction ends,
+// the other instances are told when the change is committed
+func (s *Storage) invalidate(ctx context.Context, id string) {
+	s.drop(id)
+
+	if c, ok := ctx.Value(txKey{}).(*changes); ok {
+		c.mu.Lock()
+		c.ids = append(c.ids, id)
+		c.mu.Unlock()
+
+		return
+	}
+
+	s.publish(ctx, id)
+}
+
+// publish tells the other instances about the change, if it fails they see the change when the user expires
+func (s *Storage) publish(ctx context.Context, id string) {
+	if s.broadcaster == nil {
+		return
+	}
+
+	_ = s.broadcaster.PublishUserChange(ctx, id)
+}
+
+// drop removes the user from the cache and stops the reads in flight from caching it again
+func (s *Storage) drop(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.users.Delete(idKey(id))
+}
+
+// reset removes every user from the cache and stops the reads in flight from caching them again
+func (s *Storage) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.users.Clear()
+}
+
+func (s *Storage) currentGeneration() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.generation
+}
+
+// remember caches a copy of the user read at the generation unless a user was dropped since,
+// the email is empty when the user was read by id
+func (s *Storage) remember(generation uint64, email string, user storage.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.generation != generation {
+		return
+	}
+
+	if email != "" {
+		s.users.Set(emailKey(email), user.ID)
+	}
+
+	s.users.Set(idKey(user.ID), cloneUser(user))
+}
+
+// cloneUser copies everything the user refers to, so that neither the callers nor the cache see the changes of the other
+func cloneUser(user storage.User) storage.User {
+	user.UserInfo = cloneValue(user.UserInfo)
+	user.Roles = cloneStrings(user.Roles)
+	user.RecoveryCodeHashes = cloneStrings(user.RecoveryCodeHashes)
+	user.Identities = append([]storage.Identity(nil), user.Identities...)
+
+	if user.WebAuthnCredentials != nil {
+		credentials := make([]storage.WebAuthnCredential, len(user.WebAuthnCredentials))
+
+		for i, credential := range user.WebAuthnCredentials {
+			credential.ID = cloneBytes(credential.ID)
+			credential.PublicKey = cloneBytes(credential.PublicKey)
+			credential.Transports = cloneStrings(credential.Transports)
+			credential.AAGUID = cloneBytes(credential.AAGUID)
+			credentials[i] = credential
+		}
+
+		user.WebAuthnCredentials = credentials
+	}
+
+	return user
+}
+
+// cloneValue copies the maps and slices of decoded json, the user info of every storage is made of them
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = cloneValue(item)
+		}
+
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = cloneValue(item)
+		}
+
+		return items
+	default:
+		return value
+	}
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+
+	return append([]string{}, values...)
+}
+
+func cloneBytes(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+
+	return append([]byte{}, value...)
+}
+
+func inTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*changes)
+
+	return ok
+}
+
+func idKey(id string) string {
+	return "id:" + id
+}
+
+// emailKey is case insensitive like the lookups of the storages
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
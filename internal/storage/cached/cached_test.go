@@ -0,0 +1,266 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/cache"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/degeboman/gas/internal/storage/redis"
+	"github.com/degeboman/gas/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New(memory.New(), cache.New(100, time.Minute), nil)
+	})
+}
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+	s := New(memory.New(), cache.New(100, time.Minute), nil)
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both lookups are cached before the changes
+	if _, err := s.UserByID(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UserByEmail(ctx, "Rupychman@mail.ru"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetLocked(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rollback := errors.New("rollback")
+
+	err = s.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.SetRoles(ctx, id, []string{"admin"}); err != nil {
+			return err
+		}
+
+		// the uncommitted roles are read in the transaction but not cached
+		user, err := s.UserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(user.Roles) != 1 {
+			t.Errorf("Expected the roles in the transaction, got %v", user.Roles)
+		}
+
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Expected %v, got %v", rollback, err)
+	}
+
+	data := []struct {
+		name   string
+		lookup func() (storage.User, error)
+	}{
+		{"by id", func() (storage.User, error) { return s.UserByID(ctx, id) }},
+		{"by email", func() (storage.User, error) { return s.UserByEmail(ctx, "rupychman@mail.ru") }},
+	}
+
+	for _, d := range data {
+		user, err := d.lookup()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !user.Locked || len(user.Roles) != 0 {
+			t.Errorf("%s: expected locked user without roles, got %v %v", d.name, user.Locked, user.Roles)
+		}
+	}
+
+	if err := s.DeleteUser(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UserByEmail(ctx, "rupychman@mail.ru"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected %v, got %v", storage.ErrNotFound, err)
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	ctx := context.Background()
+
+	rdb, err := redis.New(ctx, config.RedisSettings{KeyPrefix: "gas:", Embedded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	// two replicas of gas share the database and redis
+	db := memory.New()
+
+	replica := New(db, cache.New(100, time.Minute), rdb)
+	replica.Start()
+	defer replica.Stop()
+
+	other := New(db, cache.New(100, time.Minute), rdb)
+
+	id, err := other.CreateUser(ctx, "rupychman@mail.ru", "hash", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := replica.UserByEmail(ctx, "rupychman@mail.ru"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.SetLocked(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		user, err := replica.UserByEmail(ctx, "rupychman@mail.ru")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.Locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the change of the other replica to drop the cached user")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCopies(t *testing.T) {
+	ctx := context.Background()
+	s := New(memory.New(), cache.New(100, time.Minute), nil)
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", map[string]interface{}{"name": "rupychman"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetRoles(ctx, id, []string{"user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	credential := storage.WebAuthnCredential{ID: []byte("credential"), Transports: []string{"usb"}}
+
+	if err := s.AddWebAuthnCredential(ctx, id, credential); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first read fills the cache and the second one is served by it
+	for i := 0; i < 2; i++ {
+		user, err := s.UserByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user.Roles[0] = "admin"
+		user.UserInfo.(map[string]interface{})["name"] = "changed"
+		user.WebAuthnCredentials[0].ID[0] = 'x'
+		user.WebAuthnCredentials[0].Transports[0] = "nfc"
+	}
+
+	user, err := s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Roles[0] != "user" {
+		t.Errorf("Expected %v, got %v", "user", user.Roles[0])
+	}
+	if name := user.UserInfo.(map[string]interface{})["name"]; name != "rupychman" {
+		t.Errorf("Expected %v, got %v", "rupychman", name)
+	}
+	if got := user.WebAuthnCredentials[0]; string(got.ID) != "credential" || got.Transports[0] != "usb" {
+		t.Errorf("Expected %s %v, got %s %v", "credential", []string{"usb"}, got.ID, got.Transports)
+	}
+}
+
+// racingStorage changes the user through changer after reading it, like a write that ends while the read is in flight
+type racingStorage struct {
+	storage.Storage
+	changer storage.Storage
+	change  func(changer storage.Storage, id string)
+}
+
+func (s *racingStorage) UserByID(ctx context.Context, id string) (storage.User, error) {
+	user, err := s.Storage.UserByID(ctx, id)
+
+	if s.change != nil {
+		change := s.change
+		s.change = nil
+		change(s.changer, id)
+	}
+
+	return user, err
+}
+
+func (s *racingStorage) UserByEmail(ctx context.Context, email string) (storage.User, error) {
+	user, err := s.Storage.UserByEmail(ctx, email)
+
+	if s.change != nil && err == nil {
+		change := s.change
+		s.change = nil
+		change(s.changer, user.ID)
+	}
+
+	return user, err
+}
+
+func TestStaleRead(t *testing.T) {
+	ctx := context.Background()
+
+	lock := func(changer storage.Storage, id string) {
+		if err := changer.SetLocked(ctx, id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := []struct {
+		name   string
+		lookup func(s *Storage, id string) (storage.User, error)
+	}{
+		{"by id", func(s *Storage, id string) (storage.User, error) { return s.UserByID(ctx, id) }},
+		{"by email", func(s *Storage, id string) (storage.User, error) { return s.UserByEmail(ctx, "rupychman@mail.ru") }},
+	}
+
+	for _, d := range data {
+		backend := &racingStorage{Storage: memory.New()}
+		s := New(backend, cache.New(100, time.Minute), nil)
+		backend.changer = s
+
+		id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		backend.change = lock
+
+		// the read returns the user from before the change but must not cache it
+		if _, err := d.lookup(s, id); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := d.lookup(s, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !user.Locked {
+			t.Errorf("%s: expected the locked user, got the one cached by the stale read", d.name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"sort"
+	"time"
+)
+
+var errAPIKeyNotFound = fmt.Errorf("api key %w", storage.ErrNotFound)
+
+func cloneAPIKey(key storage.APIKey) storage.APIKey {
+	key.Scopes = append([]string{}, key.Scopes...)
+	key.ExpiresAt = utcPtr(key.ExpiresAt)
+	key.LastUsedAt = utcPtr(key.LastUsedAt)
+	key.RevokedAt = utcPtr(key.RevokedAt)
+
+	return key
+}
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key storage.APIKey) error {
+	defer s.lock(ctx)()
+
+	if _, ok := s.state.apiKeys[key.ID]; ok {
+		return errors.New("api key already exists")
+	}
+
+	s.state.apiKeys[key.ID] = cloneAPIKey(key)
+
+	return nil
+}
+
+func (s *Storage) APIKeyByID(ctx context.Context, id string) (storage.APIKey, error) {
+	defer s.lock(ctx)()
+
+	key, ok := s.state.apiKeys[id]
+	if !ok {
+		return storage.APIKey{}, errAPIKeyNotFound
+	}
+
+	return cloneAPIKey(key), nil
+}
+
+func (s *Storage) APIKeys(ctx context.Context, ownerID string) ([]storage.APIKey, error) {
+	defer s.lock(ctx)()
+
+	keys := make([]storage.APIKey, 0)
+	for _, key := range s.state.apiKeys {
+		if key.OwnerID == ownerID {
+			keys = append(keys, cloneAPIKey(key))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	return keys, nil
+}
+
+func (s *Storage) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	defer s.lock(ctx)()
+
+	key, ok := s.state.apiKeys[id]
+	if !ok {
+		return nil
+	}
+
+	key = cloneAPIKey(key)
+	key.LastUsedAt = utcPtr(&usedAt)
+
+	s.state.apiKeys[id] = key
+
+	return nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, ownerID, id string, revokedAt time.Time) error {
+	defer s.lock(ctx)()
+
+	key, ok := s.state.apiKeys[id]
+	if !ok || key.OwnerID != ownerID || key.RevokedAt != nil {
+		return errAPIKeyNotFound
+	}
+
+	key = cloneAPIKey(key)
+	key.RevokedAt = utcPtr(&revokedAt)
+
+	s.state.apiKeys[id] = key
+
+	return nil
+}
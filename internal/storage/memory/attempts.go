@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+	"sync"
+	"time"
+)
+
+type attemptsEntry struct {
+	storage.Attempts
+	expiresAt time.Time
+}
+
+type AttemptsStorage struct {
+	mu       sync.Mutex
+	attempts map[string]attemptsEntry
+}
+
+func NewAttemptsStorage() *AttemptsStorage {
+	return &AttemptsStorage{
+		attempts: make(map[string]attemptsEntry),
+	}
+}
+
+func (s *AttemptsStorage) FailedAttempts(_ context.Context, key string) (storage.Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entry(key).Attempts, nil
+}
+
+func (s *AttemptsStorage) AddFailedAttempt(_ context.Context, key string, window time.Duration) (storage.Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(key)
+	e.Failures++
+	e.expiresAt = later(time.Now().Add(window), e.LockedUntil)
+
+	s.attempts[key] = e
+
+	return e.Attempts, nil
+}
+
+func (s *AttemptsStorage) LockAttempts(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(key)
+	e.LockedUntil = until
+	e.expiresAt = later(e.expiresAt, until)
+
+	s.attempts[key] = e
+
+	return nil
+}
+
+func (s *AttemptsStorage) ResetAttempts(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
+
+// entry returns the actual entry dropping the expired one, s.mu must be held
+func (s *AttemptsStorage) entry(key string) attemptsEntry {
+	e, ok := s.attempts[key]
+	if !ok {
+		return attemptsEntry{}
+	}
+
+	if time.Now().After(e.expiresAt) {
+		delete(s.attempts, key)
+		return attemptsEntry{}
+	}
+
+	return e
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
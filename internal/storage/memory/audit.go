@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+func (s *Storage) LastAuditEntry(ctx context.Context) (storage.AuditEntry, error) {
+	defer s.lock(ctx)()
+
+	if len(s.state.audit) == 0 {
+		return storage.AuditEntry{}, nil
+	}
+
+	return s.state.audit[len(s.state.audit)-1], nil
+}
+
+func (s *Storage) AddAuditEntry(ctx context.Context, entry storage.AuditEntry) error {
+	defer s.lock(ctx)()
+
+	// the entries are appended in the order of the sequence numbers
+	if n := len(s.state.audit); n > 0 && s.state.audit[n-1].Seq >= entry.Seq {
+		return storage.ErrAuditSeqTaken
+	}
+
+	s.state.audit = append(s.state.audit, entry)
+
+	return nil
+}
+
+func (s *Storage) AuditEntries(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	defer s.lock(ctx)()
+
+	match := func(e storage.AuditEntry) bool {
+		switch {
+		case filter.Action != "" && e.Action != filter.Action:
+		case filter.ActorID != "" && e.ActorID != filter.ActorID:
+		case filter.Target != "" && e.Target != filter.Target:
+		case filter.Outcome != "" && e.Outcome != filter.Outcome:
+		case filter.AfterSeq > 0 && e.Seq <= filter.AfterSeq:
+		case filter.BeforeSeq > 0 && e.Seq >= filter.BeforeSeq:
+		case !filter.Since.IsZero() && e.Time.Before(filter.Since):
+		case !filter.Until.IsZero() && !e.Time.Before(filter.Until):
+		default:
+			return true
+		}
+
+		return false
+	}
+
+	entries := make([]storage.AuditEntry, 0)
+
+	for i := range s.state.audit {
+		e := s.state.audit[i]
+		if filter.Descending {
+			e = s.state.audit[len(s.state.audit)-1-i]
+		}
+
+		if !match(e) {
+			continue
+		}
+
+		entries = append(entries, e)
+
+		if filter.Limit > 0 && int64(len(entries)) == filter.Limit {
+			break
+		}
+	}
+
+	return entries, nil
+}
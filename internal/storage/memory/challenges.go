@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"sync"
+	"time"
+)
+
+var errChallengeNotFound = fmt.Errorf("challenge %w", storage.ErrNotFound)
+
+type challenge struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+type ChallengeStorage struct {
+	mu         sync.Mutex
+	challenges map[string]challenge
+}
+
+func NewChallengeStorage() *ChallengeStorage {
+	return &ChallengeStorage{
+		challenges: make(map[string]challenge),
+	}
+}
+
+func (s *ChallengeStorage) SaveChallenge(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.save(id, data, ttl)
+
+	return nil
+}
+
+func (s *ChallengeStorage) SaveChallengeOnce(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.challenges[id]; ok && !time.Now().After(c.expiresAt) {
+		return storage.ErrChallengeExists
+	}
+
+	s.save(id, data, ttl)
+
+	return nil
+}
+
+func (s *ChallengeStorage) save(id string, data []byte, ttl time.Duration) {
+	now := time.Now()
+
+	// expired challenges are dropped on write since nobody is going to take them
+	for k, c := range s.challenges {
+		if now.After(c.expiresAt) {
+			delete(s.challenges, k)
+		}
+	}
+
+	s.challenges[id] = challenge{
+		data:      data,
+		expiresAt: now.Add(ttl),
+	}
+}
+
+func (s *ChallengeStorage) TakeChallenge(_ context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.challenges[id]
+	if !ok {
+		return nil, errChallengeNotFound
+	}
+
+	delete(s.challenges, id)
+
+	if time.Now().After(c.expiresAt) {
+		return nil, errChallengeNotFound
+	}
+
+	return c.data, nil
+}
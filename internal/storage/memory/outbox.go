@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"sort"
+	"time"
+)
+
+type outboxEntry struct {
+	storage.OutboxEvent
+	DueAt     time.Time
+	LastError string
+}
+
+func (s *Storage) AddOutboxEvent(ctx context.Context, event events.Event) error {
+	defer s.lock(ctx)()
+
+	s.state.outbox[event.ID] = outboxEntry{
+		OutboxEvent: storage.OutboxEvent{Event: event, Sinks: []string{}},
+		DueAt:       event.CreatedAt,
+	}
+
+	return nil
+}
+
+func (s *Storage) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]storage.OutboxEvent, error) {
+	defer s.lock(ctx)()
+
+	now := time.Now()
+
+	var due []outboxEntry
+	for _, e := range s.state.outbox {
+		if !e.DueAt.After(now) {
+			due = append(due, e)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Event.CreatedAt.Before(due[j].Event.CreatedAt) })
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]storage.OutboxEvent, 0, len(due))
+	for _, e := range due {
+		e.DueAt = now.Add(lease)
+		s.state.outbox[e.Event.ID] = e
+
+		e.Sinks = append([]string{}, e.Sinks...)
+		claimed = append(claimed, e.OutboxEvent)
+	}
+
+	return claimed, nil
+}
+
+func (s *Storage) CompleteOutboxSink(ctx context.Context, id, sink string) error {
+	defer s.lock(ctx)()
+
+	e, ok := s.state.outbox[id]
+	if !ok {
+		return nil
+	}
+
+	for _, done := range e.Sinks {
+		if done == sink {
+			return nil
+		}
+	}
+
+	e.Sinks = append(append([]string{}, e.Sinks...), sink)
+	s.state.outbox[id] = e
+
+	return nil
+}
+
+func (s *Storage) RetryOutboxEvent(ctx context.Context, id string, at time.Time, lastError string) error {
+	defer s.lock(ctx)()
+
+	e, ok := s.state.outbox[id]
+	if !ok {
+		return nil
+	}
+
+	e.Attempts++
+	e.DueAt = at
+	e.LastError = lastError
+	s.state.outbox[id] = e
+
+	return nil
+}
+
+func (s *Storage) DeleteOutboxEvent(ctx context.Context, id string) error {
+	defer s.lock(ctx)()
+
+	delete(s.state.outbox, id)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// fullAt is the time the bucket is full again and can be forgotten
+	fullAt time.Time
+}
+
+type RateLimitStorage struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	sweptAt time.Time
+}
+
+func NewRateLimitStorage() *RateLimitStorage {
+	return &RateLimitStorage{
+		buckets: make(map[string]bucket),
+		sweptAt: time.Now(),
+	}
+}
+
+func (s *RateLimitStorage) Take(_ context.Context, key string, rate storage.Rate) (storage.RateLimitResult, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(rate.Limit), updatedAt: now}
+	}
+
+	b, res := takeToken(b, rate, now)
+	s.buckets[key] = b
+
+	return res, nil
+}
+
+// sweep removes the buckets that are full already, s.mu must be held
+func (s *RateLimitStorage) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < sweepInterval {
+		return
+	}
+
+	for key, b := range s.buckets {
+		if now.After(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.sweptAt = now
+}
+
+func takeToken(b bucket, rate storage.Rate, now time.Time) (bucket, storage.RateLimitResult) {
+	limit := float64(rate.Limit)
+	perToken := rate.Period / time.Duration(rate.Limit)
+
+	b.tokens = math.Min(limit, b.tokens+float64(now.Sub(b.updatedAt))/float64(perToken))
+	b.updatedAt = now
+
+	var res storage.RateLimitResult
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = time.Duration((limit - b.tokens) * float64(perToken))
+	b.fullAt = now.Add(res.ResetAfter)
+
+	return b, res
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"github.com/degeboman/gas/internal/storage"
+	"testing"
+	"time"
+)
+
+func Test_takeToken(t *testing.T) {
+	rate := storage.Rate{Limit: 2, Period: 2 * time.Second}
+	now := time.Now()
+
+	b := bucket{tokens: 2, updatedAt: now}
+
+	data := []struct {
+		name       string
+		after      time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"first", 0, true, 1, 0},
+		{"second", 0, true, 0, 0},
+		{"empty", 0, false, 0, time.Second},
+		{"half refilled", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"refilled", 500 * time.Millisecond, true, 0, 0},
+	}
+
+	for _, d := range data {
+		now = now.Add(d.after)
+
+		var res storage.RateLimitResult
+		b, res = takeToken(b, rate, now)
+
+		if res.Allowed != d.allowed || res.Remaining != d.remaining || res.RetryAfter != d.retryAfter {
+			t.Errorf("%s: expected %v/%d/%v, got %v/%d/%v", d.name,
+				d.allowed, d.remaining, d.retryAfter, res.Allowed, res.Remaining, res.RetryAfter)
+		}
+	}
+}
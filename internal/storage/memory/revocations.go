@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RevocationStorage struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewRevocationStorage() *RevocationStorage {
+	return &RevocationStorage{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *RevocationStorage) Revoke(_ context.Context, id string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// the ids of expired tokens are dropped on write since the tokens are rejected anyway
+	for k, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, k)
+		}
+	}
+
+	s.revoked[id] = now.Add(ttl)
+
+	return nil
+}
+
+func (s *RevocationStorage) IsRevoked(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.revoked[id]
+
+	return ok && time.Now().Before(expiresAt), nil
+}
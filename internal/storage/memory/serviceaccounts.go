@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"sort"
+)
+
+var errServiceAccountNotFound = fmt.Errorf("service account %w", storage.ErrNotFound)
+
+func (s *Storage) CreateServiceAccount(ctx context.Context, account storage.ServiceAccount) (string, error) {
+	defer s.lock(ctx)()
+
+	account.ID = s.newID()
+	account.Roles = append([]string{}, account.Roles...)
+
+	s.state.serviceAccounts[account.ID] = account
+
+	return account.ID, nil
+}
+
+func (s *Storage) ServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error) {
+	defer s.lock(ctx)()
+
+	accounts := make([]storage.ServiceAccount, 0, len(s.state.serviceAccounts))
+	for _, account := range s.state.serviceAccounts {
+		account.Roles = append([]string{}, account.Roles...)
+		accounts = append(accounts, account)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt.After(accounts[j].CreatedAt) })
+
+	return accounts, nil
+}
+
+func (s *Storage) ServiceAccountByID(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	defer s.lock(ctx)()
+
+	account, ok := s.state.serviceAccounts[id]
+	if !ok {
+		return storage.ServiceAccount{}, errServiceAccountNotFound
+	}
+
+	account.Roles = append([]string{}, account.Roles...)
+
+	return account, nil
+}
+
+func (s *Storage) DeleteServiceAccount(ctx context.Context, id string) error {
+	defer s.lock(ctx)()
+
+	if _, ok := s.state.serviceAccounts[id]; !ok {
+		return errServiceAccountNotFound
+	}
+
+	delete(s.state.serviceAccounts, id)
+
+	for keyID, key := range s.state.apiKeys {
+		if key.OwnerID == id {
+			delete(s.state.apiKeys, keyID)
+		}
+	}
+
+	return nil
+}
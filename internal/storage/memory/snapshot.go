@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshot is the state kept in the snapshot file, the lockout counters and the ceremonies are short-lived
+// and are not saved. The records have the fields of the storage types the json of the api hides
+type snapshot struct {
+	NextID          int64                     `json:"next_id"`
+	Users           []userRecord              `json:"users"`
+	ServiceAccounts []storage.ServiceAccount  `json:"service_accounts"`
+	APIKeys         []apiKeyRecord            `json:"api_keys"`
+	Webhooks        []webhookRecord           `json:"webhooks"`
+	Deliveries      []storage.WebhookDelivery `json:"webhook_deliveries"`
+	Outbox          []outboxEntry             `json:"outbox"`
+	Audit           []storage.AuditEntry      `json:"audit_log"`
+	RevokedTokens   map[string]time.Time      `json:"revoked_tokens"`
+}
+
+type userRecord struct {
+	ID                    string                       `json:"id"`
+	Email                 string                       `json:"email"`
+	PasswordHash          string                       `json:"password_hash"`
+	UserInfo              interface{}                  `json:"user_info"`
+	Roles                 []string                     `json:"roles"`
+	Verified              bool                         `json:"verified"`
+	Locked                bool                         `json:"locked"`
+	PasswordResetRequired bool                         `json:"password_reset_required"`
+	MFAEnabled            bool                         `json:"mfa_enabled"`
+	MFAMethod             string                       `json:"mfa_method"`
+	TOTPSecret            string                       `json:"totp_secret"`
+	PendingTOTPSecret     string                       `json:"totp_pending_secret"`
+	TOTPLastStep          int64                        `json:"totp_last_step"`
+	RecoveryCodeHashes    []string                     `json:"recovery_codes"`
+	WebAuthnCredentials   []storage.WebAuthnCredential `json:"webauthn_credentials"`
+	Identities            []storage.Identity           `json:"identities"`
+	CreatedAt             time.Time                    `json:"created_at"`
+}
+
+type apiKeyRecord struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"`
+	OwnerType  string     `json:"owner_type"`
+	Scopes     []string   `json:"scopes"`
+	Hash       string     `json:"hash"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type webhookRecord struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Secret      string    `json:"secret"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Open returns the storage with the state of the snapshot file, the state is saved to the file
+// every interval and on Close. A missing file is an empty storage, an empty path turns the snapshots off
+func Open(path string, interval time.Duration) (*Storage, error) {
+	const op = "storage.memory.Open"
+
+	s := New()
+	s.snapshotPath = path
+
+	if path == "" {
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if interval > 0 {
+		s.stop = make(chan struct{})
+		s.stopped = make(chan struct{})
+
+		go s.saveEvery(interval)
+	}
+
+	return s, nil
+}
+
+// Close saves the last snapshot
+func (s *Storage) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.stopped
+	}
+
+	return s.Save()
+}
+
+func (s *Storage) saveEvery(interval time.Duration) {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			// a failed save is retried on the next tick and on Close, which reports the error
+			_ = s.Save()
+		}
+	}
+}
+
+// Save writes the state to the snapshot file, the file is replaced at once so that a crash keeps the previous one
+func (s *Storage) Save() error {
+	const op = "storage.memory.Save"
+
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the temporary file is readable by the owner only, the snapshot has password hashes and secrets
+	tmp, err := os.CreateTemp(filepath.Dir(s.snapshotPath), filepath.Base(s.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.snapshotPath); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) snapshot() snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := snapshot{
+		NextID:        s.state.nextID,
+		Deliveries:    s.state.deliveries,
+		Audit:         s.state.audit,
+		RevokedTokens: make(map[string]time.Time),
+	}
+
+	for _, user := range s.state.users {
+		snap.Users = append(snap.Users, userRecord(user))
+	}
+
+	for _, account := range s.state.serviceAccounts {
+		snap.ServiceAccounts = append(snap.ServiceAccounts, account)
+	}
+
+	for _, key := range s.state.apiKeys {
+		snap.APIKeys = append(snap.APIKeys, apiKeyRecord(key))
+	}
+
+	for _, webhook := range s.state.webhooks {
+		snap.Webhooks = append(snap.Webhooks, webhookRecord(webhook))
+	}
+
+	for _, e := range s.state.outbox {
+		snap.Outbox = append(snap.Outbox, e)
+	}
+
+	s.RevocationStorage.mu.Lock()
+	defer s.RevocationStorage.mu.Unlock()
+
+	for id, expiresAt := range s.RevocationStorage.revoked {
+		snap.RevokedTokens[id] = expiresAt
+	}
+
+	return snap
+}
+
+func (s *Storage) load() error {
+	b, err := os.ReadFile(s.snapshotPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	var snap snapshot
+
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.nextID = snap.NextID
+	s.state.deliveries = snap.Deliveries
+	s.state.audit = snap.Audit
+
+	for _, record := range snap.Users {
+		s.state.users[record.ID] = withMFAMethod(storage.User(record))
+	}
+
+	for _, account := range snap.ServiceAccounts {
+		s.state.serviceAccounts[account.ID] = account
+	}
+
+	for _, key := range snap.APIKeys {
+		s.state.apiKeys[key.ID] = storage.APIKey(key)
+	}
+
+	for _, webhook := range snap.Webhooks {
+		s.state.webhooks[webhook.ID] = storage.Webhook(webhook)
+	}
+
+	for _, e := range snap.Outbox {
+		s.state.outbox[e.Event.ID] = e
+	}
+
+	s.RevocationStorage.mu.Lock()
+	defer s.RevocationStorage.mu.Unlock()
+
+	for id, expiresAt := range snap.RevokedTokens {
+		s.RevocationStorage.revoked[id] = expiresAt
+	}
+
+	return nil
+}
+
+// withMFAMethod sets the second factor of the users saved before it was kept, two-factor authentication
+// without a usable factor is turned off like the migrations of the databases do
+func withMFAMethod(user storage.User) storage.User {
+	if !user.MFAEnabled || user.MFAMethod != "" {
+		return user
+	}
+
+	switch {
+	case user.TOTPSecret != "":
+		user.MFAMethod = constant.MFAMethodTOTP
+	case len(user.WebAuthnCredentials) > 0:
+		user.MFAMethod = constant.MFAMethodWebAuthn
+	default:
+		user.MFAEnabled = false
+	}
+
+	return user
+}
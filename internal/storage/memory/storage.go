@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Storage keeps users and the rest of gas state in memory, it is the storage backend of tests and local runs.
+// The state can be saved to a snapshot file and loaded from it on the next start
+type Storage struct {
+	*AttemptsStorage
+	*ChallengeStorage
+	*RevocationStorage
+
+	mu    sync.Mutex
+	state state
+
+	snapshotPath string
+	stop         chan struct{}
+	stopped      chan struct{}
+}
+
+type state struct {
+	nextID          int64
+	users           map[string]storage.User
+	serviceAccounts map[string]storage.ServiceAccount
+	apiKeys         map[string]storage.APIKey
+	webhooks        map[string]storage.Webhook
+	deliveries      []storage.WebhookDelivery
+	outbox          map[string]outboxEntry
+	audit           []storage.AuditEntry
+}
+
+// clone copies the collections, the stored values are replaced on update and never changed in place
+func (s state) clone() state {
+	c := newState()
+
+	c.nextID = s.nextID
+
+	for k, v := range s.users {
+		c.users[k] = v
+	}
+
+	for k, v := range s.serviceAccounts {
+		c.serviceAccounts[k] = v
+	}
+
+	for k, v := range s.apiKeys {
+		c.apiKeys[k] = v
+	}
+
+	for k, v := range s.webhooks {
+		c.webhooks[k] = v
+	}
+
+	for k, v := range s.outbox {
+		c.outbox[k] = v
+	}
+
+	c.deliveries = append([]storage.WebhookDelivery(nil), s.deliveries...)
+	c.audit = append([]storage.AuditEntry(nil), s.audit...)
+
+	return c
+}
+
+func newState() state {
+	return state{
+		users:           make(map[string]storage.User),
+		serviceAccounts: make(map[string]storage.ServiceAccount),
+		apiKeys:         make(map[string]storage.APIKey),
+		webhooks:        make(map[string]storage.Webhook),
+		outbox:          make(map[string]outboxEntry),
+	}
+}
+
+// New returns an empty storage
+func New() *Storage {
+	return &Storage{
+		AttemptsStorage:   NewAttemptsStorage(),
+		ChallengeStorage:  NewChallengeStorage(),
+		RevocationStorage: NewRevocationStorage(),
+		state:             newState(),
+	}
+}
+
+type txKey struct{}
+
+// lock locks the storage unless ctx is in a transaction of the storage, the transaction holds the lock then
+func (s *Storage) lock(ctx context.Context) func() {
+	if tx, _ := ctx.Value(txKey{}).(*Storage); tx == s {
+		return func() {}
+	}
+
+	s.mu.Lock()
+
+	return s.mu.Unlock
+}
+
+// Transaction runs fn holding the lock of the storage and rolls the changes back if fn fails.
+// fn must make its storage calls with the ctx it gets
+func (s *Storage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, _ := ctx.Value(txKey{}).(*Storage); tx == s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup := s.state.clone()
+
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		s.state = backup
+
+		return err
+	}
+
+	return nil
+}
+
+// SupportsTransactions reports whether the writes of Transaction are atomic
+func (s *Storage) SupportsTransactions() bool {
+	return true
+}
+
+// newID returns the next id of a user, a service account or a webhook, s.mu must be held
+func (s *Storage) newID() string {
+	s.state.nextID++
+
+	return strconv.FormatInt(s.state.nextID, 10)
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+
+	return &utc
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/storagetest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+
+	err = s.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.SetLocked(ctx, id, true); err != nil {
+			return err
+		}
+
+		if _, err := s.CreateUser(ctx, "degeboman@mail.ru", "hash", nil); err != nil {
+			return err
+		}
+
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Expected %v, got %v", failed, err)
+	}
+
+	user, err := s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Locked {
+		t.Errorf("Expected the lock to be rolled back")
+	}
+
+	if err := s.DoesEmailExist(ctx, "degeboman@mail.ru"); err != nil {
+		t.Errorf("Expected the created user to be rolled back, got %v", err)
+	}
+
+	if err := s.DoesEmailExist(ctx, "Rupychman@Mail.ru"); err == nil {
+		t.Errorf("Expected the email to be taken in any case")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "gas.json")
+
+	s, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", map[string]interface{}{"name": "rupychman"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CreateAPIKey(ctx, storage.APIKey{ID: "key", OwnerID: id, Hash: "key hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke(ctx, "token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.PasswordHash != "hash" || user.UserInfo.(map[string]interface{})["name"] != "rupychman" {
+		t.Errorf("Expected the user to be restored, got %v", user)
+	}
+
+	if key, err := s.APIKeyByID(ctx, "key"); err != nil || key.Hash != "key hash" {
+		t.Errorf("Expected the api key hash to be restored, got %q %v", key.Hash, err)
+	}
+
+	if revoked, err := s.IsRevoked(ctx, "token"); err != nil || !revoked {
+		t.Errorf("Expected the token to stay revoked, got %v %v", revoked, err)
+	}
+
+	// the ids of the restored storage continue the sequence
+	if next, err := s.CreateUser(ctx, "degeboman@mail.ru", "hash", nil); err != nil || next == id {
+		t.Errorf("Expected a new id, got %s %v", next, err)
+	}
+}
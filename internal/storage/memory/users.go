@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	errUserNotFound = fmt.Errorf("user %w", storage.ErrNotFound)
+	errEmailTaken   = storage.ErrEmailTaken
+
+	errIdentityLinked = errors.New("identity is already linked")
+)
+
+// cloneUser copies the lists of the user, so that the caller can't change the stored user
+func cloneUser(user storage.User) storage.User {
+	user.Roles = append([]string(nil), user.Roles...)
+	user.RecoveryCodeHashes = append([]string(nil), user.RecoveryCodeHashes...)
+	user.WebAuthnCredentials = append([]storage.WebAuthnCredential(nil), user.WebAuthnCredentials...)
+	user.Identities = append([]storage.Identity(nil), user.Identities...)
+
+	return user
+}
+
+// userByEmail finds the user with the email in any case, s.mu must be held
+func (s *Storage) userByEmail(email string) (storage.User, bool) {
+	for _, user := range s.state.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, true
+		}
+	}
+
+	return storage.User{}, false
+}
+
+func (s *Storage) UserByEmail(ctx context.Context, email string) (storage.User, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.userByEmail(email)
+	if !ok {
+		return storage.User{}, errUserNotFound
+	}
+
+	return cloneUser(user), nil
+}
+
+func (s *Storage) DoesEmailExist(ctx context.Context, email string) error {
+	defer s.lock(ctx)()
+
+	if _, ok := s.userByEmail(email); ok {
+		return errEmailTaken
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error) {
+	defer s.lock(ctx)()
+
+	if _, ok := s.userByEmail(email); ok {
+		return "0", errEmailTaken
+	}
+
+	user := storage.User{
+		ID:           s.newID(),
+		Email:        email,
+		PasswordHash: password,
+		UserInfo:     userInfo,
+		Roles:        []string{},
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	s.state.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+func (s *Storage) Users(ctx context.Context, filter storage.UserFilter) ([]storage.User, int64, error) {
+	defer s.lock(ctx)()
+
+	prefix := strings.ToLower(filter.EmailPrefix)
+
+	matched := make([]storage.User, 0)
+
+	for _, user := range s.state.users {
+		switch {
+		case !strings.HasPrefix(strings.ToLower(user.Email), prefix):
+		case !filter.CreatedAfter.IsZero() && user.CreatedAt.Before(filter.CreatedAfter):
+		case !filter.CreatedBefore.IsZero() && !user.CreatedAt.Before(filter.CreatedBefore):
+		case filter.Verified != nil && user.Verified != *filter.Verified:
+		case filter.Locked != nil && user.Locked != *filter.Locked:
+		default:
+			matched = append(matched, cloneUser(user))
+		}
+	}
+
+	// the newest first, the ids break the ties of users created at the same time
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+
+		return len(matched[i].ID) > len(matched[j].ID) ||
+			len(matched[i].ID) == len(matched[j].ID) && matched[i].ID > matched[j].ID
+	})
+
+	total := int64(len(matched))
+
+	if filter.Offset >= total {
+		return []storage.User{}, total, nil
+	}
+
+	matched = matched[filter.Offset:]
+
+	if filter.Limit > 0 && filter.Limit < int64(len(matched)) {
+		matched = matched[:filter.Limit]
+	}
+
+	return matched, total, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, id string) (storage.User, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.state.users[id]
+	if !ok {
+		return storage.User{}, errUserNotFound
+	}
+
+	return cloneUser(user), nil
+}
+
+func (s *Storage) UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.UserInfo = userInfo
+		return nil
+	})
+}
+
+func (s *Storage) SetLocked(ctx context.Context, id string, locked bool) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.Locked = locked
+		return nil
+	})
+}
+
+func (s *Storage) SetRoles(ctx context.Context, id string, roles []string) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.Roles = append([]string{}, roles...)
+		return nil
+	})
+}
+
+func (s *Storage) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.PasswordResetRequired = required
+		return nil
+	})
+}
+
+func (s *Storage) SetPassword(ctx context.Context, id, passwordHash string) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.PasswordHash = passwordHash
+		user.PasswordResetRequired = false
+		return nil
+	})
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, id string) error {
+	defer s.lock(ctx)()
+
+	if _, ok := s.state.users[id]; !ok {
+		return errUserNotFound
+	}
+
+	delete(s.state.users, id)
+
+	return nil
+}
+
+func (s *Storage) SetPendingTOTPSecret(ctx context.Context, id, secret string) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.PendingTOTPSecret = secret
+		return nil
+	})
+}
+
+func (s *Storage) SetMFAMethod(ctx context.Context, id, method string) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.MFAEnabled = method != ""
+		user.MFAMethod = method
+		return nil
+	})
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, id, secret string, recoveryCodeHashes []string) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.MFAEnabled = true
+		user.MFAMethod = constant.MFAMethodTOTP
+		user.TOTPSecret = secret
+		user.RecoveryCodeHashes = append([]string{}, recoveryCodeHashes...)
+		user.PendingTOTPSecret = ""
+		user.TOTPLastStep = 0
+		return nil
+	})
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, id, recoveryCodeHash string) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		for i, hash := range user.RecoveryCodeHashes {
+			if hash == recoveryCodeHash {
+				user.RecoveryCodeHashes = append(user.RecoveryCodeHashes[:i], user.RecoveryCodeHashes[i+1:]...)
+				return nil
+			}
+		}
+
+		return errors.New("recovery code is not valid")
+	})
+}
+
+func (s *Storage) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		if step <= user.TOTPLastStep {
+			return storage.ErrTOTPStepUsed
+		}
+
+		user.TOTPLastStep = step
+		return nil
+	})
+}
+
+func (s *Storage) AddWebAuthnCredential(ctx context.Context, id string, credential storage.WebAuthnCredential) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		user.WebAuthnCredentials = append(user.WebAuthnCredentials, credential)
+		return nil
+	})
+}
+
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, id string, credentialID []byte, signCount uint32) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		for i, c := range user.WebAuthnCredentials {
+			if bytes.Equal(c.ID, credentialID) {
+				if signCount <= c.SignCount {
+					return storage.ErrSignCountUsed
+				}
+
+				user.WebAuthnCredentials[i].SignCount = signCount
+				return nil
+			}
+		}
+
+		return errUserNotFound
+	})
+}
+
+func (s *Storage) UserByIdentity(ctx context.Context, provider, subject string) (storage.User, error) {
+	defer s.lock(ctx)()
+
+	for _, user := range s.state.users {
+		for _, identity := range user.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return cloneUser(user), nil
+			}
+		}
+	}
+
+	return storage.User{}, errUserNotFound
+}
+
+func (s *Storage) AddIdentity(ctx context.Context, id string, identity storage.Identity) error {
+	return s.updateUser(ctx, id, func(user *storage.User) error {
+		// an account of the provider is linked to one user, the lock of the storage is held by updateUser
+		for _, u := range s.state.users {
+			for _, linked := range u.Identities {
+				if linked.Provider == identity.Provider && linked.Subject == identity.Subject {
+					return errIdentityLinked
+				}
+			}
+		}
+
+		user.Identities = append(user.Identities, identity)
+		return nil
+	})
+}
+
+// updateUser changes a copy of the user with fn and stores it if fn succeeds
+func (s *Storage) updateUser(ctx context.Context, id string, fn func(user *storage.User) error) error {
+	defer s.lock(ctx)()
+
+	user, ok := s.state.users[id]
+	if !ok {
+		return errUserNotFound
+	}
+
+	user = cloneUser(user)
+
+	if err := fn(&user); err != nil {
+		return err
+	}
+
+	s.state.users[id] = user
+
+	return nil
+}
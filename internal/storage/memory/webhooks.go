@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"sort"
+)
+
+var errWebhookNotFound = fmt.Errorf("webhook %w", storage.ErrNotFound)
+
+func (s *Storage) CreateWebhook(ctx context.Context, webhook storage.Webhook) (string, error) {
+	defer s.lock(ctx)()
+
+	webhook.ID = s.newID()
+	webhook.Events = append([]string{}, webhook.Events...)
+
+	s.state.webhooks[webhook.ID] = webhook
+
+	return webhook.ID, nil
+}
+
+func (s *Storage) Webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	defer s.lock(ctx)()
+
+	webhooks := make([]storage.Webhook, 0, len(s.state.webhooks))
+	for _, webhook := range s.state.webhooks {
+		webhook.Events = append([]string{}, webhook.Events...)
+		webhooks = append(webhooks, webhook)
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt.After(webhooks[j].CreatedAt) })
+
+	return webhooks, nil
+}
+
+func (s *Storage) WebhookByID(ctx context.Context, id string) (storage.Webhook, error) {
+	defer s.lock(ctx)()
+
+	webhook, ok := s.state.webhooks[id]
+	if !ok {
+		return storage.Webhook{}, errWebhookNotFound
+	}
+
+	webhook.Events = append([]string{}, webhook.Events...)
+
+	return webhook, nil
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, id string) error {
+	defer s.lock(ctx)()
+
+	if _, ok := s.state.webhooks[id]; !ok {
+		return errWebhookNotFound
+	}
+
+	delete(s.state.webhooks, id)
+
+	deliveries := make([]storage.WebhookDelivery, 0, len(s.state.deliveries))
+	for _, d := range s.state.deliveries {
+		if d.WebhookID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	s.state.deliveries = deliveries
+
+	return nil
+}
+
+func (s *Storage) AddWebhookDelivery(ctx context.Context, delivery storage.WebhookDelivery) error {
+	defer s.lock(ctx)()
+
+	delivery.ID = s.newID()
+
+	s.state.deliveries = append(s.state.deliveries, delivery)
+
+	return nil
+}
+
+func (s *Storage) WebhookDeliveries(ctx context.Context, webhookID string, limit int64) ([]storage.WebhookDelivery, error) {
+	defer s.lock(ctx)()
+
+	deliveries := make([]storage.WebhookDelivery, 0)
+	for _, d := range s.state.deliveries {
+		if d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+
+	if limit > 0 && limit < int64(len(deliveries)) {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
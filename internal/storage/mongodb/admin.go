@@ -0,0 +1,194 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"time"
+)
+
+var errUserNotFound = fmt.Errorf("user %w", storage.ErrNotFound)
+
+type userDocument struct {
+	ID                    primitive.ObjectID           `bson:"_id"`
+	Email                 string                       `bson:"email"`
+	Password              string                       `bson:"password"`
+	UserInfo              interface{}                  `bson:"user_info"`
+	Roles                 []string                     `bson:"roles"`
+	Verified              bool                         `bson:"verified"`
+	Locked                bool                         `bson:"locked"`
+	PasswordResetRequired bool                         `bson:"password_reset_required"`
+	MFAEnabled            bool                         `bson:"mfa_enabled"`
+	MFAMethod             string                       `bson:"mfa_method"`
+	TOTPSecret            string                       `bson:"totp_secret"`
+	PendingTOTPSecret     string                       `bson:"totp_pending_secret"`
+	TOTPLastStep          int64                        `bson:"totp_last_step"`
+	RecoveryCodeHashes    []string                     `bson:"recovery_codes"`
+	WebAuthnCredentials   []webAuthnCredentialDocument `bson:"webauthn_credentials"`
+	Identities            []identityDocument           `bson:"identities"`
+	CreatedAt             time.Time                    `bson:"created_at"`
+}
+
+func (d userDocument) user() storage.User {
+	return storage.User{
+		ID:                    d.ID.Hex(),
+		Email:                 d.Email,
+		PasswordHash:          d.Password,
+		UserInfo:              d.UserInfo,
+		Roles:                 d.Roles,
+		Verified:              d.Verified,
+		Locked:                d.Locked,
+		PasswordResetRequired: d.PasswordResetRequired,
+		MFAEnabled:            d.MFAEnabled,
+		MFAMethod:             d.MFAMethod,
+		TOTPSecret:            d.TOTPSecret,
+		PendingTOTPSecret:     d.PendingTOTPSecret,
+		TOTPLastStep:          d.TOTPLastStep,
+		RecoveryCodeHashes:    d.RecoveryCodeHashes,
+		WebAuthnCredentials:   webAuthnCredentials(d.WebAuthnCredentials),
+		Identities:            identities(d.Identities),
+		CreatedAt:             d.CreatedAt,
+	}
+}
+
+func (u UsersStorage) Users(ctx context.Context, filter storage.UserFilter) ([]storage.User, int64, error) {
+	query := bson.D{}
+
+	if filter.EmailPrefix != "" {
+		// the prefix of the normalized email can use its index unlike a case-insensitive regex
+		query = append(query, bson.E{Key: "email_normalized", Value: primitive.Regex{
+			Pattern: "^" + regexp.QuoteMeta(normalizeEmail(filter.EmailPrefix)),
+		}})
+	}
+
+	created := bson.D{}
+	if !filter.CreatedAfter.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: filter.CreatedAfter})
+	}
+	if !filter.CreatedBefore.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: filter.CreatedBefore})
+	}
+	if len(created) > 0 {
+		query = append(query, bson.E{Key: "created_at", Value: created})
+	}
+
+	if filter.Verified != nil {
+		query = append(query, bson.E{Key: "verified", Value: *filter.Verified})
+	}
+
+	if filter.Locked != nil {
+		query = append(query, bson.E{Key: "locked", Value: *filter.Locked})
+	}
+
+	total, err := u.users.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(filter.Offset).
+		SetLimit(filter.Limit)
+
+	cursor, err := u.users.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var docs []userDocument
+
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+
+	users := make([]storage.User, 0, len(docs))
+	for _, d := range docs {
+		users = append(users, d.user())
+	}
+
+	return users, total, nil
+}
+
+func (u UsersStorage) UserByID(ctx context.Context, id string) (storage.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return storage.User{}, errUserNotFound
+	}
+
+	var doc userDocument
+
+	if err := u.users.FindOne(ctx, bson.D{{Key: "_id", Value: objectID}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.User{}, errUserNotFound
+		}
+
+		return storage.User{}, err
+	}
+
+	return doc.user(), nil
+}
+
+func (u UsersStorage) UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error {
+	return u.updateUser(ctx, id, bson.D{{Key: "user_info", Value: userInfo}})
+}
+
+func (u UsersStorage) SetLocked(ctx context.Context, id string, locked bool) error {
+	return u.updateUser(ctx, id, bson.D{{Key: "locked", Value: locked}})
+}
+
+func (u UsersStorage) SetRoles(ctx context.Context, id string, roles []string) error {
+	return u.updateUser(ctx, id, bson.D{{Key: "roles", Value: roles}})
+}
+
+func (u UsersStorage) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	return u.updateUser(ctx, id, bson.D{{Key: "password_reset_required", Value: required}})
+}
+
+func (u UsersStorage) SetPassword(ctx context.Context, id, passwordHash string) error {
+	return u.updateUser(ctx, id, bson.D{
+		{Key: "password", Value: passwordHash},
+		{Key: "password_reset_required", Value: false},
+	})
+}
+
+func (u UsersStorage) DeleteUser(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	res, err := u.users.DeleteOne(ctx, bson.D{{Key: "_id", Value: objectID}})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
+
+func (u UsersStorage) updateUser(ctx context.Context, id string, set bson.D) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	res, err := u.users.UpdateOne(ctx, bson.D{{Key: "_id", Value: objectID}}, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var errAPIKeyNotFound = fmt.Errorf("api key %w", storage.ErrNotFound)
+
+type apiKeyDocument struct {
+	ID         string     `bson:"_id"`
+	Name       string     `bson:"name"`
+	OwnerID    string     `bson:"owner_id"`
+	OwnerType  string     `bson:"owner_type"`
+	Scopes     []string   `bson:"scopes"`
+	Hash       string     `bson:"hash"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	ExpiresAt  *time.Time `bson:"expires_at"`
+	LastUsedAt *time.Time `bson:"last_used_at"`
+	RevokedAt  *time.Time `bson:"revoked_at"`
+}
+
+func (u UsersStorage) CreateAPIKey(ctx context.Context, key storage.APIKey) error {
+	_, err := u.apiKeys.InsertOne(ctx, apiKeyDocument(key))
+	return err
+}
+
+func (u UsersStorage) APIKeyByID(ctx context.Context, id string) (storage.APIKey, error) {
+	var doc apiKeyDocument
+
+	if err := u.apiKeys.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.APIKey{}, errAPIKeyNotFound
+		}
+
+		return storage.APIKey{}, err
+	}
+
+	return storage.APIKey(doc), nil
+}
+
+func (u UsersStorage) APIKeys(ctx context.Context, ownerID string) ([]storage.APIKey, error) {
+	cursor, err := u.apiKeys.Find(ctx,
+		bson.D{{Key: "owner_id", Value: ownerID}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []apiKeyDocument
+
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	keys := make([]storage.APIKey, 0, len(docs))
+	for _, d := range docs {
+		keys = append(keys, storage.APIKey(d))
+	}
+
+	return keys, nil
+}
+
+func (u UsersStorage) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := u.apiKeys.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "last_used_at", Value: usedAt}}}},
+	)
+
+	return err
+}
+
+func (u UsersStorage) RevokeAPIKey(ctx context.Context, ownerID, id string, revokedAt time.Time) error {
+	res, err := u.apiKeys.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "owner_id", Value: ownerID},
+			{Key: "revoked_at", Value: nil},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: revokedAt}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errAPIKeyNotFound
+	}
+
+	return nil
+}
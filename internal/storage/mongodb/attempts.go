@@ -0,0 +1,96 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type attemptsDocument struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LockedUntil time.Time `bson:"locked_until"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+func (u UsersStorage) FailedAttempts(ctx context.Context, key string) (storage.Attempts, error) {
+	var doc attemptsDocument
+
+	err := u.attempts.FindOne(ctx, bson.D{
+		{Key: "_id", Value: key},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.Attempts{}, nil
+		}
+
+		return storage.Attempts{}, err
+	}
+
+	return storage.Attempts{
+		Failures:    doc.Failures,
+		LockedUntil: doc.LockedUntil,
+	}, nil
+}
+
+func (u UsersStorage) AddFailedAttempt(ctx context.Context, key string, window time.Duration) (storage.Attempts, error) {
+	now := time.Now()
+
+	// the pipeline resets the counter of an expired document, so the increment stays atomic
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "failures", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gt", Value: bson.A{"$expires_at", now}}},
+				bson.D{{Key: "$add", Value: bson.A{"$failures", 1}}},
+				1,
+			}}}},
+			{Key: "locked_until", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$locked_until", time.Time{}}}}},
+			{Key: "expires_at", Value: bson.D{{Key: "$max", Value: bson.A{
+				now.Add(window),
+				bson.D{{Key: "$ifNull", Value: bson.A{"$locked_until", time.Time{}}}},
+			}}}},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var doc attemptsDocument
+
+	if err := u.attempts.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: key}}, update, opts).Decode(&doc); err != nil {
+		return storage.Attempts{}, err
+	}
+
+	return storage.Attempts{
+		Failures:    doc.Failures,
+		LockedUntil: doc.LockedUntil,
+	}, nil
+}
+
+func (u UsersStorage) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "locked_until", Value: until},
+			{Key: "expires_at", Value: bson.D{{Key: "$max", Value: bson.A{
+				until,
+				bson.D{{Key: "$ifNull", Value: bson.A{"$expires_at", time.Time{}}}},
+			}}}},
+		}}},
+	}
+
+	_, err := u.attempts.UpdateOne(ctx, bson.D{{Key: "_id", Value: key}}, update, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (u UsersStorage) ResetAttempts(ctx context.Context, key string) error {
+	_, err := u.attempts.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+
+	return err
+}
@@ -0,0 +1,149 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// auditDocument is keyed by the sequence number, the unique id keeps concurrent appends from forking the chain
+type auditDocument struct {
+	Seq           int64     `bson:"_id"`
+	Time          time.Time `bson:"time"`
+	Action        string    `bson:"action"`
+	Outcome       string    `bson:"outcome"`
+	Error         string    `bson:"error,omitempty"`
+	ActorID       string    `bson:"actor_id,omitempty"`
+	ActorAPIKeyID string    `bson:"actor_api_key_id,omitempty"`
+	Target        string    `bson:"target,omitempty"`
+	IP            string    `bson:"ip,omitempty"`
+	UserAgent     string    `bson:"user_agent,omitempty"`
+	RequestID     string    `bson:"request_id,omitempty"`
+	PrevHash      string    `bson:"prev_hash"`
+	Hash          string    `bson:"hash"`
+}
+
+func (d auditDocument) entry() storage.AuditEntry {
+	return storage.AuditEntry{
+		Seq:           d.Seq,
+		Time:          d.Time.UTC(),
+		Action:        d.Action,
+		Outcome:       d.Outcome,
+		Error:         d.Error,
+		ActorID:       d.ActorID,
+		ActorAPIKeyID: d.ActorAPIKeyID,
+		Target:        d.Target,
+		IP:            d.IP,
+		UserAgent:     d.UserAgent,
+		RequestID:     d.RequestID,
+		PrevHash:      d.PrevHash,
+		Hash:          d.Hash,
+	}
+}
+
+func (u UsersStorage) LastAuditEntry(ctx context.Context) (storage.AuditEntry, error) {
+	var doc auditDocument
+
+	err := u.audit.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.AuditEntry{}, nil
+		}
+
+		return storage.AuditEntry{}, err
+	}
+
+	return doc.entry(), nil
+}
+
+func (u UsersStorage) AddAuditEntry(ctx context.Context, entry storage.AuditEntry) error {
+	_, err := u.audit.InsertOne(ctx, auditDocument{
+		Seq:           entry.Seq,
+		Time:          entry.Time,
+		Action:        entry.Action,
+		Outcome:       entry.Outcome,
+		Error:         entry.Error,
+		ActorID:       entry.ActorID,
+		ActorAPIKeyID: entry.ActorAPIKeyID,
+		Target:        entry.Target,
+		IP:            entry.IP,
+		UserAgent:     entry.UserAgent,
+		RequestID:     entry.RequestID,
+		PrevHash:      entry.PrevHash,
+		Hash:          entry.Hash,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return storage.ErrAuditSeqTaken
+	}
+
+	return err
+}
+
+func (u UsersStorage) AuditEntries(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	query := bson.D{}
+
+	for key, value := range map[string]string{
+		"action":   filter.Action,
+		"actor_id": filter.ActorID,
+		"target":   filter.Target,
+		"outcome":  filter.Outcome,
+	} {
+		if value != "" {
+			query = append(query, bson.E{Key: key, Value: value})
+		}
+	}
+
+	seq := bson.D{}
+	if filter.AfterSeq > 0 {
+		seq = append(seq, bson.E{Key: "$gt", Value: filter.AfterSeq})
+	}
+	if filter.BeforeSeq > 0 {
+		seq = append(seq, bson.E{Key: "$lt", Value: filter.BeforeSeq})
+	}
+	if len(seq) > 0 {
+		query = append(query, bson.E{Key: "_id", Value: seq})
+	}
+
+	created := bson.D{}
+	if !filter.Since.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: filter.Since})
+	}
+	if !filter.Until.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: filter.Until})
+	}
+	if len(created) > 0 {
+		query = append(query, bson.E{Key: "time", Value: created})
+	}
+
+	order := 1
+	if filter.Descending {
+		order = -1
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: order}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := u.audit.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []auditDocument
+
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	entries := make([]storage.AuditEntry, 0, len(docs))
+	for _, d := range docs {
+		entries = append(entries, d.entry())
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,70 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var errChallengeNotFound = fmt.Errorf("challenge %w", storage.ErrNotFound)
+
+type challengeDocument struct {
+	ID        string    `bson:"_id"`
+	Data      []byte    `bson:"data"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (u UsersStorage) SaveChallenge(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	// replacing lets a challenge be saved again under the same id, e.g. a resent code
+	_, err := u.challenges.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, challengeDocument{
+		ID:        id,
+		Data:      data,
+		ExpiresAt: time.Now().Add(ttl),
+	}, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+func (u UsersStorage) SaveChallengeOnce(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	now := time.Now()
+
+	// only an expired challenge matches the filter, a live one makes the upsert insert a duplicate id
+	_, err := u.challenges.ReplaceOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: now}}},
+	}, challengeDocument{
+		ID:        id,
+		Data:      data,
+		ExpiresAt: now.Add(ttl),
+	}, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return storage.ErrChallengeExists
+	}
+
+	return err
+}
+
+func (u UsersStorage) TakeChallenge(ctx context.Context, id string) ([]byte, error) {
+	var doc challengeDocument
+
+	// deleting on read makes the challenge single use even with concurrent requests
+	err := u.challenges.FindOneAndDelete(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errChallengeNotFound
+		}
+
+		return nil, err
+	}
+
+	if time.Now().After(doc.ExpiresAt) {
+		return nil, errChallengeNotFound
+	}
+
+	return doc.Data, nil
+}
@@ -0,0 +1,72 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type identityDocument struct {
+	Provider string    `bson:"provider"`
+	Subject  string    `bson:"subject"`
+	Email    string    `bson:"email"`
+	LinkedAt time.Time `bson:"linked_at"`
+}
+
+func identities(docs []identityDocument) []storage.Identity {
+	identities := make([]storage.Identity, 0, len(docs))
+
+	for _, d := range docs {
+		identities = append(identities, storage.Identity(d))
+	}
+
+	return identities
+}
+
+func (u UsersStorage) UserByIdentity(ctx context.Context, provider, subject string) (storage.User, error) {
+	var doc userDocument
+
+	err := u.users.FindOne(ctx, bson.D{
+		{Key: "identities", Value: bson.D{
+			{Key: "$elemMatch", Value: bson.D{
+				{Key: "provider", Value: provider},
+				{Key: "subject", Value: subject},
+			}},
+		}},
+	}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.User{}, errUserNotFound
+		}
+
+		return storage.User{}, err
+	}
+
+	return doc.user(), nil
+}
+
+func (u UsersStorage) AddIdentity(ctx context.Context, id string, identity storage.Identity) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	res, err := u.users.UpdateOne(ctx, bson.D{{Key: "_id", Value: objectID}}, bson.D{
+		{Key: "$push", Value: bson.D{
+			{Key: "identities", Value: identityDocument(identity)},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
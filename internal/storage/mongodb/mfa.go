@@ -0,0 +1,95 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (u UsersStorage) SetPendingTOTPSecret(ctx context.Context, id, secret string) error {
+	return u.updateUser(ctx, id, bson.D{{Key: "totp_pending_secret", Value: secret}})
+}
+
+func (u UsersStorage) SetMFAMethod(ctx context.Context, id, method string) error {
+	return u.updateUser(ctx, id, bson.D{{Key: "mfa_enabled", Value: method != ""}, {Key: "mfa_method", Value: method}})
+}
+
+func (u UsersStorage) EnableTOTP(ctx context.Context, id, secret string, recoveryCodeHashes []string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	res, err := u.users.UpdateOne(ctx, bson.D{{Key: "_id", Value: objectID}}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "mfa_enabled", Value: true},
+			{Key: "mfa_method", Value: constant.MFAMethodTOTP},
+			{Key: "totp_secret", Value: secret},
+			{Key: "recovery_codes", Value: recoveryCodeHashes},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "totp_pending_secret", Value: ""}, {Key: "totp_last_step", Value: ""}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
+
+func (u UsersStorage) UseRecoveryCode(ctx context.Context, id, recoveryCodeHash string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	// matching the hash in the filter makes the check and the removal atomic
+	res, err := u.users.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: "recovery_codes", Value: recoveryCodeHash},
+	}, bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "recovery_codes", Value: recoveryCodeHash}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount == 0 {
+		return errors.New("recovery code is not valid")
+	}
+
+	return nil
+}
+
+func (u UsersStorage) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	// comparing the step in the filter makes the check and the update atomic, a missing step is never used
+	res, err := u.users.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "totp_last_step", Value: bson.D{{Key: "$lt", Value: step}}}},
+			bson.D{{Key: "totp_last_step", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}},
+	}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "totp_last_step", Value: step}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount == 0 {
+		return storage.ErrTOTPStepUsed
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// migration changes the database once, the applied versions are kept in the migrations collection.
+// Every migration must be safe to run again, two instances of gas may start at the same time
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, db *mongo.Database) error
+}
+
+var migrations = []migration{
+	{version: 1, name: "unique_normalized_email", up: uniqueNormalizedEmail},
+	{version: 2, name: "session_and_token_indexes", up: sessionAndTokenIndexes},
+	{version: 3, name: "mfa_method", up: mfaMethod},
+}
+
+type migrationDocument struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// migrate applies the migrations which have not been applied yet in the order of the versions
+func migrate(ctx context.Context, db *mongo.Database) error {
+	applied := db.Collection("migrations")
+
+	for _, m := range migrations {
+		err := applied.FindOne(ctx, bson.D{{Key: "_id", Value: m.version}}).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+
+		if err := m.up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+
+		_, err = applied.ReplaceOne(ctx, bson.D{{Key: "_id", Value: m.version}}, migrationDocument{
+			Version:   m.version,
+			Name:      m.name,
+			AppliedAt: time.Now().UTC(),
+		}, options.Replace().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeEmail is the key the uniqueness of the emails is checked by
+func normalizeEmail(email string) string {
+	return strings.ToLower(email)
+}
+
+// uniqueNormalizedEmail fills email_normalized of the users created before it and makes it unique,
+// the insert of a second user with the same email fails even when the sign-ups race
+func uniqueNormalizedEmail(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	_, err := users.UpdateMany(ctx,
+		bson.D{{Key: "email_normalized", Value: bson.D{{Key: "$exists", Value: false}}}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.D{
+			{Key: "email_normalized", Value: bson.D{{Key: "$toLower", Value: "$email"}}},
+		}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email_normalized", Value: 1}},
+		Options: options.Index().SetName("email_normalized_unique").SetUnique(true),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("users with the same email in different case must be merged first: %w", err)
+	}
+
+	return err
+}
+
+// sessionAndTokenIndexes lets mongodb remove the expired revocations, challenges and sign in attempts
+// and covers the lookups of the api keys, identities, deliveries, outbox and audit log
+func sessionAndTokenIndexes(ctx context.Context, db *mongo.Database) error {
+	expires := func() *options.IndexOptions {
+		return options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0)
+	}
+
+	indexes := map[string][]mongo.IndexModel{
+		"revoked_tokens": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: expires()},
+		},
+		"challenges": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: expires()},
+		},
+		"login_attempts": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: expires()},
+		},
+		"users": {
+			{Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}}},
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		},
+		"api_keys": {
+			{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"webhook_deliveries": {
+			{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"outbox": {
+			{Keys: bson.D{{Key: "due_at", Value: 1}, {Key: "created_at", Value: 1}}},
+		},
+		"audit_log": {
+			{Keys: bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "target", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+
+	return nil
+}
+
+// mfaMethod sets the second factor of the users with two-factor authentication from the factors they have,
+// two-factor authentication without a usable factor is turned off
+func mfaMethod(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	pending := bson.D{
+		{Key: "mfa_enabled", Value: true},
+		{Key: "mfa_method", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+
+	updates := []struct {
+		filter bson.D
+		set    bson.D
+	}{
+		{
+			filter: append(pending, bson.E{Key: "totp_secret", Value: bson.D{{Key: "$nin", Value: bson.A{"", nil}}}}),
+			set:    bson.D{{Key: "mfa_method", Value: "totp"}},
+		},
+		{
+			filter: append(pending, bson.E{Key: "webauthn_credentials.0", Value: bson.D{{Key: "$exists", Value: true}}}),
+			set:    bson.D{{Key: "mfa_method", Value: "webauthn"}},
+		},
+		{
+			filter: pending,
+			set:    bson.D{{Key: "mfa_enabled", Value: false}, {Key: "mfa_method", Value: ""}},
+		},
+	}
+
+	for _, update := range updates {
+		if _, err := users.UpdateMany(ctx, update.filter, bson.D{{Key: "$set", Value: update.set}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,38 +3,52 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
+	"time"
 )
 
 type UsersStorage struct {
-	users Users
+	client     *mongo.Client
+	users      Users
+	attempts   *mongo.Collection
+	challenges *mongo.Collection
+	accounts   *mongo.Collection
+	apiKeys    *mongo.Collection
+	revoked    *mongo.Collection
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+	outbox     *mongo.Collection
+	audit      *mongo.Collection
+	// transactions are supported only by replica sets and sharded clusters
+	transactions bool
 }
 
-func (u UsersStorage) UserByEmail(email string) (interface{}, error) {
-	res := u.users.FindOne(context.TODO(), bson.D{
-		{Key: "email", Value: email},
-	})
+func (u UsersStorage) UserByEmail(ctx context.Context, email string) (storage.User, error) {
+	var doc userDocument
 
-	var userInfo map[string]interface{}
+	if err := u.users.FindOne(ctx, bson.D{{Key: "email_normalized", Value: normalizeEmail(email)}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.User{}, errUserNotFound
+		}
 
-	if err := res.Decode(&userInfo); err != nil {
-		return nil, err
+		return storage.User{}, err
 	}
 
-	return userInfo, nil
+	return doc.user(), nil
 }
 
 type Users struct {
 	*mongo.Collection
 }
 
-func (u UsersStorage) DoesEmailExist(email string) error {
-	res := u.users.FindOne(context.TODO(), bson.D{{Key: "email", Value: email}})
+func (u UsersStorage) DoesEmailExist(ctx context.Context, email string) error {
+	res := u.users.FindOne(ctx, bson.D{{Key: "email_normalized", Value: normalizeEmail(email)}})
 
 	if res.Err() != nil {
 		if errors.Is(res.Err(), mongo.ErrNoDocuments) {
@@ -44,41 +58,95 @@ func (u UsersStorage) DoesEmailExist(email string) error {
 		return res.Err()
 	}
 
-	return errors.New("email is already in use")
+	return storage.ErrEmailTaken
 }
 
-func (u UsersStorage) CreateUser(email, password string, userInfo interface{}) (string, error) {
-	res, err := u.users.InsertOne(context.TODO(), bson.D{
+func (u UsersStorage) CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error) {
+	res, err := u.users.InsertOne(ctx, bson.D{
 		{Key: "email", Value: email},
+		{Key: "email_normalized", Value: normalizeEmail(email)},
 		{Key: "password", Value: password},
 		{Key: "user_info", Value: userInfo},
+		{Key: "roles", Value: []string{}},
+		{Key: "verified", Value: false},
+		{Key: "locked", Value: false},
+		{Key: "password_reset_required", Value: false},
+		{Key: "mfa_enabled", Value: false},
+		{Key: "created_at", Value: time.Now().UTC()},
 	})
 
 	if err != nil {
+		// the unique index decides when two sign-ups with the same email race past DoesEmailExist
+		if mongo.IsDuplicateKeyError(err) {
+			return "0", storage.ErrEmailTaken
+		}
+
 		return "0", err
 	}
 
-	return res.InsertedID.(primitive.ObjectID).String(), nil
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func New(connectString string) UsersStorage {
+func New(connectString string) (UsersStorage, error) {
+	return open(connectString, constant.DatabaseName)
+}
+
+// open connects to the database with the name, the tests use a database of their own
+func open(connectString, database string) (UsersStorage, error) {
 	const op = "storage.mongodb.mongodb.New"
 
 	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(connectString))
 
 	if err != nil {
-		log.Fatalf("%s: %s", op, err)
+		return UsersStorage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if err := client.Ping(context.TODO(), nil); err != nil {
-		log.Fatalf("%s: %s", op, err)
+		_ = client.Disconnect(context.TODO())
+
+		return UsersStorage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	db := client.Database(database)
+
 	users := Users{
-		Collection: client.Database(constant.DatabaseName).Collection("users"),
+		Collection: db.Collection("users"),
 	}
 
-	return UsersStorage{
-		users: users,
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
 	}
+
+	if err := db.RunCommand(context.TODO(), bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		_ = client.Disconnect(context.TODO())
+
+		return UsersStorage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := migrate(context.TODO(), db); err != nil {
+		_ = client.Disconnect(context.TODO())
+
+		return UsersStorage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return UsersStorage{
+		client:     client,
+		users:      users,
+		attempts:   db.Collection("login_attempts"),
+		challenges: db.Collection("challenges"),
+		accounts:   db.Collection("service_accounts"),
+		apiKeys:    db.Collection("api_keys"),
+		revoked:    db.Collection("revoked_tokens"),
+		webhooks:   db.Collection("webhooks"),
+		deliveries: db.Collection("webhook_deliveries"),
+		outbox:     db.Collection("outbox"),
+		audit:      db.Collection("audit_log"),
+
+		transactions: hello.SetName != "" || hello.Msg == "isdbgrid",
+	}, nil
+}
+
+func (u UsersStorage) Close() error {
+	return u.client.Disconnect(context.TODO())
 }
@@ -0,0 +1,90 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/storagetest"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"testing"
+)
+
+// testDatabase is dropped by the tests, it must not be the database of gas
+const testDatabase = "gas_test"
+
+// openTest opens the storage on the empty test database with the migrations applied
+func openTest(t *testing.T) UsersStorage {
+	t.Helper()
+
+	uri := os.Getenv("GAS_TEST_MONGODB")
+	if uri == "" {
+		t.Skip("GAS_TEST_MONGODB is not set")
+	}
+
+	s, err := open(uri, testDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	db := s.users.Database()
+
+	if err := db.Drop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	if os.Getenv("GAS_TEST_MONGODB") == "" {
+		t.Skip("GAS_TEST_MONGODB is not set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return openTest(t)
+	})
+}
+
+func TestMigrateLegacyUsers(t *testing.T) {
+	ctx := context.Background()
+	s := openTest(t)
+	db := s.users.Database()
+
+	// the users created before the migration have no normalized email
+	if err := db.Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.users.InsertOne(ctx, bson.D{{Key: "email", Value: "Rupychman@Mail.ru"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UserByEmail(ctx, "rupychman@mail.ru"); err != nil {
+		t.Errorf("Expected the legacy user to be found, got %v", err)
+	}
+
+	// the duplicates differing in case can't be made unique
+	if err := db.Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"rupychman@mail.ru", "RUPYCHMAN@mail.ru"} {
+		if _, err := s.users.InsertOne(ctx, bson.D{{Key: "email", Value: email}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrate(ctx, db); err == nil {
+		t.Errorf("Expected the migration of the duplicate emails to fail")
+	}
+}
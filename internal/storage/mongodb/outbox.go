@@ -0,0 +1,136 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// outboxDocument keeps the event as json, the data of events has no fixed shape
+type outboxDocument struct {
+	ID        string    `bson:"_id"`
+	Type      string    `bson:"type"`
+	Payload   []byte    `bson:"payload"`
+	CreatedAt time.Time `bson:"created_at"`
+	// DueAt is when the event may be claimed, it is moved forward by claims and failed attempts
+	DueAt     time.Time `bson:"due_at"`
+	Attempts  int       `bson:"attempts"`
+	Sinks     []string  `bson:"sinks"`
+	LastError string    `bson:"last_error,omitempty"`
+}
+
+// Transaction runs fn in a transaction, without transactions support fn runs as is and every write stands alone
+func (u UsersStorage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// a nested call is a part of the outer transaction
+	if !u.transactions || mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := u.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+
+	return err
+}
+
+// SupportsTransactions reports whether the writes of Transaction are atomic
+func (u UsersStorage) SupportsTransactions() bool {
+	return u.transactions
+}
+
+func (u UsersStorage) AddOutboxEvent(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = u.outbox.InsertOne(ctx, outboxDocument{
+		ID:        event.ID,
+		Type:      event.Type,
+		Payload:   payload,
+		CreatedAt: event.CreatedAt,
+		DueAt:     event.CreatedAt,
+		Sinks:     []string{},
+	})
+
+	return err
+}
+
+func (u UsersStorage) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]storage.OutboxEvent, error) {
+	claimed := make([]storage.OutboxEvent, 0, limit)
+
+	for len(claimed) < limit {
+		now := time.Now()
+
+		var doc outboxDocument
+
+		err := u.outbox.FindOneAndUpdate(ctx,
+			bson.D{{Key: "due_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "due_at", Value: now.Add(lease)}}}},
+			options.FindOneAndUpdate().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+		).Decode(&doc)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				break
+			}
+
+			return nil, err
+		}
+
+		var event events.Event
+
+		if err := json.Unmarshal(doc.Payload, &event); err != nil {
+			return nil, err
+		}
+
+		claimed = append(claimed, storage.OutboxEvent{
+			Event:    event,
+			Attempts: doc.Attempts,
+			Sinks:    doc.Sinks,
+		})
+	}
+
+	return claimed, nil
+}
+
+func (u UsersStorage) CompleteOutboxSink(ctx context.Context, id, sink string) error {
+	_, err := u.outbox.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$addToSet", Value: bson.D{{Key: "sinks", Value: sink}}}},
+	)
+
+	return err
+}
+
+func (u UsersStorage) RetryOutboxEvent(ctx context.Context, id string, at time.Time, lastError string) error {
+	_, err := u.outbox.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "due_at", Value: at},
+				{Key: "last_error", Value: lastError},
+			}},
+			{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+		},
+	)
+
+	return err
+}
+
+func (u UsersStorage) DeleteOutboxEvent(ctx context.Context, id string) error {
+	_, err := u.outbox.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+
+	return err
+}
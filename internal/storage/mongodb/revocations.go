@@ -0,0 +1,39 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type revokedDocument struct {
+	ID        string    `bson:"_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (u UsersStorage) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := u.revoked.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, revokedDocument{
+		ID:        id,
+		ExpiresAt: time.Now().Add(ttl),
+	}, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+func (u UsersStorage) IsRevoked(ctx context.Context, id string) (bool, error) {
+	var doc revokedDocument
+
+	err := u.revoked.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return time.Now().Before(doc.ExpiresAt), nil
+}
@@ -0,0 +1,106 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var errServiceAccountNotFound = fmt.Errorf("service account %w", storage.ErrNotFound)
+
+type serviceAccountDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Name        string             `bson:"name"`
+	Description string             `bson:"description"`
+	Roles       []string           `bson:"roles"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+func (d serviceAccountDocument) serviceAccount() storage.ServiceAccount {
+	return storage.ServiceAccount{
+		ID:          d.ID.Hex(),
+		Name:        d.Name,
+		Description: d.Description,
+		Roles:       d.Roles,
+		CreatedAt:   d.CreatedAt,
+	}
+}
+
+func (u UsersStorage) CreateServiceAccount(ctx context.Context, account storage.ServiceAccount) (string, error) {
+	res, err := u.accounts.InsertOne(ctx, serviceAccountDocument{
+		Name:        account.Name,
+		Description: account.Description,
+		Roles:       account.Roles,
+		CreatedAt:   account.CreatedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (u UsersStorage) ServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error) {
+	cursor, err := u.accounts.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []serviceAccountDocument
+
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	accounts := make([]storage.ServiceAccount, 0, len(docs))
+	for _, d := range docs {
+		accounts = append(accounts, d.serviceAccount())
+	}
+
+	return accounts, nil
+}
+
+func (u UsersStorage) ServiceAccountByID(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return storage.ServiceAccount{}, errServiceAccountNotFound
+	}
+
+	var doc serviceAccountDocument
+
+	if err := u.accounts.FindOne(ctx, bson.D{{Key: "_id", Value: objectID}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.ServiceAccount{}, errServiceAccountNotFound
+		}
+
+		return storage.ServiceAccount{}, err
+	}
+
+	return doc.serviceAccount(), nil
+}
+
+func (u UsersStorage) DeleteServiceAccount(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errServiceAccountNotFound
+	}
+
+	res, err := u.accounts.DeleteOne(ctx, bson.D{{Key: "_id", Value: objectID}})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return errServiceAccountNotFound
+	}
+
+	_, err = u.apiKeys.DeleteMany(ctx, bson.D{{Key: "owner_id", Value: id}})
+
+	return err
+}
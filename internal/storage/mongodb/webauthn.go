@@ -0,0 +1,78 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type webAuthnCredentialDocument struct {
+	ID              []byte    `bson:"id"`
+	PublicKey       []byte    `bson:"public_key"`
+	AttestationType string    `bson:"attestation_type"`
+	Transports      []string  `bson:"transports"`
+	AAGUID          []byte    `bson:"aaguid"`
+	SignCount       uint32    `bson:"sign_count"`
+	CreatedAt       time.Time `bson:"created_at"`
+}
+
+func webAuthnCredentials(docs []webAuthnCredentialDocument) []storage.WebAuthnCredential {
+	credentials := make([]storage.WebAuthnCredential, 0, len(docs))
+
+	for _, d := range docs {
+		credentials = append(credentials, storage.WebAuthnCredential(d))
+	}
+
+	return credentials
+}
+
+func (u UsersStorage) AddWebAuthnCredential(ctx context.Context, id string, credential storage.WebAuthnCredential) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	res, err := u.users.UpdateOne(ctx, bson.D{{Key: "_id", Value: objectID}}, bson.D{
+		{Key: "$push", Value: bson.D{
+			{Key: "webauthn_credentials", Value: webAuthnCredentialDocument(credential)},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
+
+func (u UsersStorage) UpdateWebAuthnSignCount(ctx context.Context, id string, credentialID []byte, signCount uint32) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errUserNotFound
+	}
+
+	// comparing the count in the filter makes the check and the update atomic
+	res, err := u.users.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: "webauthn_credentials", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "id", Value: credentialID},
+			{Key: "sign_count", Value: bson.D{{Key: "$lt", Value: signCount}}},
+		}}}},
+	}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "webauthn_credentials.$.sign_count", Value: signCount}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount == 0 {
+		return storage.ErrSignCountUsed
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var errWebhookNotFound = fmt.Errorf("webhook %w", storage.ErrNotFound)
+
+type webhookDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	URL         string             `bson:"url"`
+	Description string             `bson:"description"`
+	Events      []string           `bson:"events"`
+	Secret      string             `bson:"secret"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+func (d webhookDocument) webhook() storage.Webhook {
+	return storage.Webhook{
+		ID:          d.ID.Hex(),
+		URL:         d.URL,
+		Description: d.Description,
+		Events:      d.Events,
+		Secret:      d.Secret,
+		CreatedAt:   d.CreatedAt,
+	}
+}
+
+type webhookDeliveryDocument struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	WebhookID  string             `bson:"webhook_id"`
+	EventID    string             `bson:"event_id"`
+	EventType  string             `bson:"event_type"`
+	Attempt    int                `bson:"attempt"`
+	StatusCode int                `bson:"status_code"`
+	Error      string             `bson:"error"`
+	Succeeded  bool               `bson:"succeeded"`
+	Duration   time.Duration      `bson:"duration"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+func (u UsersStorage) CreateWebhook(ctx context.Context, webhook storage.Webhook) (string, error) {
+	res, err := u.webhooks.InsertOne(ctx, webhookDocument{
+		URL:         webhook.URL,
+		Description: webhook.Description,
+		Events:      webhook.Events,
+		Secret:      webhook.Secret,
+		CreatedAt:   webhook.CreatedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (u UsersStorage) Webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	cursor, err := u.webhooks.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []webhookDocument
+
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]storage.Webhook, 0, len(docs))
+	for _, d := range docs {
+		webhooks = append(webhooks, d.webhook())
+	}
+
+	return webhooks, nil
+}
+
+func (u UsersStorage) WebhookByID(ctx context.Context, id string) (storage.Webhook, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return storage.Webhook{}, errWebhookNotFound
+	}
+
+	var doc webhookDocument
+
+	if err := u.webhooks.FindOne(ctx, bson.D{{Key: "_id", Value: objectID}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return storage.Webhook{}, errWebhookNotFound
+		}
+
+		return storage.Webhook{}, err
+	}
+
+	return doc.webhook(), nil
+}
+
+func (u UsersStorage) DeleteWebhook(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errWebhookNotFound
+	}
+
+	res, err := u.webhooks.DeleteOne(ctx, bson.D{{Key: "_id", Value: objectID}})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return errWebhookNotFound
+	}
+
+	_, err = u.deliveries.DeleteMany(ctx, bson.D{{Key: "webhook_id", Value: id}})
+
+	return err
+}
+
+func (u UsersStorage) AddWebhookDelivery(ctx context.Context, delivery storage.WebhookDelivery) error {
+	_, err := u.deliveries.InsertOne(ctx, webhookDeliveryDocument{
+		WebhookID:  delivery.WebhookID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		Succeeded:  delivery.Succeeded,
+		Duration:   delivery.Duration,
+		CreatedAt:  delivery.CreatedAt,
+	})
+
+	return err
+}
+
+func (u UsersStorage) WebhookDeliveries(ctx context.Context, webhookID string, limit int64) ([]storage.WebhookDelivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+
+	cursor, err := u.deliveries.Find(ctx, bson.D{{Key: "webhook_id", Value: webhookID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []webhookDeliveryDocument
+
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]storage.WebhookDelivery, 0, len(docs))
+	for _, d := range docs {
+		deliveries = append(deliveries, storage.WebhookDelivery{
+			ID:         d.ID.Hex(),
+			WebhookID:  d.WebhookID,
+			EventID:    d.EventID,
+			EventType:  d.EventType,
+			Attempt:    d.Attempt,
+			StatusCode: d.StatusCode,
+			Error:      d.Error,
+			Succeeded:  d.Succeeded,
+			Duration:   d.Duration,
+			CreatedAt:  d.CreatedAt,
+		})
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	"time"
+)
+
+func (s Storage) FailedAttempts(ctx context.Context, key string) (storage.Attempts, error) {
+	var attempts storage.Attempts
+
+	err := s.conn(ctx).QueryRowContext(ctx,
+		`SELECT failures, locked_until FROM login_attempts WHERE key = $1 AND expires_at > $2`, key, time.Now(),
+	).Scan(&attempts.Failures, &attempts.LockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Attempts{}, nil
+		}
+
+		return storage.Attempts{}, err
+	}
+
+	return attempts, nil
+}
+
+func (s Storage) AddFailedAttempt(ctx context.Context, key string, window time.Duration) (storage.Attempts, error) {
+	now := time.Now()
+
+	var attempts storage.Attempts
+
+	// the upsert resets the counter of an expired row, so the increment stays atomic
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO login_attempts (key, failures, locked_until, expires_at) VALUES ($1, 1, $3, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.expires_at > $4 THEN login_attempts.failures + 1 ELSE 1 END,
+			expires_at = GREATEST($2, login_attempts.locked_until)
+		RETURNING failures, locked_until`,
+		key, now.Add(window), time.Time{}, now,
+	).Scan(&attempts.Failures, &attempts.LockedUntil)
+	if err != nil {
+		return storage.Attempts{}, err
+	}
+
+	return attempts, nil
+}
+
+func (s Storage) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO login_attempts (key, failures, locked_until, expires_at) VALUES ($1, 0, $2, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			locked_until = $2,
+			expires_at = GREATEST($2, login_attempts.expires_at)`,
+		key, until,
+	)
+
+	return err
+}
+
+func (s Storage) ResetAttempts(ctx context.Context, key string) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+
+	return err
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"time"
+)
+
+var errChallengeNotFound = fmt.Errorf("challenge %w", storage.ErrNotFound)
+
+func (s Storage) SaveChallenge(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	// replacing lets a challenge be saved again under the same id, e.g. a resent code
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO challenges (id, data, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`,
+		id, data, time.Now().Add(ttl),
+	)
+
+	return err
+}
+
+func (s Storage) SaveChallengeOnce(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	// an expired challenge is replaced, a live one is left as is and no row is affected
+	res, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO challenges (id, data, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at
+		WHERE challenges.expires_at < $4`,
+		id, data, time.Now().Add(ttl), time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return storage.ErrChallengeExists
+	}
+
+	return nil
+}
+
+func (s Storage) TakeChallenge(ctx context.Context, id string) ([]byte, error) {
+	var (
+		data      []byte
+		expiresAt time.Time
+	)
+
+	// deleting on read makes the challenge single use even with concurrent requests
+	err := s.conn(ctx).QueryRowContext(ctx,
+		`DELETE FROM challenges WHERE id = $1 RETURNING data, expires_at`, id,
+	).Scan(&data, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errChallengeNotFound
+		}
+
+		return nil, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, errChallengeNotFound
+	}
+
+	return data, nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+func (s Storage) SetPendingTOTPSecret(ctx context.Context, id, secret string) error {
+	return s.updateUser(ctx, id, `totp_pending_secret = $2`, secret)
+}
+
+func (s Storage) SetMFAMethod(ctx context.Context, id, method string) error {
+	return s.updateUser(ctx, id, `mfa_enabled = $2::text <> '', mfa_method = $2`, method)
+}
+
+func (s Storage) EnableTOTP(ctx context.Context, id, secret string, recoveryCodeHashes []string) error {
+	codes, err := jsonArray(recoveryCodeHashes)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(ctx, id,
+		`mfa_enabled = TRUE, mfa_method = 'totp', totp_secret = $2, recovery_codes = $3, totp_pending_secret = '', totp_last_step = 0`,
+		secret, codes,
+	)
+}
+
+func (s Storage) UseRecoveryCode(ctx context.Context, id, recoveryCodeHash string) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	// matching the hash in the condition makes the check and the removal atomic
+	res, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE users SET recovery_codes = recovery_codes - $2::text WHERE id = $1 AND recovery_codes ? $2::text`,
+		userID, recoveryCodeHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return errors.New("recovery code is not valid")
+	}
+
+	return nil
+}
+
+func (s Storage) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	// comparing the step in the condition makes the check and the update atomic
+	res, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return storage.ErrTOTPStepUsed
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+CREATE TABLE users (
+    id                      BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    email                   TEXT        NOT NULL,
+    password                TEXT        NOT NULL,
+    user_info               JSONB,
+    roles                   JSONB       NOT NULL DEFAULT '[]',
+    verified                BOOLEAN     NOT NULL DEFAULT FALSE,
+    locked                  BOOLEAN     NOT NULL DEFAULT FALSE,
+    password_reset_required BOOLEAN     NOT NULL DEFAULT FALSE,
+    mfa_enabled             BOOLEAN     NOT NULL DEFAULT FALSE,
+    totp_secret             TEXT        NOT NULL DEFAULT '',
+    totp_pending_secret     TEXT        NOT NULL DEFAULT '',
+    recovery_codes          JSONB       NOT NULL DEFAULT '[]',
+    created_at              TIMESTAMPTZ NOT NULL
+);
+
+-- emails differing only in case belong to the same user
+CREATE UNIQUE INDEX users_email_key ON users (lower(email));
+CREATE INDEX users_created_at_idx ON users (created_at DESC);
+
+CREATE TABLE webauthn_credentials (
+    user_id          BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    id               BYTEA       NOT NULL,
+    public_key       BYTEA       NOT NULL,
+    attestation_type TEXT        NOT NULL,
+    transports       JSONB       NOT NULL DEFAULT '[]',
+    aaguid           BYTEA,
+    sign_count       BIGINT      NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE identities (
+    user_id   BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider  TEXT        NOT NULL,
+    subject   TEXT        NOT NULL,
+    email     TEXT        NOT NULL,
+    linked_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX identities_user_id_idx ON identities (user_id);
+
+CREATE TABLE service_accounts (
+    id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name        TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    roles       JSONB       NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    name         TEXT        NOT NULL,
+    owner_id     TEXT        NOT NULL,
+    owner_type   TEXT        NOT NULL,
+    scopes       JSONB       NOT NULL DEFAULT '[]',
+    hash         TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_owner_id_idx ON api_keys (owner_id, created_at DESC);
+
+CREATE TABLE webhooks (
+    id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    url         TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    events      JSONB       NOT NULL DEFAULT '[]',
+    secret      TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+-- a delivery may be recorded after its webhook is deleted, so there is no foreign key
+CREATE TABLE webhook_deliveries (
+    id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    webhook_id  TEXT        NOT NULL,
+    event_id    TEXT        NOT NULL,
+    event_type  TEXT        NOT NULL,
+    attempt     INTEGER     NOT NULL,
+    status_code INTEGER     NOT NULL DEFAULT 0,
+    error       TEXT        NOT NULL DEFAULT '',
+    succeeded   BOOLEAN     NOT NULL,
+    duration    BIGINT      NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at DESC);
+
+CREATE TABLE login_attempts (
+    key          TEXT PRIMARY KEY,
+    failures     INTEGER     NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE challenges (
+    id         TEXT PRIMARY KEY,
+    data       BYTEA       NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE revoked_tokens (
+    id         TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE outbox (
+    id         TEXT PRIMARY KEY,
+    type       TEXT        NOT NULL,
+    payload    JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    due_at     TIMESTAMPTZ NOT NULL,
+    attempts   INTEGER     NOT NULL DEFAULT 0,
+    sinks      JSONB       NOT NULL DEFAULT '[]',
+    last_error TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX outbox_due_at_idx ON outbox (due_at, created_at);
+
+CREATE TABLE audit_log (
+    seq              BIGINT PRIMARY KEY,
+    time             TIMESTAMPTZ NOT NULL,
+    action           TEXT        NOT NULL,
+    outcome          TEXT        NOT NULL,
+    error            TEXT        NOT NULL DEFAULT '',
+    actor_id         TEXT        NOT NULL DEFAULT '',
+    actor_api_key_id TEXT        NOT NULL DEFAULT '',
+    target           TEXT        NOT NULL DEFAULT '',
+    ip               TEXT        NOT NULL DEFAULT '',
+    user_agent       TEXT        NOT NULL DEFAULT '',
+    request_id       TEXT        NOT NULL DEFAULT '',
+    prev_hash        TEXT        NOT NULL,
+    hash             TEXT        NOT NULL
+);
+
+CREATE INDEX audit_log_action_idx ON audit_log (action, seq);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, seq);
+CREATE INDEX audit_log_target_idx ON audit_log (target, seq);
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationsLock is the key of the advisory lock held while the schema is migrated,
+// replicas starting together apply every migration once
+const migrationsLock = 7_305_214
+
+// uniqueViolation is the sql state of a unique constraint error
+const uniqueViolation = "23505"
+
+type Storage struct {
+	db *sql.DB
+}
+
+// New connects to the database and migrates the schema
+func New(ctx context.Context, connectString string) (Storage, error) {
+	const op = "storage.postgres.New"
+
+	db, err := sql.Open("pgx", connectString)
+	if err != nil {
+		return Storage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+
+		return Storage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := Storage{db: db}
+
+	if err := s.migrate(ctx); err != nil {
+		_ = db.Close()
+
+		return Storage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+func (s Storage) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the migrations newer than the schema version, each in its own transaction
+func (s Storage) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		// the files are named <version>_<name>.sql
+		name := strings.TrimPrefix(file, "migrations/")
+
+		prefix, _, _ := strings.Cut(name, "_")
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+
+		query, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if err := s.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := s.conn(ctx).ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsLock); err != nil {
+				return err
+			}
+
+			var applied bool
+
+			err := s.conn(ctx).QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version,
+			).Scan(&applied)
+			if err != nil || applied {
+				return err
+			}
+
+			if _, err := s.conn(ctx).ExecContext(ctx, string(query)); err != nil {
+				return err
+			}
+
+			_, err = s.conn(ctx).ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+
+			return err
+		}); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+type txKey struct{}
+
+// querier is the connection pool or the transaction of ctx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s Storage) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// Transaction runs fn in a transaction, a nested call is a part of the outer transaction
+func (s Storage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SupportsTransactions reports whether the writes of Transaction are atomic
+func (s Storage) SupportsTransactions() bool {
+	return true
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// parseID turns the id of a row into the integer primary key, a malformed id matches no row
+func parseID(id string) (int64, bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+
+	return n, err == nil
+}
+
+// jsonArray keeps string lists in jsonb columns, nil is stored as an empty list
+func jsonArray(values []string) (string, error) {
+	if values == nil {
+		values = []string{}
+	}
+
+	b, err := json.Marshal(values)
+
+	return string(b), err
+}
+
+func scanJSONArray(b []byte) ([]string, error) {
+	var values []string
+
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/storagetest"
+	"os"
+	"testing"
+	"time"
+)
+
+// testStorage connects to the database of GAS_TEST_POSTGRES and empties it, the tests are skipped without it
+func testStorage(t *testing.T) Storage {
+	t.Helper()
+
+	dsn := os.Getenv("GAS_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("GAS_TEST_POSTGRES is not set")
+	}
+
+	ctx := context.Background()
+
+	s, err := New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	if _, err := s.db.ExecContext(ctx, `TRUNCATE users, webauthn_credentials, identities, service_accounts, api_keys,
+		webhooks, webhook_deliveries, login_attempts, challenges, revoked_tokens, outbox, audit_log`); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return testStorage(t)
+	})
+}
+
+func TestOutbox(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	first := events.New(constant.EventUserCreated, nil)
+	second := events.New(constant.EventUserDeleted, nil)
+	first.CreatedAt = second.CreatedAt.Add(-time.Second)
+
+	for _, event := range []events.Event{first, second} {
+		if err := s.AddOutboxEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := s.ClaimOutboxEvents(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 2 || claimed[0].Event.ID != first.ID || claimed[1].Event.ID != second.ID {
+		t.Fatalf("Expected both events the oldest first, got %v", claimed)
+	}
+
+	if claimed, err := s.ClaimOutboxEvents(ctx, 10, time.Minute); err != nil || len(claimed) != 0 {
+		t.Errorf("Expected the leased events to be hidden, got %d", len(claimed))
+	}
+
+	if err := s.CompleteOutboxSink(ctx, first.ID, constant.SinkWebhooks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RetryOutboxEvent(ctx, first.ID, time.Now().Add(-time.Second), "broker is down"); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err = s.ClaimOutboxEvents(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 1 || claimed[0].Attempts != 1 || len(claimed[0].Sinks) != 1 {
+		t.Errorf("Expected the retried event with 1 attempt and 1 sink, got %v", claimed)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	last, err := s.LastAuditEntry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last.Seq != 0 {
+		t.Errorf("Expected the zero entry, got %d", last.Seq)
+	}
+
+	for seq := int64(1); seq <= 3; seq++ {
+		if err := s.AddAuditEntry(ctx, storage.AuditEntry{Seq: seq, Time: time.Now(), Action: constant.AuditSignIn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.AddAuditEntry(ctx, storage.AuditEntry{Seq: 3}); !errors.Is(err, storage.ErrAuditSeqTaken) {
+		t.Errorf("Expected %v, got %v", storage.ErrAuditSeqTaken, err)
+	}
+
+	entries, err := s.AuditEntries(ctx, storage.AuditFilter{BeforeSeq: 3, Descending: true, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Seq != 2 {
+		t.Errorf("Expected entry 2, got %v", entries)
+	}
+}
@@ -0,0 +1,345 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errUserNotFound = fmt.Errorf("user %w", storage.ErrNotFound)
+	errEmailTaken   = storage.ErrEmailTaken
+)
+
+// userColumns selects a user row with its passkeys and linked identities aggregated as json
+const userColumns = `id, email, password, user_info, roles, verified, locked, password_reset_required, mfa_enabled,
+	mfa_method, totp_secret, totp_pending_secret, totp_last_step, recovery_codes, created_at,
+	COALESCE((
+		SELECT jsonb_agg(jsonb_build_object(
+			'id', encode(c.id, 'base64'),
+			'public_key', encode(c.public_key, 'base64'),
+			'attestation_type', c.attestation_type,
+			'transports', c.transports,
+			'aaguid', encode(c.aaguid, 'base64'),
+			'sign_count', c.sign_count,
+			'created_at', c.created_at
+		) ORDER BY c.created_at)
+		FROM webauthn_credentials c WHERE c.user_id = users.id
+	), '[]'),
+	COALESCE((
+		SELECT jsonb_agg(jsonb_build_object(
+			'provider', i.provider,
+			'subject', i.subject,
+			'email', i.email,
+			'linked_at', i.linked_at
+		) ORDER BY i.linked_at)
+		FROM identities i WHERE i.user_id = users.id
+	), '[]')`
+
+// credentialRow is a passkey as userColumns aggregates it, bytea columns come base64 encoded
+type credentialRow struct {
+	ID              []byte    `json:"id"`
+	PublicKey       []byte    `json:"public_key"`
+	AttestationType string    `json:"attestation_type"`
+	Transports      []string  `json:"transports"`
+	AAGUID          []byte    `json:"aaguid"`
+	SignCount       uint32    `json:"sign_count"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (storage.User, error) {
+	var (
+		user                                                  storage.User
+		id                                                    int64
+		userInfo, roles, recoveryCodes, credentials, identity []byte
+	)
+
+	err := row.Scan(
+		&id,
+		&user.Email,
+		&user.PasswordHash,
+		&userInfo,
+		&roles,
+		&user.Verified,
+		&user.Locked,
+		&user.PasswordResetRequired,
+		&user.MFAEnabled,
+		&user.MFAMethod,
+		&user.TOTPSecret,
+		&user.PendingTOTPSecret,
+		&user.TOTPLastStep,
+		&recoveryCodes,
+		&user.CreatedAt,
+		&credentials,
+		&identity,
+	)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	user.ID = strconv.FormatInt(id, 10)
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	if userInfo != nil {
+		if err := json.Unmarshal(userInfo, &user.UserInfo); err != nil {
+			return storage.User{}, err
+		}
+	}
+
+	if user.Roles, err = scanJSONArray(roles); err != nil {
+		return storage.User{}, err
+	}
+
+	if user.RecoveryCodeHashes, err = scanJSONArray(recoveryCodes); err != nil {
+		return storage.User{}, err
+	}
+
+	var rows []credentialRow
+
+	if err := json.Unmarshal(credentials, &rows); err != nil {
+		return storage.User{}, err
+	}
+
+	user.WebAuthnCredentials = make([]storage.WebAuthnCredential, 0, len(rows))
+	for _, c := range rows {
+		user.WebAuthnCredentials = append(user.WebAuthnCredentials, storage.WebAuthnCredential(c))
+	}
+
+	if err := json.Unmarshal(identity, &user.Identities); err != nil {
+		return storage.User{}, err
+	}
+
+	return user, nil
+}
+
+// user returns the user matching the condition on the users table
+func (s Storage) user(ctx context.Context, where string, args ...interface{}) (storage.User, error) {
+	user, err := scanUser(s.conn(ctx).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+where, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.User{}, errUserNotFound
+		}
+
+		return storage.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s Storage) UserByEmail(ctx context.Context, email string) (storage.User, error) {
+	return s.user(ctx, `lower(email) = lower($1)`, email)
+}
+
+func (s Storage) DoesEmailExist(ctx context.Context, email string) error {
+	var exists bool
+
+	err := s.conn(ctx).QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))`, email,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errEmailTaken
+	}
+
+	return nil
+}
+
+func (s Storage) CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error) {
+	info, err := json.Marshal(userInfo)
+	if err != nil {
+		return "0", err
+	}
+
+	var id int64
+
+	err = s.conn(ctx).QueryRowContext(ctx,
+		`INSERT INTO users (email, password, user_info, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		email, password, string(info), time.Now().UTC(),
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return "0", errEmailTaken
+		}
+
+		return "0", err
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (s Storage) Users(ctx context.Context, filter storage.UserFilter) ([]storage.User, int64, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EmailPrefix != "" {
+		where(`lower(email) LIKE $%d`, likePrefix(strings.ToLower(filter.EmailPrefix)))
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		where(`created_at >= $%d`, filter.CreatedAfter)
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		where(`created_at < $%d`, filter.CreatedBefore)
+	}
+
+	if filter.Verified != nil {
+		where(`verified = $%d`, *filter.Verified)
+	}
+
+	if filter.Locked != nil {
+		where(`locked = $%d`, *filter.Locked)
+	}
+
+	query := ``
+	if len(conditions) > 0 {
+		query = ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	var total int64
+
+	if err := s.conn(ctx).QueryRowContext(ctx, `SELECT count(*) FROM users`+query, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// a zero limit is no limit, LIMIT NULL returns all the rows
+	var rowsLimit interface{}
+	if filter.Limit > 0 {
+		rowsLimit = filter.Limit
+	}
+
+	args = append(args, filter.Offset, rowsLimit)
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		fmt.Sprintf(`SELECT %s FROM users%s ORDER BY created_at DESC, id DESC OFFSET $%d LIMIT $%d`,
+			userColumns, query, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]storage.User, 0)
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (s Storage) UserByID(ctx context.Context, id string) (storage.User, error) {
+	userID, ok := parseID(id)
+	if !ok {
+		return storage.User{}, errUserNotFound
+	}
+
+	return s.user(ctx, `id = $1`, userID)
+}
+
+func (s Storage) UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error {
+	info, err := json.Marshal(userInfo)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(ctx, id, `user_info = $2`, string(info))
+}
+
+func (s Storage) SetLocked(ctx context.Context, id string, locked bool) error {
+	return s.updateUser(ctx, id, `locked = $2`, locked)
+}
+
+func (s Storage) SetRoles(ctx context.Context, id string, roles []string) error {
+	value, err := jsonArray(roles)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(ctx, id, `roles = $2`, value)
+}
+
+func (s Storage) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	return s.updateUser(ctx, id, `password_reset_required = $2`, required)
+}
+
+func (s Storage) SetPassword(ctx context.Context, id, passwordHash string) error {
+	return s.updateUser(ctx, id, `password = $2, password_reset_required = false`, passwordHash)
+}
+
+func (s Storage) DeleteUser(ctx context.Context, id string) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	res, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return userAffected(res)
+}
+
+// updateUser sets the columns of the user, the id is the first argument of the set clause
+func (s Storage) updateUser(ctx context.Context, id, set string, args ...interface{}) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	res, err := s.conn(ctx).ExecContext(ctx, `UPDATE users SET `+set+` WHERE id = $1`, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	return userAffected(res)
+}
+
+func userAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
+
+// likePrefix escapes the wildcards of the prefix for a LIKE pattern
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + `%`
+}
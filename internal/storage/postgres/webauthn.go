@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+func (s Storage) AddWebAuthnCredential(ctx context.Context, id string, credential storage.WebAuthnCredential) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	transports, err := jsonArray(credential.Transports)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO webauthn_credentials (user_id, id, public_key, attestation_type, transports, aaguid, sign_count, created_at)
+		SELECT $1::bigint, $2::bytea, $3::bytea, $4::text, $5::jsonb, $6::bytea, $7::bigint, $8::timestamptz
+		WHERE EXISTS (SELECT 1 FROM users WHERE id = $1)`,
+		userID,
+		credential.ID,
+		credential.PublicKey,
+		credential.AttestationType,
+		transports,
+		credential.AAGUID,
+		int64(credential.SignCount),
+		credential.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return userAffected(res)
+}
+
+func (s Storage) UpdateWebAuthnSignCount(ctx context.Context, id string, credentialID []byte, signCount uint32) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	// comparing the count in the condition makes the check and the update atomic
+	res, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE webauthn_credentials SET sign_count = $3 WHERE user_id = $1 AND id = $2 AND sign_count < $3`,
+		userID, credentialID, int64(signCount),
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return storage.ErrSignCountUsed
+	}
+
+	return nil
+}
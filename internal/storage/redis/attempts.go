@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	goredis "github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// addFailedAttempt increments the counter and keeps it until the window passes or the lock ends, whichever is later
+var addFailedAttempt = goredis.NewScript(`
+local failures = redis.call('HINCRBY', KEYS[1], 'failures', 1)
+local locked = tonumber(redis.call('HGET', KEYS[1], 'locked_until') or '0')
+local ttl = math.max(tonumber(ARGV[1]), locked - tonumber(ARGV[2]))
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {failures, locked}
+`)
+
+// lockAttempts sets the lock and keeps the counter at least until the lock ends
+var lockAttempts = goredis.NewScript(`
+redis.call('HSET', KEYS[1], 'locked_until', ARGV[1])
+local ttl = math.max(redis.call('PTTL', KEYS[1]), tonumber(ARGV[1]) - tonumber(ARGV[2]))
+if ttl <= 0 then
+	return redis.call('DEL', KEYS[1])
+end
+return redis.call('PEXPIRE', KEYS[1], ttl)
+`)
+
+func (s *Storage) FailedAttempts(ctx context.Context, key string) (storage.Attempts, error) {
+	values, err := s.client.HMGet(ctx, s.key("attempts", key), "failures", "locked_until").Result()
+	if err != nil {
+		return storage.Attempts{}, err
+	}
+
+	var attempts storage.Attempts
+
+	if v, ok := values[0].(string); ok {
+		if attempts.Failures, err = strconv.Atoi(v); err != nil {
+			return storage.Attempts{}, err
+		}
+	}
+
+	if v, ok := values[1].(string); ok {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return storage.Attempts{}, err
+		}
+
+		attempts.LockedUntil = lockedUntil(ms)
+	}
+
+	return attempts, nil
+}
+
+func (s *Storage) AddFailedAttempt(ctx context.Context, key string, window time.Duration) (storage.Attempts, error) {
+	result, err := addFailedAttempt.Run(ctx, s.client,
+		[]string{s.key("attempts", key)}, millis(window), time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return storage.Attempts{}, err
+	}
+
+	if len(result) != 2 {
+		return storage.Attempts{}, errors.New("unexpected reply of the attempts script")
+	}
+
+	return storage.Attempts{
+		Failures:    int(result[0]),
+		LockedUntil: lockedUntil(result[1]),
+	}, nil
+}
+
+func (s *Storage) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	return lockAttempts.Run(ctx, s.client,
+		[]string{s.key("attempts", key)}, until.UnixMilli(), time.Now().UnixMilli(),
+	).Err()
+}
+
+func (s *Storage) ResetAttempts(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.key("attempts", key)).Err()
+}
+
+// lockedUntil is the time of the lock kept in unix milliseconds, zero means no lock
+func lockedUntil(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms)
+}
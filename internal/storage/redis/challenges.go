@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	goredis "github.com/redis/go-redis/v9"
+	"time"
+)
+
+var errChallengeNotFound = fmt.Errorf("challenge %w", storage.ErrNotFound)
+
+func (s *Storage) SaveChallenge(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.key("challenge", id), data, time.Duration(millis(ttl))*time.Millisecond).Err()
+}
+
+func (s *Storage) SaveChallengeOnce(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	ok, err := s.client.SetNX(ctx, s.key("challenge", id), data, time.Duration(millis(ttl))*time.Millisecond).Result()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return storage.ErrChallengeExists
+	}
+
+	return nil
+}
+
+func (s *Storage) TakeChallenge(ctx context.Context, id string) ([]byte, error) {
+	key := s.key("challenge", id)
+
+	var get *goredis.StringCmd
+
+	// GET and DEL in MULTI, GETDEL needs redis 6.2
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+
+		return nil
+	})
+	if errors.Is(err, goredis.Nil) {
+		return nil, errChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return get.Bytes()
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/storage"
+	goredis "github.com/redis/go-redis/v9"
+	"time"
+)
+
+// takeToken is the token bucket of the memory store run on the server, so that the replicas share the bucket.
+// The times are in microseconds, the bucket expires when it is full again. The time is stored as it was passed,
+// lua would format it with the exponent
+var takeToken = goredis.NewScript(`
+local limit = tonumber(ARGV[1])
+local per_token = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(bucket[1]) or limit
+local updated_at = tonumber(bucket[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - updated_at) / per_token)
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) * per_token)
+end
+local reset_after = math.ceil((limit - tokens) * per_token)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.floor(reset_after / 1000) + 1)
+return {allowed, math.floor(tokens), retry_after, reset_after}
+`)
+
+func (s *Storage) Take(ctx context.Context, key string, rate storage.Rate) (storage.RateLimitResult, error) {
+	perToken := rate.Period / time.Duration(rate.Limit)
+
+	result, err := takeToken.Run(ctx, s.client, []string{s.key("ratelimit", key)},
+		rate.Limit, perToken.Microseconds(), time.Now().UnixMicro(),
+	).Int64Slice()
+	if err != nil {
+		return storage.RateLimitResult{}, err
+	}
+
+	if len(result) != 4 {
+		return storage.RateLimitResult{}, errors.New("unexpected reply of the rate limit script")
+	}
+
+	return storage.RateLimitResult{
+		Allowed:    result[0] == 1,
+		Remaining:  int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Microsecond,
+		ResetAfter: time.Duration(result[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/degeboman/gas/internal/config"
+	goredis "github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+// embeddedTick is how often the clock of the embedded server is moved forward, the keys expire by it
+const embeddedTick = 100 * time.Millisecond
+
+// Storage keeps the sign in attempts, ceremonies, revoked tokens and rate limit buckets in redis and passes
+// the changes of the users between the caches, so that every replica of gas sees the same state.
+// Every key expires on its own
+type Storage struct {
+	client *goredis.Client
+	prefix string
+
+	// embedded is the in-process server when gas runs without a real one
+	embedded *miniredis.Miniredis
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// New connects to the server of the settings, or starts the embedded one
+func New(ctx context.Context, cfg config.RedisSettings) (*Storage, error) {
+	const op = "storage.redis.New"
+
+	s := &Storage{prefix: cfg.KeyPrefix}
+
+	address := cfg.Address
+
+	if cfg.Embedded {
+		embedded, err := miniredis.Run()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		s.embedded = embedded
+		s.stop = make(chan struct{})
+		s.stopped.Add(1)
+
+		go s.runClock()
+
+		address = embedded.Addr()
+	}
+
+	s.client = goredis.NewClient(&goredis.Options{
+		Addr:     address,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		_ = s.Close()
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// runClock moves the clock of the embedded server, it doesn't expire the keys by itself
+func (s *Storage) runClock() {
+	defer s.stopped.Done()
+
+	ticker := time.NewTicker(embeddedTick)
+	defer ticker.Stop()
+
+	last := time.Now()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.embedded.FastForward(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+func (s *Storage) Close() error {
+	err := s.client.Close()
+
+	if s.embedded != nil {
+		close(s.stop)
+		s.stopped.Wait()
+
+		s.embedded.Close()
+	}
+
+	return err
+}
+
+// key namespaces the key of a store, the prefix keeps gas apart from the other users of the server
+func (s *Storage) key(store, key string) string {
+	return s.prefix + store + ":" + key
+}
+
+// millis is the duration in milliseconds rounded up, redis rejects a zero expiry
+func millis(d time.Duration) int64 {
+	ms := int64((d + time.Millisecond - 1) / time.Millisecond)
+	if ms < 1 {
+		return 1
+	}
+
+	return ms
+}
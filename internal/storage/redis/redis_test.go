@@ -0,0 +1,221 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"testing"
+	"time"
+)
+
+func newEmbedded(t *testing.T) *Storage {
+	t.Helper()
+
+	s, err := New(context.Background(), config.RedisSettings{KeyPrefix: "gas:", Embedded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestAttempts(t *testing.T) {
+	ctx := context.Background()
+	s := newEmbedded(t)
+
+	for i := 1; i <= 3; i++ {
+		attempts, err := s.AddFailedAttempt(ctx, "rupychman@mail.ru", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attempts.Failures != i {
+			t.Errorf("Expected %v, got %v", i, attempts.Failures)
+		}
+	}
+
+	until := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	if err := s.LockAttempts(ctx, "rupychman@mail.ru", until); err != nil {
+		t.Fatal(err)
+	}
+
+	// the counter outlives the window while the lock lasts
+	s.embedded.FastForward(2 * time.Minute)
+
+	attempts, err := s.FailedAttempts(ctx, "rupychman@mail.ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts.Failures != 3 || !attempts.LockedUntil.Equal(until) {
+		t.Errorf("Expected 3 failures locked until %v, got %v", until, attempts)
+	}
+
+	s.embedded.FastForward(time.Hour)
+
+	if attempts, err := s.FailedAttempts(ctx, "rupychman@mail.ru"); err != nil || attempts.Failures != 0 {
+		t.Errorf("Expected the attempts to expire, got %v, %v", attempts, err)
+	}
+
+	if _, err := s.AddFailedAttempt(ctx, "degeboman@mail.ru", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ResetAttempts(ctx, "degeboman@mail.ru"); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts, err := s.FailedAttempts(ctx, "degeboman@mail.ru"); err != nil || attempts.Failures != 0 {
+		t.Errorf("Expected the attempts to be reset, got %v, %v", attempts, err)
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	ctx := context.Background()
+	s := newEmbedded(t)
+
+	if err := s.SaveChallenge(ctx, "1", []byte("session"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.TakeChallenge(ctx, "1")
+	if err != nil || string(data) != "session" {
+		t.Errorf("Expected session, got %q, %v", data, err)
+	}
+
+	if _, err := s.TakeChallenge(ctx, "1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected %v, got %v", storage.ErrNotFound, err)
+	}
+
+	if err := s.SaveChallenge(ctx, "2", []byte("session"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	s.embedded.FastForward(2 * time.Minute)
+
+	if _, err := s.TakeChallenge(ctx, "2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected %v, got %v", storage.ErrNotFound, err)
+	}
+
+	if err := s.SaveChallengeOnce(ctx, "3", []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveChallengeOnce(ctx, "3", []byte("data"), time.Minute); !errors.Is(err, storage.ErrChallengeExists) {
+		t.Errorf("Expected %v, got %v", storage.ErrChallengeExists, err)
+	}
+
+	s.embedded.FastForward(2 * time.Minute)
+
+	if err := s.SaveChallengeOnce(ctx, "3", []byte("data"), time.Minute); err != nil {
+		t.Errorf("Expected the expired challenge to be replaced, got %v", err)
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	ctx := context.Background()
+	s := newEmbedded(t)
+
+	if err := s.Revoke(ctx, "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke(ctx, "2", -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		id       string
+		after    time.Duration
+		expected bool
+	}{
+		{"1", 0, true},
+		{"2", 0, false},
+		{"3", 0, false},
+		{"1", 2 * time.Minute, false},
+	}
+
+	for _, d := range data {
+		s.embedded.FastForward(d.after)
+
+		revoked, err := s.IsRevoked(ctx, d.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if revoked != d.expected {
+			t.Errorf("%s after %v: expected %v, got %v", d.id, d.after, d.expected, revoked)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	ctx := context.Background()
+	s := newEmbedded(t)
+
+	rate := storage.Rate{Limit: 2, Period: time.Hour}
+
+	data := []struct {
+		allowed   bool
+		remaining int
+	}{
+		{true, 1},
+		{true, 0},
+		{false, 0},
+	}
+
+	for i, d := range data {
+		res, err := s.Take(ctx, "10.0.0.1", rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Allowed != d.allowed || res.Remaining != d.remaining {
+			t.Errorf("%d: expected %v/%d, got %v/%d", i, d.allowed, d.remaining, res.Allowed, res.Remaining)
+		}
+
+		if !res.Allowed && (res.RetryAfter <= 0 || res.RetryAfter > rate.Period/2) {
+			t.Errorf("%d: expected retry after up to %v, got %v", i, rate.Period/2, res.RetryAfter)
+		}
+	}
+
+	// the buckets are kept by key
+	if res, err := s.Take(ctx, "10.0.0.2", rate); err != nil || !res.Allowed {
+		t.Errorf("Expected the other key to be allowed, got %v, %v", res, err)
+	}
+}
+
+func TestUserChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := newEmbedded(t)
+
+	subscribed := make(chan struct{}, 1)
+	dropped := make(chan string, 1)
+
+	go s.SubscribeUserChanges(ctx, func(id string) { dropped <- id }, func() { subscribed <- struct{}{} })
+
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the subscription to reset the cache")
+	}
+
+	if err := s.PublishUserChange(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case id := <-dropped:
+		if id != "1" {
+			t.Errorf("Expected %v, got %v", "1", id)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the published change to be delivered")
+	}
+}
@@ -0,0 +1,21 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+func (s *Storage) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	// the token has expired already and is rejected anyway
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(ctx, s.key("revoked", id), 1, time.Duration(millis(ttl))*time.Millisecond).Err()
+}
+
+func (s *Storage) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.key("revoked", id)).Result()
+
+	return n > 0, err
+}
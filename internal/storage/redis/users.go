@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	goredis "github.com/redis/go-redis/v9"
+	"time"
+)
+
+// resubscribeDelay is the pause after a failed receive, the client reconnects on the next one
+const resubscribeDelay = time.Second
+
+// PublishUserChange tells the other replicas to drop the user from their caches
+func (s *Storage) PublishUserChange(ctx context.Context, id string) error {
+	return s.client.Publish(ctx, s.key("channel", "users"), id).Err()
+}
+
+// SubscribeUserChanges calls drop with the ids published by any replica until ctx is done, reset is called
+// on every subscription, including the one after a reconnect, since the ids published without one are lost
+func (s *Storage) SubscribeUserChanges(ctx context.Context, drop func(id string), reset func()) {
+	pubsub := s.client.Subscribe(ctx, s.key("channel", "users"))
+
+	// a blocked receive does not watch ctx, closing the subscription ends it
+	go func() {
+		<-ctx.Done()
+		_ = pubsub.Close()
+	}()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeDelay):
+				continue
+			}
+		}
+
+		switch m := msg.(type) {
+		case *goredis.Subscription:
+			if m.Kind == "subscribe" {
+				reset()
+			}
+		case *goredis.Message:
+			drop(m.Payload)
+		}
+	}
+}
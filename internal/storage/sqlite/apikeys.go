@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"time"
+)
+
+var errAPIKeyNotFound = fmt.Errorf("api key %w", storage.ErrNotFound)
+
+const apiKeyColumns = `id, name, owner_id, owner_type, scopes, hash, created_at, expires_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (storage.APIKey, error) {
+	var (
+		key    storage.APIKey
+		scopes string
+	)
+
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.OwnerID,
+		&key.OwnerType,
+		&scopes,
+		&key.Hash,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+
+	key.CreatedAt = key.CreatedAt.UTC()
+	key.ExpiresAt = utcPtr(key.ExpiresAt)
+	key.LastUsedAt = utcPtr(key.LastUsedAt)
+	key.RevokedAt = utcPtr(key.RevokedAt)
+
+	if key.Scopes, err = scanJSONArray(scopes); err != nil {
+		return storage.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (s Storage) CreateAPIKey(ctx context.Context, key storage.APIKey) error {
+	scopes, err := jsonArray(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn(ctx).ExecContext(ctx,
+		`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		key.ID,
+		key.Name,
+		key.OwnerID,
+		key.OwnerType,
+		scopes,
+		key.Hash,
+		timestamp(key.CreatedAt),
+		nullTimestamp(key.ExpiresAt),
+		nullTimestamp(key.LastUsedAt),
+		nullTimestamp(key.RevokedAt),
+	)
+
+	return err
+}
+
+func (s Storage) APIKeyByID(ctx context.Context, id string) (storage.APIKey, error) {
+	key, err := scanAPIKey(s.conn(ctx).QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.APIKey{}, errAPIKeyNotFound
+		}
+
+		return storage.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (s Storage) APIKeys(ctx context.Context, ownerID string) ([]storage.APIKey, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE owner_id = $1 ORDER BY created_at DESC`, ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]storage.APIKey, 0)
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s Storage) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, timestamp(usedAt))
+
+	return err
+}
+
+func (s Storage) RevokeAPIKey(ctx context.Context, ownerID, id string, revokedAt time.Time) error {
+	res, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = $3 WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL`,
+		id, ownerID, timestamp(revokedAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return errAPIKeyNotFound
+	}
+
+	return nil
+}
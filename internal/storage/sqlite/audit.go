@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"strings"
+)
+
+const auditColumns = `seq, time, action, outcome, error, actor_id, actor_api_key_id, target, ip, user_agent, request_id, prev_hash, hash`
+
+func scanAuditEntry(row rowScanner) (storage.AuditEntry, error) {
+	var e storage.AuditEntry
+
+	err := row.Scan(
+		&e.Seq,
+		&e.Time,
+		&e.Action,
+		&e.Outcome,
+		&e.Error,
+		&e.ActorID,
+		&e.ActorAPIKeyID,
+		&e.Target,
+		&e.IP,
+		&e.UserAgent,
+		&e.RequestID,
+		&e.PrevHash,
+		&e.Hash,
+	)
+	if err != nil {
+		return storage.AuditEntry{}, err
+	}
+
+	e.Time = e.Time.UTC()
+
+	return e, nil
+}
+
+func (s Storage) LastAuditEntry(ctx context.Context) (storage.AuditEntry, error) {
+	e, err := scanAuditEntry(s.conn(ctx).QueryRowContext(ctx,
+		`SELECT `+auditColumns+` FROM audit_log ORDER BY seq DESC LIMIT 1`,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.AuditEntry{}, nil
+		}
+
+		return storage.AuditEntry{}, err
+	}
+
+	return e, nil
+}
+
+func (s Storage) AddAuditEntry(ctx context.Context, e storage.AuditEntry) error {
+	_, err := s.conn(ctx).ExecContext(ctx,
+		`INSERT INTO audit_log (`+auditColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		e.Seq,
+		timestamp(e.Time),
+		e.Action,
+		e.Outcome,
+		e.Error,
+		e.ActorID,
+		e.ActorAPIKeyID,
+		e.Target,
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+		e.PrevHash,
+		e.Hash,
+	)
+	if isUniqueViolation(err) {
+		return storage.ErrAuditSeqTaken
+	}
+
+	return err
+}
+
+func (s Storage) AuditEntries(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	for column, value := range map[string]string{
+		"action":   filter.Action,
+		"actor_id": filter.ActorID,
+		"target":   filter.Target,
+		"outcome":  filter.Outcome,
+	} {
+		if value != "" {
+			where(column+` = $%d`, value)
+		}
+	}
+
+	if filter.AfterSeq > 0 {
+		where(`seq > $%d`, filter.AfterSeq)
+	}
+
+	if filter.BeforeSeq > 0 {
+		where(`seq < $%d`, filter.BeforeSeq)
+	}
+
+	if !filter.Since.IsZero() {
+		where(`time >= $%d`, timestamp(filter.Since))
+	}
+
+	if !filter.Until.IsZero() {
+		where(`time < $%d`, timestamp(filter.Until))
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	if filter.Descending {
+		query += ` ORDER BY seq DESC`
+	} else {
+		query += ` ORDER BY seq`
+	}
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]storage.AuditEntry, 0)
+
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
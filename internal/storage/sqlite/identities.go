@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+func (s Storage) UserByIdentity(ctx context.Context, provider, subject string) (storage.User, error) {
+	return s.user(ctx, `id = (SELECT user_id FROM identities WHERE provider = $1 AND subject = $2)`, provider, subject)
+}
+
+func (s Storage) AddIdentity(ctx context.Context, id string, identity storage.Identity) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	res, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO identities (user_id, provider, subject, email, linked_at)
+		SELECT $1, $2, $3, $4, $5
+		WHERE EXISTS (SELECT 1 FROM users WHERE id = $1)`,
+		userID, identity.Provider, identity.Subject, identity.Email, timestamp(identity.LinkedAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	return userAffected(res)
+}
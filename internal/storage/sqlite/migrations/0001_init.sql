@@ -0,0 +1,143 @@
+-- times are TIMESTAMP text in utc, lists are json text
+
+CREATE TABLE users (
+    id                      INTEGER PRIMARY KEY AUTOINCREMENT,
+    email                   TEXT      NOT NULL,
+    password                TEXT      NOT NULL,
+    user_info               TEXT,
+    roles                   TEXT      NOT NULL DEFAULT '[]',
+    verified                BOOLEAN   NOT NULL DEFAULT FALSE,
+    locked                  BOOLEAN   NOT NULL DEFAULT FALSE,
+    password_reset_required BOOLEAN   NOT NULL DEFAULT FALSE,
+    mfa_enabled             BOOLEAN   NOT NULL DEFAULT FALSE,
+    totp_secret             TEXT      NOT NULL DEFAULT '',
+    totp_pending_secret     TEXT      NOT NULL DEFAULT '',
+    recovery_codes          TEXT      NOT NULL DEFAULT '[]',
+    created_at              TIMESTAMP NOT NULL
+);
+
+-- emails differing only in case belong to the same user
+CREATE UNIQUE INDEX users_email_key ON users (lower(email));
+CREATE INDEX users_created_at_idx ON users (created_at DESC);
+
+CREATE TABLE webauthn_credentials (
+    user_id          INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    id               BLOB      NOT NULL,
+    public_key       BLOB      NOT NULL,
+    attestation_type TEXT      NOT NULL,
+    transports       TEXT      NOT NULL DEFAULT '[]',
+    aaguid           BLOB,
+    sign_count       INTEGER   NOT NULL,
+    created_at       TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE identities (
+    user_id   INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider  TEXT      NOT NULL,
+    subject   TEXT      NOT NULL,
+    email     TEXT      NOT NULL,
+    linked_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX identities_user_id_idx ON identities (user_id);
+
+CREATE TABLE service_accounts (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT      NOT NULL,
+    description TEXT      NOT NULL DEFAULT '',
+    roles       TEXT      NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    name         TEXT      NOT NULL,
+    owner_id     TEXT      NOT NULL,
+    owner_type   TEXT      NOT NULL,
+    scopes       TEXT      NOT NULL DEFAULT '[]',
+    hash         TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX api_keys_owner_id_idx ON api_keys (owner_id, created_at DESC);
+
+CREATE TABLE webhooks (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    url         TEXT      NOT NULL,
+    description TEXT      NOT NULL DEFAULT '',
+    events      TEXT      NOT NULL DEFAULT '[]',
+    secret      TEXT      NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+-- a delivery may be recorded after its webhook is deleted, so there is no foreign key
+CREATE TABLE webhook_deliveries (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id  TEXT      NOT NULL,
+    event_id    TEXT      NOT NULL,
+    event_type  TEXT      NOT NULL,
+    attempt     INTEGER   NOT NULL,
+    status_code INTEGER   NOT NULL DEFAULT 0,
+    error       TEXT      NOT NULL DEFAULT '',
+    succeeded   BOOLEAN   NOT NULL,
+    duration    INTEGER   NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at DESC);
+
+CREATE TABLE login_attempts (
+    key          TEXT PRIMARY KEY,
+    failures     INTEGER   NOT NULL,
+    locked_until TIMESTAMP NOT NULL,
+    expires_at   TIMESTAMP NOT NULL
+);
+
+CREATE TABLE challenges (
+    id         TEXT PRIMARY KEY,
+    data       BLOB      NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE revoked_tokens (
+    id         TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE outbox (
+    id         TEXT PRIMARY KEY,
+    type       TEXT      NOT NULL,
+    payload    TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    due_at     TIMESTAMP NOT NULL,
+    attempts   INTEGER   NOT NULL DEFAULT 0,
+    sinks      TEXT      NOT NULL DEFAULT '[]',
+    last_error TEXT      NOT NULL DEFAULT ''
+);
+
+CREATE INDEX outbox_due_at_idx ON outbox (due_at, created_at);
+
+CREATE TABLE audit_log (
+    seq              INTEGER PRIMARY KEY,
+    time             TIMESTAMP NOT NULL,
+    action           TEXT      NOT NULL,
+    outcome          TEXT      NOT NULL,
+    error            TEXT      NOT NULL DEFAULT '',
+    actor_id         TEXT      NOT NULL DEFAULT '',
+    actor_api_key_id TEXT      NOT NULL DEFAULT '',
+    target           TEXT      NOT NULL DEFAULT '',
+    ip               TEXT      NOT NULL DEFAULT '',
+    user_agent       TEXT      NOT NULL DEFAULT '',
+    request_id       TEXT      NOT NULL DEFAULT '',
+    prev_hash        TEXT      NOT NULL,
+    hash             TEXT      NOT NULL
+);
+
+CREATE INDEX audit_log_action_idx ON audit_log (action, seq);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, seq);
+CREATE INDEX audit_log_target_idx ON audit_log (target, seq);
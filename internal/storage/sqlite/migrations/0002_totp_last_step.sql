@@ -0,0 +1,2 @@
+-- the time step of the last accepted totp code, a code of the same or an earlier step is a replay
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- the second factor two-factor authentication asks for, it was not kept before and is taken from the
+-- factors the user has, two-factor authentication without a usable factor is turned off
+ALTER TABLE users ADD COLUMN mfa_method TEXT NOT NULL DEFAULT '';
+
+UPDATE users SET mfa_method = 'totp' WHERE mfa_enabled AND totp_secret <> '';
+
+UPDATE users SET mfa_method = 'webauthn'
+WHERE mfa_enabled AND mfa_method = '' AND EXISTS (SELECT 1 FROM webauthn_credentials c WHERE c.user_id = users.id);
+
+UPDATE users SET mfa_enabled = FALSE WHERE mfa_enabled AND mfa_method = '';
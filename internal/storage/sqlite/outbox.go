@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"sort"
+	"time"
+)
+
+func (s Storage) AddOutboxEvent(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn(ctx).ExecContext(ctx,
+		`INSERT INTO outbox (id, type, payload, created_at, due_at) VALUES ($1, $2, $3, $4, $4)`,
+		event.ID, event.Type, string(payload), timestamp(event.CreatedAt),
+	)
+
+	return err
+}
+
+func (s Storage) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]storage.OutboxEvent, error) {
+	now := time.Now()
+
+	// the update holds the write lock, so the relays of other processes claim the next events
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		UPDATE outbox SET due_at = $1
+		WHERE id IN (
+			SELECT id FROM outbox WHERE due_at <= $2 ORDER BY created_at LIMIT $3
+		)
+		RETURNING payload, attempts, sinks, created_at`,
+		timestamp(now.Add(lease)), timestamp(now), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type claim struct {
+		event     storage.OutboxEvent
+		createdAt time.Time
+	}
+
+	var claims []claim
+
+	for rows.Next() {
+		var (
+			c              claim
+			payload, sinks string
+		)
+
+		if err := rows.Scan(&payload, &c.event.Attempts, &sinks, &c.createdAt); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(payload), &c.event.Event); err != nil {
+			return nil, err
+		}
+
+		if c.event.Sinks, err = scanJSONArray(sinks); err != nil {
+			return nil, err
+		}
+
+		claims = append(claims, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// returning does not keep the order of the subquery
+	sort.Slice(claims, func(i, j int) bool { return claims[i].createdAt.Before(claims[j].createdAt) })
+
+	claimed := make([]storage.OutboxEvent, 0, len(claims))
+	for _, c := range claims {
+		claimed = append(claimed, c.event)
+	}
+
+	return claimed, nil
+}
+
+func (s Storage) CompleteOutboxSink(ctx context.Context, id, sink string) error {
+	_, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE outbox SET sinks = json_insert(sinks, '$[#]', $2)
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM json_each(outbox.sinks) WHERE value = $2)`,
+		id, sink,
+	)
+
+	return err
+}
+
+func (s Storage) RetryOutboxEvent(ctx context.Context, id string, at time.Time, lastError string) error {
+	_, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE outbox SET due_at = $2, last_error = $3, attempts = attempts + 1 WHERE id = $1`,
+		id, timestamp(at), lastError,
+	)
+
+	return err
+}
+
+func (s Storage) DeleteOutboxEvent(ctx context.Context, id string) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM outbox WHERE id = $1`, id)
+
+	return err
+}
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+func (s Storage) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO revoked_tokens (id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET expires_at = EXCLUDED.expires_at`,
+		id, timestamp(time.Now().Add(ttl)),
+	)
+
+	return err
+}
+
+func (s Storage) IsRevoked(ctx context.Context, id string) (bool, error) {
+	var revoked bool
+
+	err := s.conn(ctx).QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE id = $1 AND expires_at > $2)`, id, timestamp(time.Now()),
+	).Scan(&revoked)
+
+	return revoked, err
+}
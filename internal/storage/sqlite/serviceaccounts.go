@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"strconv"
+)
+
+var errServiceAccountNotFound = fmt.Errorf("service account %w", storage.ErrNotFound)
+
+func scanServiceAccount(row rowScanner) (storage.ServiceAccount, error) {
+	var (
+		account storage.ServiceAccount
+		id      int64
+		roles   string
+	)
+
+	if err := row.Scan(&id, &account.Name, &account.Description, &roles, &account.CreatedAt); err != nil {
+		return storage.ServiceAccount{}, err
+	}
+
+	account.ID = strconv.FormatInt(id, 10)
+	account.CreatedAt = account.CreatedAt.UTC()
+
+	var err error
+
+	if account.Roles, err = scanJSONArray(roles); err != nil {
+		return storage.ServiceAccount{}, err
+	}
+
+	return account, nil
+}
+
+func (s Storage) CreateServiceAccount(ctx context.Context, account storage.ServiceAccount) (string, error) {
+	roles, err := jsonArray(account.Roles)
+	if err != nil {
+		return "", err
+	}
+
+	var id int64
+
+	err = s.conn(ctx).QueryRowContext(ctx,
+		`INSERT INTO service_accounts (name, description, roles, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		account.Name, account.Description, roles, timestamp(account.CreatedAt),
+	).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (s Storage) ServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT id, name, description, roles, created_at FROM service_accounts ORDER BY created_at DESC, id DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]storage.ServiceAccount, 0)
+
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (s Storage) ServiceAccountByID(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	accountID, ok := parseID(id)
+	if !ok {
+		return storage.ServiceAccount{}, errServiceAccountNotFound
+	}
+
+	account, err := scanServiceAccount(s.conn(ctx).QueryRowContext(ctx,
+		`SELECT id, name, description, roles, created_at FROM service_accounts WHERE id = $1`, accountID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ServiceAccount{}, errServiceAccountNotFound
+		}
+
+		return storage.ServiceAccount{}, err
+	}
+
+	return account, nil
+}
+
+func (s Storage) DeleteServiceAccount(ctx context.Context, id string) error {
+	accountID, ok := parseID(id)
+	if !ok {
+		return errServiceAccountNotFound
+	}
+
+	return s.Transaction(ctx, func(ctx context.Context) error {
+		res, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM service_accounts WHERE id = $1`, accountID)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return errServiceAccountNotFound
+		}
+
+		_, err = s.conn(ctx).ExecContext(ctx, `DELETE FROM api_keys WHERE owner_id = $1`, id)
+
+		return err
+	})
+}
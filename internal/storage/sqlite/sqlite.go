@@ -0,0 +1,223 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// params of every connection: cascades need foreign keys, the immediate transactions wait for the write lock
+// of other processes for the busy timeout, times are written in the sqlite format so that they sort as text
+const params = `_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite&_txlock=immediate`
+
+type Storage struct {
+	db *sql.DB
+}
+
+// New opens the database file, creating it if there is none, and migrates the schema
+func New(ctx context.Context, path string) (Storage, error) {
+	const op = "storage.sqlite.New"
+
+	db, err := sql.Open("sqlite", path+"?"+params)
+	if err != nil {
+		return Storage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// sqlite has a single writer, one connection queues the writes instead of failing them with busy errors
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+
+		return Storage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := Storage{db: db}
+
+	if err := s.migrate(ctx); err != nil {
+		_ = db.Close()
+
+		return Storage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+func (s Storage) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the migrations newer than the schema version, each in its own transaction
+func (s Storage) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		// the files are named <version>_<name>.sql
+		name := strings.TrimPrefix(file, "migrations/")
+
+		prefix, _, _ := strings.Cut(name, "_")
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+
+		query, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		// the transaction holds the write lock, so processes opening the file together apply every migration once
+		if err := s.Transaction(ctx, func(ctx context.Context) error {
+			var applied bool
+
+			err := s.conn(ctx).QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version,
+			).Scan(&applied)
+			if err != nil || applied {
+				return err
+			}
+
+			if _, err := s.conn(ctx).ExecContext(ctx, string(query)); err != nil {
+				return err
+			}
+
+			_, err = s.conn(ctx).ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, version, time.Now().UTC(),
+			)
+
+			return err
+		}); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+type txKey struct{}
+
+// querier is the connection or the transaction of ctx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s Storage) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// Transaction runs fn in a transaction, a nested call is a part of the outer transaction.
+// The transaction holds the only connection, so fn must make its storage calls with the ctx it gets
+func (s Storage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SupportsTransactions reports whether the writes of Transaction are atomic
+func (s Storage) SupportsTransactions() bool {
+	return true
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+// parseID turns the id of a row into the integer primary key, a malformed id matches no row
+func parseID(id string) (int64, bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+
+	return n, err == nil
+}
+
+// timestamp keeps times in utc, so that the text of the sqlite format sorts in time order
+func timestamp(t time.Time) time.Time {
+	return t.UTC()
+}
+
+// nullTimestamp is timestamp for the optional times, nil is stored as null
+func nullTimestamp(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return timestamp(*t)
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+
+	return &utc
+}
+
+// jsonArray keeps string lists in json text columns, nil is stored as an empty list
+func jsonArray(values []string) (string, error) {
+	if values == nil {
+		values = []string{}
+	}
+
+	b, err := json.Marshal(values)
+
+	return string(b), err
+}
+
+func scanJSONArray(s string) ([]string, error) {
+	var values []string
+
+	if err := json.Unmarshal([]byte(s), &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
@@ -0,0 +1,244 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/storagetest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStorage opens a database in a temporary file
+func testStorage(t *testing.T) Storage {
+	t.Helper()
+
+	s, err := New(context.Background(), filepath.Join(t.TempDir(), "gas.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return testStorage(t)
+	})
+}
+
+func TestOutbox(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	first := events.New(constant.EventUserCreated, nil)
+	second := events.New(constant.EventUserDeleted, nil)
+	first.CreatedAt = second.CreatedAt.Add(-time.Second)
+
+	for _, event := range []events.Event{first, second} {
+		if err := s.AddOutboxEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := s.ClaimOutboxEvents(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 2 || claimed[0].Event.ID != first.ID || claimed[1].Event.ID != second.ID {
+		t.Fatalf("Expected both events the oldest first, got %v", claimed)
+	}
+
+	if claimed, err := s.ClaimOutboxEvents(ctx, 10, time.Minute); err != nil || len(claimed) != 0 {
+		t.Errorf("Expected the leased events to be hidden, got %d", len(claimed))
+	}
+
+	if err := s.CompleteOutboxSink(ctx, first.ID, constant.SinkWebhooks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RetryOutboxEvent(ctx, first.ID, time.Now().Add(-time.Second), "broker is down"); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err = s.ClaimOutboxEvents(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 1 || claimed[0].Attempts != 1 || len(claimed[0].Sinks) != 1 {
+		t.Errorf("Expected the retried event with 1 attempt and 1 sink, got %v", claimed)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	last, err := s.LastAuditEntry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last.Seq != 0 {
+		t.Errorf("Expected the zero entry, got %d", last.Seq)
+	}
+
+	for seq := int64(1); seq <= 3; seq++ {
+		if err := s.AddAuditEntry(ctx, storage.AuditEntry{Seq: seq, Time: time.Now(), Action: constant.AuditSignIn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.AddAuditEntry(ctx, storage.AuditEntry{Seq: 3}); !errors.Is(err, storage.ErrAuditSeqTaken) {
+		t.Errorf("Expected %v, got %v", storage.ErrAuditSeqTaken, err)
+	}
+
+	entries, err := s.AuditEntries(ctx, storage.AuditFilter{BeforeSeq: 3, Descending: true, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Seq != 2 {
+		t.Errorf("Expected entry 2, got %v", entries)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	id, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linkedAt := time.Now().UTC()
+
+	if err := s.AddIdentity(ctx, id, storage.Identity{Provider: "github", Subject: "42", LinkedAt: linkedAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	credential := storage.WebAuthnCredential{ID: []byte{1, 2}, PublicKey: []byte{3}, Transports: []string{"usb"}, CreatedAt: linkedAt}
+
+	if err := s.AddWebAuthnCredential(ctx, id, credential); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateWebAuthnSignCount(ctx, id, credential.ID, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddIdentity(ctx, "404", storage.Identity{Provider: "github", Subject: "43"}); err == nil {
+		t.Errorf("Expected an error for a missing user, got nil")
+	}
+
+	user, err := s.UserByIdentity(ctx, "github", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != id || len(user.Identities) != 1 || !user.Identities[0].LinkedAt.Equal(linkedAt) {
+		t.Errorf("Expected user %s with the identity linked at %v, got %v", id, linkedAt, user)
+	}
+
+	if len(user.WebAuthnCredentials) != 1 || user.WebAuthnCredentials[0].SignCount != 7 {
+		t.Errorf("Expected a passkey with sign count 7, got %v", user.WebAuthnCredentials)
+	}
+
+	expiresAt := linkedAt.Add(time.Hour)
+
+	if err := s.CreateAPIKey(ctx, storage.APIKey{ID: "key", OwnerID: id, CreatedAt: linkedAt, ExpiresAt: &expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := s.APIKeyByID(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.ExpiresAt == nil || !key.ExpiresAt.Equal(expiresAt) || key.RevokedAt != nil {
+		t.Errorf("Expected the key to expire at %v, got %v", expiresAt, key.ExpiresAt)
+	}
+
+	if err := s.DeleteUser(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UserByIdentity(ctx, "github", "42"); err == nil {
+		t.Errorf("Expected the identity to be removed with the user")
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		attempts, err := s.AddFailedAttempt(ctx, "rupychman@mail.ru", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attempts.Failures != i {
+			t.Errorf("Expected %d failures, got %d", i, attempts.Failures)
+		}
+	}
+
+	until := time.Now().Add(time.Hour).UTC()
+
+	if err := s.LockAttempts(ctx, "rupychman@mail.ru", until); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := s.FailedAttempts(ctx, "rupychman@mail.ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts.Failures != 3 || !attempts.LockedUntil.Equal(until) {
+		t.Errorf("Expected 3 failures locked until %v, got %v", until, attempts)
+	}
+
+	if err := s.SaveChallenge(ctx, "challenge", []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := s.TakeChallenge(ctx, "challenge"); err != nil || string(data) != "data" {
+		t.Errorf("Expected data, got %s %v", data, err)
+	}
+
+	if _, err := s.TakeChallenge(ctx, "challenge"); err == nil {
+		t.Errorf("Expected the challenge to be taken once")
+	}
+
+	if err := s.SaveChallengeOnce(ctx, "once", []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveChallengeOnce(ctx, "once", []byte("data"), time.Minute); !errors.Is(err, storage.ErrChallengeExists) {
+		t.Errorf("Expected %v, got %v", storage.ErrChallengeExists, err)
+	}
+
+	if err := s.SaveChallenge(ctx, "expired", []byte("data"), -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveChallengeOnce(ctx, "expired", []byte("data"), time.Minute); err != nil {
+		t.Errorf("Expected the expired challenge to be replaced, got %v", err)
+	}
+
+	if err := s.Revoke(ctx, "token", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := s.IsRevoked(ctx, "token"); err != nil || !revoked {
+		t.Errorf("Expected the token to be revoked, got %v %v", revoked, err)
+	}
+}
@@ -0,0 +1,372 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errUserNotFound = fmt.Errorf("user %w", storage.ErrNotFound)
+	errEmailTaken   = storage.ErrEmailTaken
+)
+
+const userColumns = `id, email, password, user_info, roles, verified, locked, password_reset_required, mfa_enabled,
+	mfa_method, totp_secret, totp_pending_secret, totp_last_step, recovery_codes, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (storage.User, error) {
+	var (
+		user                 storage.User
+		id                   int64
+		userInfo             sql.NullString
+		roles, recoveryCodes string
+	)
+
+	err := row.Scan(
+		&id,
+		&user.Email,
+		&user.PasswordHash,
+		&userInfo,
+		&roles,
+		&user.Verified,
+		&user.Locked,
+		&user.PasswordResetRequired,
+		&user.MFAEnabled,
+		&user.MFAMethod,
+		&user.TOTPSecret,
+		&user.PendingTOTPSecret,
+		&user.TOTPLastStep,
+		&recoveryCodes,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	user.ID = strconv.FormatInt(id, 10)
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	if userInfo.Valid {
+		if err := json.Unmarshal([]byte(userInfo.String), &user.UserInfo); err != nil {
+			return storage.User{}, err
+		}
+	}
+
+	if user.Roles, err = scanJSONArray(roles); err != nil {
+		return storage.User{}, err
+	}
+
+	if user.RecoveryCodeHashes, err = scanJSONArray(recoveryCodes); err != nil {
+		return storage.User{}, err
+	}
+
+	return user, nil
+}
+
+// withCredentials loads the passkeys and the linked identities of the user,
+// the queries of an embedded database are cheap enough to make them per user
+func (s Storage) withCredentials(ctx context.Context, user *storage.User) error {
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT id, public_key, attestation_type, transports, aaguid, sign_count, created_at
+		FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	user.WebAuthnCredentials = make([]storage.WebAuthnCredential, 0)
+
+	for rows.Next() {
+		var (
+			c          storage.WebAuthnCredential
+			transports string
+		)
+
+		if err := rows.Scan(&c.ID, &c.PublicKey, &c.AttestationType, &transports, &c.AAGUID, &c.SignCount, &c.CreatedAt); err != nil {
+			return err
+		}
+
+		if c.Transports, err = scanJSONArray(transports); err != nil {
+			return err
+		}
+
+		c.CreatedAt = c.CreatedAt.UTC()
+
+		user.WebAuthnCredentials = append(user.WebAuthnCredentials, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	identities, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT provider, subject, email, linked_at FROM identities WHERE user_id = $1 ORDER BY linked_at`, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer identities.Close()
+
+	user.Identities = make([]storage.Identity, 0)
+
+	for identities.Next() {
+		var identity storage.Identity
+
+		if err := identities.Scan(&identity.Provider, &identity.Subject, &identity.Email, &identity.LinkedAt); err != nil {
+			return err
+		}
+
+		identity.LinkedAt = identity.LinkedAt.UTC()
+
+		user.Identities = append(user.Identities, identity)
+	}
+
+	return identities.Err()
+}
+
+// user returns the user matching the condition on the users table
+func (s Storage) user(ctx context.Context, where string, args ...interface{}) (storage.User, error) {
+	user, err := scanUser(s.conn(ctx).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+where, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.User{}, errUserNotFound
+		}
+
+		return storage.User{}, err
+	}
+
+	if err := s.withCredentials(ctx, &user); err != nil {
+		return storage.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s Storage) UserByEmail(ctx context.Context, email string) (storage.User, error) {
+	return s.user(ctx, `lower(email) = lower($1)`, email)
+}
+
+func (s Storage) DoesEmailExist(ctx context.Context, email string) error {
+	var exists bool
+
+	err := s.conn(ctx).QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))`, email,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errEmailTaken
+	}
+
+	return nil
+}
+
+func (s Storage) CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error) {
+	info, err := json.Marshal(userInfo)
+	if err != nil {
+		return "0", err
+	}
+
+	var id int64
+
+	err = s.conn(ctx).QueryRowContext(ctx,
+		`INSERT INTO users (email, password, user_info, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		email, password, string(info), timestamp(time.Now()),
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return "0", errEmailTaken
+		}
+
+		return "0", err
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (s Storage) Users(ctx context.Context, filter storage.UserFilter) ([]storage.User, int64, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EmailPrefix != "" {
+		where(`lower(email) LIKE $%d ESCAPE '\'`, likePrefix(strings.ToLower(filter.EmailPrefix)))
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		where(`created_at >= $%d`, timestamp(filter.CreatedAfter))
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		where(`created_at < $%d`, timestamp(filter.CreatedBefore))
+	}
+
+	if filter.Verified != nil {
+		where(`verified = $%d`, *filter.Verified)
+	}
+
+	if filter.Locked != nil {
+		where(`locked = $%d`, *filter.Locked)
+	}
+
+	query := ``
+	if len(conditions) > 0 {
+		query = ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	var total int64
+
+	if err := s.conn(ctx).QueryRowContext(ctx, `SELECT count(*) FROM users`+query, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// a zero limit is no limit, a negative limit returns all the rows
+	rowsLimit := int64(-1)
+	if filter.Limit > 0 {
+		rowsLimit = filter.Limit
+	}
+
+	args = append(args, rowsLimit, filter.Offset)
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		fmt.Sprintf(`SELECT %s FROM users%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+			userColumns, query, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := make([]storage.User, 0)
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			_ = rows.Close()
+
+			return nil, 0, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// the rows hold the only connection until they are closed
+	_ = rows.Close()
+
+	for i := range users {
+		if err := s.withCredentials(ctx, &users[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return users, total, nil
+}
+
+func (s Storage) UserByID(ctx context.Context, id string) (storage.User, error) {
+	userID, ok := parseID(id)
+	if !ok {
+		return storage.User{}, errUserNotFound
+	}
+
+	return s.user(ctx, `id = $1`, userID)
+}
+
+func (s Storage) UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error {
+	info, err := json.Marshal(userInfo)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(ctx, id, `user_info = $2`, string(info))
+}
+
+func (s Storage) SetLocked(ctx context.Context, id string, locked bool) error {
+	return s.updateUser(ctx, id, `locked = $2`, locked)
+}
+
+func (s Storage) SetRoles(ctx context.Context, id string, roles []string) error {
+	value, err := jsonArray(roles)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(ctx, id, `roles = $2`, value)
+}
+
+func (s Storage) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	return s.updateUser(ctx, id, `password_reset_required = $2`, required)
+}
+
+func (s Storage) SetPassword(ctx context.Context, id, passwordHash string) error {
+	return s.updateUser(ctx, id, `password = $2, password_reset_required = false`, passwordHash)
+}
+
+func (s Storage) DeleteUser(ctx context.Context, id string) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	res, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return userAffected(res)
+}
+
+// updateUser sets the columns of the user, the id is the first argument of the set clause
+func (s Storage) updateUser(ctx context.Context, id, set string, args ...interface{}) error {
+	userID, ok := parseID(id)
+	if !ok {
+		return errUserNotFound
+	}
+
+	res, err := s.conn(ctx).ExecContext(ctx, `UPDATE users SET `+set+` WHERE id = $1`, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	return userAffected(res)
+}
+
+func userAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errUserNotFound
+	}
+
+	return nil
+}
+
+// likePrefix escapes the wildcards of the prefix for a LIKE pattern with the backslash escape
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + `%`
+}
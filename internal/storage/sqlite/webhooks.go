@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/storage"
+	"strconv"
+	"time"
+)
+
+var errWebhookNotFound = fmt.Errorf("webhook %w", storage.ErrNotFound)
+
+func scanWebhook(row rowScanner) (storage.Webhook, error) {
+	var (
+		webhook storage.Webhook
+		id      int64
+		events  string
+	)
+
+	if err := row.Scan(&id, &webhook.URL, &webhook.Description, &events, &webhook.Secret, &webhook.CreatedAt); err != nil {
+		return storage.Webhook{}, err
+	}
+
+	webhook.ID = strconv.FormatInt(id, 10)
+	webhook.CreatedAt = webhook.CreatedAt.UTC()
+
+	var err error
+
+	if webhook.Events, err = scanJSONArray(events); err != nil {
+		return storage.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (s Storage) CreateWebhook(ctx context.Context, webhook storage.Webhook) (string, error) {
+	events, err := jsonArray(webhook.Events)
+	if err != nil {
+		return "", err
+	}
+
+	var id int64
+
+	err = s.conn(ctx).QueryRowContext(ctx,
+		`INSERT INTO webhooks (url, description, events, secret, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		webhook.URL, webhook.Description, events, webhook.Secret, timestamp(webhook.CreatedAt),
+	).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (s Storage) Webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT id, url, description, events, secret, created_at FROM webhooks ORDER BY created_at DESC, id DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]storage.Webhook, 0)
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s Storage) WebhookByID(ctx context.Context, id string) (storage.Webhook, error) {
+	webhookID, ok := parseID(id)
+	if !ok {
+		return storage.Webhook{}, errWebhookNotFound
+	}
+
+	webhook, err := scanWebhook(s.conn(ctx).QueryRowContext(ctx,
+		`SELECT id, url, description, events, secret, created_at FROM webhooks WHERE id = $1`, webhookID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Webhook{}, errWebhookNotFound
+		}
+
+		return storage.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (s Storage) DeleteWebhook(ctx context.Context, id string) error {
+	webhookID, ok := parseID(id)
+	if !ok {
+		return errWebhookNotFound
+	}
+
+	return s.Transaction(ctx, func(ctx context.Context) error {
+		res, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookID)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return errWebhookNotFound
+		}
+
+		_, err = s.conn(ctx).ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = $1`, id)
+
+		return err
+	})
+}
+
+func (s Storage) AddWebhookDelivery(ctx context.Context, delivery storage.WebhookDelivery) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, attempt, status_code, error, succeeded, duration, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.EventType,
+		delivery.Attempt,
+		delivery.StatusCode,
+		delivery.Error,
+		delivery.Succeeded,
+		int64(delivery.Duration),
+		timestamp(delivery.CreatedAt),
+	)
+
+	return err
+}
+
+func (s Storage) WebhookDeliveries(ctx context.Context, webhookID string, limit int64) ([]storage.WebhookDelivery, error) {
+	// a zero limit is no limit, a negative limit returns all the rows
+	rowsLimit := int64(-1)
+	if limit > 0 {
+		rowsLimit = limit
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event_type, attempt, status_code, error, succeeded, duration, created_at
+		FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		webhookID, rowsLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]storage.WebhookDelivery, 0)
+
+	for rows.Next() {
+		var (
+			d        storage.WebhookDelivery
+			id       int64
+			duration int64
+		)
+
+		err := rows.Scan(
+			&id,
+			&d.WebhookID,
+			&d.EventID,
+			&d.EventType,
+			&d.Attempt,
+			&d.StatusCode,
+			&d.Error,
+			&d.Succeeded,
+			&duration,
+			&d.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		d.ID = strconv.FormatInt(id, 10)
+		d.Duration = time.Duration(duration)
+		d.CreatedAt = d.CreatedAt.UTC()
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
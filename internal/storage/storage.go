@@ -1,7 +1,304 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/lib/events"
+	"time"
+)
+
+var (
+	// ErrNotFound means the user, account, key or webhook doesn't exist, the errors of the backends wrap it
+	ErrNotFound = errors.New("not found")
+	// ErrEmailTaken means another user already has the email
+	ErrEmailTaken = errors.New("email is already in use")
+	// ErrAuditSeqTaken means another entry was appended after the last entry the caller saw
+	ErrAuditSeqTaken = errors.New("audit sequence number is taken")
+	// ErrTOTPStepUsed means a totp code of the same or a later time step has already been accepted
+	ErrTOTPStepUsed = errors.New("totp code is already used")
+	// ErrSignCountUsed means the passkey sign count is not greater than the saved one, the passkey may be cloned
+	ErrSignCountUsed = errors.New("passkey sign count is already used")
+	// ErrChallengeExists means a challenge with the id is saved and has not expired yet
+	ErrChallengeExists = errors.New("challenge already exists")
+)
+
 type Storage interface {
-	DoesEmailExist(email string) error
-	CreateUser(email, password string) (string, error)
-	UserByEmail(email string) (interface{}, error)
+	// DoesEmailExist returns ErrEmailTaken if a user has the email
+	DoesEmailExist(ctx context.Context, email string) error
+	CreateUser(ctx context.Context, email, password string, userInfo interface{}) (string, error)
+	UserByEmail(ctx context.Context, email string) (User, error)
+
+	Users(ctx context.Context, filter UserFilter) ([]User, int64, error)
+	UserByID(ctx context.Context, id string) (User, error)
+	UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) error
+	SetLocked(ctx context.Context, id string, locked bool) error
+	SetRoles(ctx context.Context, id string, roles []string) error
+	SetPasswordResetRequired(ctx context.Context, id string, required bool) error
+	// SetPassword replaces the password hash and clears the password reset requirement
+	SetPassword(ctx context.Context, id, passwordHash string) error
+	DeleteUser(ctx context.Context, id string) error
+
+	SetPendingTOTPSecret(ctx context.Context, id, secret string) error
+	// EnableTOTP turns two-factor authentication on with the secret and hashes of the recovery codes,
+	// totp becomes the second factor
+	EnableTOTP(ctx context.Context, id, secret string, recoveryCodeHashes []string) error
+	// UseRecoveryCode removes the recovery code hash so that the code can't be used twice
+	UseRecoveryCode(ctx context.Context, id, recoveryCodeHash string) error
+	// UseTOTPStep saves the time step of the accepted totp code and returns ErrTOTPStepUsed unless it is later
+	// than the saved one, so that a code can't be replayed within the skew
+	UseTOTPStep(ctx context.Context, id string, step int64) error
+	// SetMFAMethod enables two-factor authentication with the second factor, the empty method turns it off
+	SetMFAMethod(ctx context.Context, id, method string) error
+
+	AddWebAuthnCredential(ctx context.Context, id string, credential WebAuthnCredential) error
+	// UpdateWebAuthnSignCount saves the sign count of the passkey and returns ErrSignCountUsed unless it is greater
+	// than the saved one, so that two logins with the same count can't both pass
+	UpdateWebAuthnSignCount(ctx context.Context, id string, credentialID []byte, signCount uint32) error
+
+	// UserByIdentity finds the user linked to the account of the external identity provider
+	UserByIdentity(ctx context.Context, provider, subject string) (User, error)
+	AddIdentity(ctx context.Context, id string, identity Identity) error
+
+	CreateServiceAccount(ctx context.Context, account ServiceAccount) (string, error)
+	ServiceAccounts(ctx context.Context) ([]ServiceAccount, error)
+	ServiceAccountByID(ctx context.Context, id string) (ServiceAccount, error)
+	// DeleteServiceAccount removes the account with its api keys
+	DeleteServiceAccount(ctx context.Context, id string) error
+
+	CreateAPIKey(ctx context.Context, key APIKey) error
+	APIKeyByID(ctx context.Context, id string) (APIKey, error)
+	APIKeys(ctx context.Context, ownerID string) ([]APIKey, error)
+	// TouchAPIKey sets the last used time of the key
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+	// RevokeAPIKey revokes the key only if it belongs to the owner
+	RevokeAPIKey(ctx context.Context, ownerID, id string, revokedAt time.Time) error
+
+	CreateWebhook(ctx context.Context, webhook Webhook) (string, error)
+	Webhooks(ctx context.Context) ([]Webhook, error)
+	WebhookByID(ctx context.Context, id string) (Webhook, error)
+	// DeleteWebhook removes the webhook with its delivery log
+	DeleteWebhook(ctx context.Context, id string) error
+	AddWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+	// WebhookDeliveries returns the last deliveries of the webhook, the newest first
+	WebhookDeliveries(ctx context.Context, webhookID string, limit int64) ([]WebhookDelivery, error)
+}
+
+type User struct {
+	ID                    string               `json:"id"`
+	Email                 string               `json:"email"`
+	PasswordHash          string               `json:"-"`
+	UserInfo              interface{}          `json:"user_info,omitempty"`
+	Roles                 []string             `json:"roles,omitempty"`
+	Verified              bool                 `json:"verified"`
+	Locked                bool                 `json:"locked"`
+	PasswordResetRequired bool                 `json:"password_reset_required"`
+	MFAEnabled            bool                 `json:"mfa_enabled"`
+	MFAMethod             string               `json:"mfa_method,omitempty"`
+	TOTPSecret            string               `json:"-"`
+	PendingTOTPSecret     string               `json:"-"`
+	TOTPLastStep          int64                `json:"-"`
+	RecoveryCodeHashes    []string             `json:"-"`
+	WebAuthnCredentials   []WebAuthnCredential `json:"-"`
+	Identities            []Identity           `json:"identities,omitempty"`
+	CreatedAt             time.Time            `json:"created_at"`
+}
+
+type WebAuthnCredential struct {
+	ID              []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	CreatedAt       time.Time
+}
+
+// Identity is an account of the user at an external identity provider
+type Identity struct {
+	Provider string    `json:"provider"`
+	Subject  string    `json:"subject"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// ServiceAccount is a non-human principal that signs in only with api keys
+type ServiceAccount struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// APIKey is a long-lived credential of a user or a service account, only the hash of the key is kept
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"`
+	OwnerType  string     `json:"owner_type"`
+	Scopes     []string   `json:"scopes"`
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Webhook is an endpoint events are posted to, the payloads are signed with the secret
+type Webhook struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	// Events are the subscribed event types, * subscribes to all of them
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is an attempt to post an event to a webhook
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	Attempt   int    `json:"attempt"`
+	// StatusCode is zero when the endpoint did not answer
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Succeeded  bool          `json:"succeeded"`
+	Duration   time.Duration `json:"duration"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// UserFilter narrows down the users list, zero values are not applied
+type UserFilter struct {
+	EmailPrefix   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Verified      *bool
+	Locked        *bool
+	Offset        int64
+	Limit         int64
+}
+
+// AttemptsStorage keeps failed sign in counters by key, e.g. account email or client ip
+type AttemptsStorage interface {
+	FailedAttempts(ctx context.Context, key string) (Attempts, error)
+	// AddFailedAttempt increments the counter, the counter is reset when the window passes without failures
+	AddFailedAttempt(ctx context.Context, key string, window time.Duration) (Attempts, error)
+	LockAttempts(ctx context.Context, key string, until time.Time) error
+	ResetAttempts(ctx context.Context, key string) error
+}
+
+type Attempts struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+// RateLimitStorage keeps token buckets by key
+type RateLimitStorage interface {
+	// Take refills the bucket and takes a token from it if there is one
+	Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error)
+}
+
+// Rate allows Limit requests per Period, Limit is also the bucket size
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is the time until the next token is available
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// RevocationStorage keeps the ids of revoked tokens until the tokens expire on their own
+type RevocationStorage interface {
+	Revoke(ctx context.Context, id string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// ChallengeStorage keeps the state of multistep ceremonies, e.g. webauthn sessions, every challenge can be taken once
+type ChallengeStorage interface {
+	SaveChallenge(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	// SaveChallengeOnce saves the challenge unless one with the id has not expired yet, then it returns
+	// ErrChallengeExists, e.g. to remember the ids of the used assertions
+	SaveChallengeOnce(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	// TakeChallenge returns the data and removes it
+	TakeChallenge(ctx context.Context, id string) ([]byte, error)
+}
+
+// Transactor runs fn in a transaction, the storage calls made with the ctx passed to fn are a part of it
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OutboxStorage keeps published events until the relay hands them to every sink
+type OutboxStorage interface {
+	// AddOutboxEvent writes the event in the transaction of ctx if there is one
+	AddOutboxEvent(ctx context.Context, event events.Event) error
+	// ClaimOutboxEvents returns the oldest due events and hides them from other relays for the lease
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error)
+	// CompleteOutboxSink records that the sink has the event so that it is not sent there again
+	CompleteOutboxSink(ctx context.Context, id, sink string) error
+	// RetryOutboxEvent counts the failed attempt and makes the event due again at the time
+	RetryOutboxEvent(ctx context.Context, id string, at time.Time, lastError string) error
+	// DeleteOutboxEvent removes the event all the sinks have
+	DeleteOutboxEvent(ctx context.Context, id string) error
+}
+
+type OutboxEvent struct {
+	Event events.Event
+	// Attempts is the number of failed relay attempts
+	Attempts int
+	// Sinks are the sinks that already have the event
+	Sinks []string
+}
+
+// AuditStorage keeps the append-only audit log ordered by the sequence numbers
+type AuditStorage interface {
+	// LastAuditEntry returns the zero entry when the log is empty
+	LastAuditEntry(ctx context.Context) (AuditEntry, error)
+	// AddAuditEntry fails with ErrAuditSeqTaken when there is an entry with the sequence number
+	AddAuditEntry(ctx context.Context, entry AuditEntry) error
+	AuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// AuditEntry records an action, the hash covers the entry and the hash of the previous one
+// so that a changed or removed entry breaks the chain
+type AuditEntry struct {
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+	// ActorID is the user or the service account that did the action, it is empty for anonymous requests
+	ActorID       string `json:"actor_id,omitempty"`
+	ActorAPIKeyID string `json:"actor_api_key_id,omitempty"`
+	// Target is the id or the email the action is about
+	Target    string `json:"target,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	PrevHash  string `json:"prev_hash"`
+	Hash      string `json:"hash"`
+}
+
+// AuditFilter narrows down the audit log, zero values are not applied
+type AuditFilter struct {
+	Action  string
+	ActorID string
+	Target  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	// AfterSeq and BeforeSeq are the cursors of the ascending and the descending pages
+	AfterSeq   int64
+	BeforeSeq  int64
+	Descending bool
+	Limit      int64
 }
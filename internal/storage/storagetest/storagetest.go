@@ -0,0 +1,621 @@
+// Package storagetest is the conformance suite of the storage backends, every backend runs it in its tests
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run checks the semantics every storage.Storage must have, open returns an empty storage for each subtest
+func Run(t *testing.T, open func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"DuplicateEmail", testDuplicateEmail},
+		{"CaseInsensitiveEmail", testCaseInsensitiveEmail},
+		{"NotFound", testNotFound},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Users", testUsers},
+		{"RecoveryCode", testRecoveryCode},
+		{"TOTPStep", testTOTPStep},
+		{"Credentials", testCredentials},
+		{"APIKeys", testAPIKeys},
+		{"ServiceAccounts", testServiceAccounts},
+		{"Webhooks", testWebhooks},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s storage.Storage, email string) string {
+	t.Helper()
+
+	id, err := s.CreateUser(context.Background(), email, "hash", map[string]interface{}{"name": email})
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", email, err)
+	}
+
+	return id
+}
+
+func testDuplicateEmail(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	createUser(t, s, "rupychman@mail.ru")
+
+	if err := s.DoesEmailExist(ctx, "rupychman@mail.ru"); !errors.Is(err, storage.ErrEmailTaken) {
+		t.Errorf("Expected %v, got %v", storage.ErrEmailTaken, err)
+	}
+
+	if _, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", nil); !errors.Is(err, storage.ErrEmailTaken) {
+		t.Errorf("Expected %v, got %v", storage.ErrEmailTaken, err)
+	}
+
+	if err := s.DoesEmailExist(ctx, "degeboman@mail.ru"); err != nil {
+		t.Errorf("Expected a free email, got %v", err)
+	}
+}
+
+func testCaseInsensitiveEmail(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	createUser(t, s, "rupychman@mail.ru")
+
+	data := []string{"Rupychman@mail.ru", "RUPYCHMAN@MAIL.RU"}
+
+	for _, email := range data {
+		if err := s.DoesEmailExist(ctx, email); err == nil {
+			t.Errorf("Expected %s to be taken", email)
+		}
+
+		if _, err := s.UserByEmail(ctx, email); err != nil {
+			t.Errorf("Expected %s to be found, got %v", email, err)
+		}
+
+		if _, err := s.CreateUser(ctx, email, "hash", nil); err == nil {
+			t.Errorf("Expected %s to be rejected", email)
+		}
+	}
+}
+
+func testNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	// a deleted user has a well-formed id of the backend, the malformed one must not match anything either
+	deleted := createUser(t, s, "rupychman@mail.ru")
+	if err := s.DeleteUser(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{deleted, "missing"} {
+		calls := []struct {
+			name string
+			call func() error
+		}{
+			{"UserByID", func() error { _, err := s.UserByID(ctx, id); return err }},
+			{"UpdateUserInfo", func() error { return s.UpdateUserInfo(ctx, id, nil) }},
+			{"SetLocked", func() error { return s.SetLocked(ctx, id, true) }},
+			{"SetRoles", func() error { return s.SetRoles(ctx, id, []string{constant.AdminRole}) }},
+			{"SetPasswordResetRequired", func() error { return s.SetPasswordResetRequired(ctx, id, true) }},
+			{"SetPassword", func() error { return s.SetPassword(ctx, id, "hash") }},
+			{"DeleteUser", func() error { return s.DeleteUser(ctx, id) }},
+			{"ServiceAccountByID", func() error { _, err := s.ServiceAccountByID(ctx, id); return err }},
+			{"DeleteServiceAccount", func() error { return s.DeleteServiceAccount(ctx, id) }},
+			{"APIKeyByID", func() error { _, err := s.APIKeyByID(ctx, id); return err }},
+			{"WebhookByID", func() error { _, err := s.WebhookByID(ctx, id); return err }},
+			{"DeleteWebhook", func() error { return s.DeleteWebhook(ctx, id) }},
+		}
+
+		for _, c := range calls {
+			if err := c.call(); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("%s(%q): expected %v, got %v", c.name, id, storage.ErrNotFound, err)
+			}
+		}
+	}
+
+	if _, err := s.UserByEmail(ctx, "rupychman@mail.ru"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("UserByEmail: expected %v, got %v", storage.ErrNotFound, err)
+	}
+
+	if _, err := s.UserByIdentity(ctx, "github", "42"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("UserByIdentity: expected %v, got %v", storage.ErrNotFound, err)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	const workers = 8
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			// the emails differ in case only, so they race for the same user
+			email := "rupychman@mail.ru"
+			if i%2 == 1 {
+				email = "Rupychman@mail.ru"
+			}
+
+			if _, err := s.CreateUser(ctx, email, "hash", nil); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("Expected 1 user to be created, got %d", created)
+	}
+}
+
+func testUpdate(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "rupychman@mail.ru")
+
+	if err := s.UpdateUserInfo(ctx, id, map[string]interface{}{"name": "Rupychman"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetLocked(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetRoles(ctx, id, []string{constant.AdminRole}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetPasswordResetRequired(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetMFAMethod(ctx, id, constant.MFAMethodWebAuthn); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != id || user.Email != "rupychman@mail.ru" || user.PasswordHash != "hash" {
+		t.Errorf("Expected user %s rupychman@mail.ru, got %s %s", id, user.ID, user.Email)
+	}
+
+	if info, ok := user.UserInfo.(map[string]interface{}); !ok || info["name"] != "Rupychman" {
+		t.Errorf("Expected the updated user info, got %v", user.UserInfo)
+	}
+
+	if !user.Locked || !user.PasswordResetRequired || !user.MFAEnabled || user.Verified {
+		t.Errorf("Expected locked, reset required and mfa enabled, got %+v", user)
+	}
+
+	if user.MFAMethod != constant.MFAMethodWebAuthn {
+		t.Errorf("Expected %v, got %v", constant.MFAMethodWebAuthn, user.MFAMethod)
+	}
+
+	if len(user.Roles) != 1 || user.Roles[0] != constant.AdminRole {
+		t.Errorf("Expected roles [%s], got %v", constant.AdminRole, user.Roles)
+	}
+
+	if time.Since(user.CreatedAt) > time.Minute {
+		t.Errorf("Expected the creation time to be set, got %v", user.CreatedAt)
+	}
+
+	// the changes are seen by the lookup the sign in uses
+	if _, err := s.UserByEmail(ctx, "rupychman@mail.ru"); err != nil {
+		t.Errorf("Expected the user to be found by email, got %v", err)
+	}
+
+	if err := s.SetPassword(ctx, id, "new hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err = s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.PasswordHash != "new hash" || user.PasswordResetRequired {
+		t.Errorf("Expected the new hash and no reset required, got %v %v", user.PasswordHash, user.PasswordResetRequired)
+	}
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "rupychman@mail.ru")
+	other := createUser(t, s, "degeboman@mail.ru")
+
+	if err := s.AddIdentity(ctx, id, storage.Identity{Provider: "github", Subject: "42", LinkedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteUser(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UserByID(ctx, id); err == nil {
+		t.Errorf("Expected the deleted user to be not found")
+	}
+
+	if _, err := s.UserByIdentity(ctx, "github", "42"); err == nil {
+		t.Errorf("Expected the identity to be deleted with the user")
+	}
+
+	if _, err := s.UserByID(ctx, other); err != nil {
+		t.Errorf("Expected the other user to stay, got %v", err)
+	}
+
+	// the email is free again
+	if _, err := s.CreateUser(ctx, "rupychman@mail.ru", "hash", nil); err != nil {
+		t.Errorf("Expected the email of the deleted user to be free, got %v", err)
+	}
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, createUser(t, s, fmt.Sprintf("user%d@mail.ru", i)))
+
+		// the users are listed the newest first, the pause keeps the creation times apart
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	createUser(t, s, "rupychman@mail.ru")
+
+	if err := s.SetLocked(ctx, ids[1], true); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := true
+
+	data := []struct {
+		name     string
+		filter   storage.UserFilter
+		expected []string
+		total    int64
+	}{
+		{"prefix", storage.UserFilter{EmailPrefix: "User"}, []string{ids[4], ids[3], ids[2], ids[1], ids[0]}, 5},
+		{"page", storage.UserFilter{EmailPrefix: "user", Offset: 1, Limit: 2}, []string{ids[3], ids[2]}, 5},
+		{"past the end", storage.UserFilter{EmailPrefix: "user", Offset: 10}, []string{}, 5},
+		{"locked", storage.UserFilter{Locked: &locked}, []string{ids[1]}, 1},
+		{"wildcard", storage.UserFilter{EmailPrefix: "user_"}, []string{}, 0},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users, total, err := s.Users(ctx, d.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if total != d.total {
+				t.Errorf("Expected total %d, got %d", d.total, total)
+			}
+
+			got := make([]string, 0, len(users))
+			for _, user := range users {
+				got = append(got, user.ID)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(d.expected) {
+				t.Errorf("Expected %v, got %v", d.expected, got)
+			}
+		})
+	}
+}
+
+func testRecoveryCode(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "rupychman@mail.ru")
+
+	if err := s.SetPendingTOTPSecret(ctx, id, "pending"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.EnableTOTP(ctx, id, "secret", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UseRecoveryCode(ctx, id, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UseRecoveryCode(ctx, id, "a"); err == nil {
+		t.Errorf("Expected the used recovery code to be rejected")
+	}
+
+	if err := s.UseRecoveryCode(ctx, id, "c"); err == nil {
+		t.Errorf("Expected an unknown recovery code to be rejected")
+	}
+
+	user, err := s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !user.MFAEnabled || user.MFAMethod != constant.MFAMethodTOTP || user.TOTPSecret != "secret" || user.PendingTOTPSecret != "" {
+		t.Errorf("Expected totp to be enabled with the secret")
+	}
+
+	if len(user.RecoveryCodeHashes) != 1 || user.RecoveryCodeHashes[0] != "b" {
+		t.Errorf("Expected recovery codes [b], got %v", user.RecoveryCodeHashes)
+	}
+}
+
+func testTOTPStep(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "rupychman@mail.ru")
+
+	if err := s.EnableTOTP(ctx, id, "secret", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		step     int64
+		expected error
+	}{
+		{100, nil},
+		{100, storage.ErrTOTPStepUsed},
+		{99, storage.ErrTOTPStepUsed},
+		{102, nil},
+	}
+
+	for _, d := range data {
+		if err := s.UseTOTPStep(ctx, id, d.step); !errors.Is(err, d.expected) {
+			t.Errorf("Step %d: expected %v, got %v", d.step, d.expected, err)
+		}
+	}
+
+	user, err := s.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.TOTPLastStep != 102 {
+		t.Errorf("Expected %v, got %v", 102, user.TOTPLastStep)
+	}
+
+	// a new secret starts over
+	if err := s.EnableTOTP(ctx, id, "another", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UseTOTPStep(ctx, id, 101); err != nil {
+		t.Errorf("Expected the step of the new secret to be accepted, got %v", err)
+	}
+}
+
+func testCredentials(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "rupychman@mail.ru")
+	other := createUser(t, s, "degeboman@mail.ru")
+
+	credential := storage.WebAuthnCredential{
+		ID:         []byte{1, 2, 3},
+		PublicKey:  []byte{4, 5},
+		Transports: []string{"usb"},
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if err := s.AddWebAuthnCredential(ctx, id, credential); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := []struct {
+		count    uint32
+		expected error
+	}{
+		{7, nil},
+		{7, storage.ErrSignCountUsed},
+		{5, storage.ErrSignCountUsed},
+	}
+
+	for _, c := range counts {
+		if err := s.UpdateWebAuthnSignCount(ctx, id, credential.ID, c.count); !errors.Is(err, c.expected) {
+			t.Errorf("Sign count %d: expected %v, got %v", c.count, c.expected, err)
+		}
+	}
+
+	identity := storage.Identity{Provider: "github", Subject: "42", Email: "rupychman@mail.ru", LinkedAt: time.Now().UTC()}
+
+	if err := s.AddIdentity(ctx, id, identity); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddIdentity(ctx, other, identity); err == nil {
+		t.Errorf("Expected the identity linked to another user to be rejected")
+	}
+
+	user, err := s.UserByIdentity(ctx, "github", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != id || len(user.Identities) != 1 || user.Identities[0].Email != identity.Email {
+		t.Errorf("Expected user %s with the identity, got %s %v", id, user.ID, user.Identities)
+	}
+
+	if len(user.WebAuthnCredentials) != 1 || user.WebAuthnCredentials[0].SignCount != 7 {
+		t.Errorf("Expected a passkey with sign count 7, got %v", user.WebAuthnCredentials)
+	}
+}
+
+func testAPIKeys(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+
+	keys := []storage.APIKey{
+		{ID: "first", Name: "laptop", OwnerID: "owner", OwnerType: constant.OwnerUser, Scopes: []string{"read"}, Hash: "1", CreatedAt: time.Now().UTC()},
+		{ID: "second", Name: "ci", OwnerID: "owner", OwnerType: constant.OwnerUser, Hash: "2", CreatedAt: time.Now().Add(time.Second).UTC(), ExpiresAt: &expiresAt},
+		{ID: "other", Name: "ci", OwnerID: "other", OwnerType: constant.OwnerUser, Hash: "3", CreatedAt: time.Now().UTC()},
+	}
+
+	for _, key := range keys {
+		if err := s.CreateAPIKey(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owned, err := s.APIKeys(ctx, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(owned) != 2 || owned[0].ID != "second" || owned[1].ID != "first" {
+		t.Errorf("Expected the keys of the owner the newest first, got %v", owned)
+	}
+
+	if err := s.RevokeAPIKey(ctx, "owner", "other", time.Now()); err == nil {
+		t.Errorf("Expected the key of another owner to stay")
+	}
+
+	if err := s.RevokeAPIKey(ctx, "owner", "first", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RevokeAPIKey(ctx, "owner", "first", time.Now()); err == nil {
+		t.Errorf("Expected the revoked key to be revoked once")
+	}
+
+	if err := s.TouchAPIKey(ctx, "second", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.APIKeyByID(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.RevokedAt == nil || first.Hash != "1" || len(first.Scopes) != 1 {
+		t.Errorf("Expected the revoked key with its hash and scopes, got %+v", first)
+	}
+
+	second, err := s.APIKeyByID(ctx, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.LastUsedAt == nil || second.ExpiresAt == nil || !second.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the used key to expire at %v, got %+v", expiresAt, second)
+	}
+}
+
+func testServiceAccounts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id, err := s.CreateServiceAccount(ctx, storage.ServiceAccount{Name: "ci", Roles: []string{"deploy"}, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := storage.APIKey{ID: "key", OwnerID: id, OwnerType: constant.OwnerServiceAccount, Hash: "1", CreatedAt: time.Now().UTC()}
+	if err := s.CreateAPIKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := s.ServiceAccountByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Name != "ci" || len(account.Roles) != 1 {
+		t.Errorf("Expected service account ci with a role, got %+v", account)
+	}
+
+	if accounts, err := s.ServiceAccounts(ctx); err != nil || len(accounts) != 1 {
+		t.Errorf("Expected 1 service account, got %d %v", len(accounts), err)
+	}
+
+	if err := s.DeleteServiceAccount(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.APIKeyByID(ctx, "key"); err == nil {
+		t.Errorf("Expected the api key to be deleted with the service account")
+	}
+}
+
+func testWebhooks(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id, err := s.CreateWebhook(ctx, storage.Webhook{
+		URL:       "https://example.com/hooks",
+		Events:    []string{constant.EventUserCreated},
+		Secret:    "secret",
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook, err := s.WebhookByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if webhook.Secret != "secret" || len(webhook.Events) != 1 {
+		t.Errorf("Expected the webhook with its secret and events, got %+v", webhook)
+	}
+
+	for i := 1; i <= 3; i++ {
+		err := s.AddWebhookDelivery(ctx, storage.WebhookDelivery{
+			WebhookID: id,
+			EventID:   fmt.Sprintf("event%d", i),
+			Attempt:   1,
+			CreatedAt: time.Now().Add(time.Duration(i) * time.Second).UTC(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, err := s.WebhookDeliveries(ctx, id, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 2 || deliveries[0].EventID != "event3" || deliveries[1].EventID != "event2" {
+		t.Errorf("Expected the last 2 deliveries the newest first, got %v", deliveries)
+	}
+
+	if err := s.DeleteWebhook(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if deliveries, err := s.WebhookDeliveries(ctx, id, 0); err != nil || len(deliveries) != 0 {
+		t.Errorf("Expected the deliveries to be deleted with the webhook, got %d %v", len(deliveries), err)
+	}
+}
@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+func (u Usecase) Users(ctx context.Context, filter storage.UserFilter) ([]storage.User, int64, error) {
+	const op = "usecase.admin.Users"
+
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = constant.DefaultPageLimit
+	}
+
+	if filter.Limit > constant.MaxPageLimit {
+		filter.Limit = constant.MaxPageLimit
+	}
+
+	users, total, err := u.Storage.Users(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, total, nil
+}
+
+func (u Usecase) User(ctx context.Context, id string) (storage.User, error) {
+	const op = "usecase.admin.User"
+
+	user, err := u.Storage.UserByID(ctx, id)
+	if err != nil {
+		return storage.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (u Usecase) UpdateUserInfo(ctx context.Context, id string, userInfo interface{}) (err error) {
+	const op = "usecase.admin.UpdateUserInfo"
+
+	defer func() { u.audit(ctx, constant.AuditUserUpdate, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.UpdateUserInfo(ctx, id, userInfo); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserUpdated, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetUserRoles replaces the roles of the user, the tokens issued before keep the old roles until they expire
+func (u Usecase) SetUserRoles(ctx context.Context, id string, roles []string) (err error) {
+	const op = "usecase.admin.SetUserRoles"
+
+	defer func() { u.audit(ctx, constant.AuditUserSetRoles, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.SetRoles(ctx, id, roles); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserUpdated, map[string]interface{}{"id": id, "roles": roles})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (u Usecase) DisableUser(ctx context.Context, id string) (err error) {
+	const op = "usecase.admin.DisableUser"
+
+	defer func() { u.audit(ctx, constant.AuditUserDisable, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.SetLocked(ctx, id, true); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserDisabled, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (u Usecase) EnableUser(ctx context.Context, id string) (err error) {
+	const op = "usecase.admin.EnableUser"
+
+	defer func() { u.audit(ctx, constant.AuditUserEnable, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.SetLocked(ctx, id, false); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserEnabled, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ForcePasswordReset marks the user so that sign in is refused until the password is reset by the emailed link
+func (u Usecase) ForcePasswordReset(ctx context.Context, id string) (err error) {
+	const op = "usecase.admin.ForcePasswordReset"
+
+	defer func() { u.audit(ctx, constant.AuditUserResetPassword, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.SetPasswordResetRequired(ctx, id, true); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserPasswordResetRequired, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (u Usecase) DeleteUser(ctx context.Context, id string) (err error) {
+	const op = "usecase.admin.DeleteUser"
+
+	defer func() { u.audit(ctx, constant.AuditUserDelete, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.DeleteUser(ctx, id); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserDeleted, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/dgrijalva/jwt-go/v4"
+	"strings"
+	"time"
+)
+
+// apiKeyTouchInterval limits how often the last used time is written for a busy key
+const apiKeyTouchInterval = time.Minute
+
+var ErrAPIKeyNotAllowed = errors.New("api keys can not manage credentials")
+
+// CreateAPIKey returns the key and its stored description, the key itself is shown only once
+func (u Usecase) CreateAPIKey(
+	ctx context.Context,
+	ownerType, ownerID, name string,
+	scopes []string,
+	expiresAt *time.Time,
+) (key string, apiKey storage.APIKey, err error) {
+	const op = "usecase.apikeys.CreateAPIKey"
+
+	defer func() { u.audit(ctx, constant.AuditAPIKeyCreate, ownerID, err) }()
+
+	if strings.TrimSpace(name) == "" {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", op, errors.New("name is required"))
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", op, errors.New("expiry is in the past"))
+	}
+
+	if err := u.checkAPIKeyOwner(ctx, ownerType, ownerID); err != nil {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key = constant.APIKeyPrefix + hex.EncodeToString(id) + "_" + secret
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	apiKey = storage.APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		OwnerID:   ownerID,
+		OwnerType: ownerType,
+		Scopes:    scopes,
+		Hash:      hashSecret(key),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.CreateAPIKey(ctx, apiKey); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventAPIKeyCreated, map[string]interface{}{
+			"id":         apiKey.ID,
+			"owner_id":   ownerID,
+			"owner_type": ownerType,
+			"scopes":     scopes,
+		})
+	})
+	if err != nil {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, apiKey, nil
+}
+
+func (u Usecase) APIKeys(ctx context.Context, ownerID string) ([]storage.APIKey, error) {
+	const op = "usecase.apikeys.APIKeys"
+
+	keys, err := u.Storage.APIKeys(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (u Usecase) RevokeAPIKey(ctx context.Context, ownerID, id string) (err error) {
+	const op = "usecase.apikeys.RevokeAPIKey"
+
+	defer func() { u.audit(ctx, constant.AuditAPIKeyRevoke, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.RevokeAPIKey(ctx, ownerID, id, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventAPIKeyRevoked, map[string]interface{}{"id": id, "owner_id": ownerID})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// verifyAPIKey resolves the key to the claims an access token of the owner would have,
+// with the scopes and the id of the key added
+func (u Usecase) verifyAPIKey(ctx context.Context, key string) (*UserClaims, error) {
+	id, _, _ := strings.Cut(strings.TrimPrefix(key, constant.APIKeyPrefix), "_")
+
+	apiKey, err := u.Storage.APIKeyByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameSecret(apiKey.Hash, key) {
+		return nil, errors.New("api key is not valid")
+	}
+
+	now := time.Now()
+
+	if apiKey.RevokedAt != nil {
+		return nil, errors.New("api key is revoked")
+	}
+
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, errors.New("api key is expired")
+	}
+
+	info, err := u.apiKeyOwnerInfo(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	info["scopes"] = apiKey.Scopes
+	info["api_key_id"] = apiKey.ID
+
+	userInfo, err := jwtValues(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		if err := u.Storage.TouchAPIKey(ctx, apiKey.ID, now.UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	claims := &UserClaims{UserInfo: userInfo}
+	if apiKey.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.At(*apiKey.ExpiresAt)
+	}
+
+	return claims, nil
+}
+
+func (u Usecase) apiKeyOwnerInfo(ctx context.Context, apiKey storage.APIKey) (map[string]interface{}, error) {
+	if apiKey.OwnerType == constant.OwnerServiceAccount {
+		account, err := u.Storage.ServiceAccountByID(ctx, apiKey.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"_id":             account.ID,
+			"name":            account.Name,
+			"roles":           account.Roles,
+			"service_account": true,
+		}, nil
+	}
+
+	user, err := u.Storage.UserByID(ctx, apiKey.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// a disabled user loses access by the keys at once, unlike by the issued tokens,
+	// the other replicas drop the cached user when redis shares the change with them
+	if err := checkUserState(user); err != nil {
+		return nil, err
+	}
+
+	return userClaims(user), nil
+}
+
+// jwtValues makes a json round trip of the info, so that it has the same value types as the claims of a parsed jwt
+func jwtValues(info map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (u Usecase) checkAPIKeyOwner(ctx context.Context, ownerType, ownerID string) error {
+	switch ownerType {
+	case constant.OwnerUser:
+		_, err := u.Storage.UserByID(ctx, ownerID)
+		return err
+	case constant.OwnerServiceAccount:
+		_, err := u.Storage.ServiceAccountByID(ctx, ownerID)
+		return err
+	default:
+		return fmt.Errorf("unknown owner type %q", ownerType)
+	}
+}
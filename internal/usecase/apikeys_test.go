@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeKeyStorage adds the api keys and the service accounts to the single user of fakeStorage
+type fakeKeyStorage struct {
+	fakeStorage
+	keys     map[string]storage.APIKey
+	accounts map[string]storage.ServiceAccount
+}
+
+func (s fakeKeyStorage) CreateServiceAccount(_ context.Context, account storage.ServiceAccount) (string, error) {
+	account.ID = primitive.NewObjectID().Hex()
+	s.accounts[account.ID] = account
+
+	return account.ID, nil
+}
+
+func (s fakeKeyStorage) ServiceAccountByID(_ context.Context, id string) (storage.ServiceAccount, error) {
+	account, ok := s.accounts[id]
+	if !ok {
+		return storage.ServiceAccount{}, errors.New("service account not found")
+	}
+
+	return account, nil
+}
+
+func (s fakeKeyStorage) CreateAPIKey(_ context.Context, apiKey storage.APIKey) error {
+	s.keys[apiKey.ID] = apiKey
+	return nil
+}
+
+func (s fakeKeyStorage) APIKeyByID(_ context.Context, id string) (storage.APIKey, error) {
+	apiKey, ok := s.keys[id]
+	if !ok {
+		return storage.APIKey{}, errors.New("api key not found")
+	}
+
+	return apiKey, nil
+}
+
+func (s fakeKeyStorage) TouchAPIKey(_ context.Context, id string, usedAt time.Time) error {
+	apiKey := s.keys[id]
+	apiKey.LastUsedAt = &usedAt
+	s.keys[id] = apiKey
+
+	return nil
+}
+
+func (s fakeKeyStorage) RevokeAPIKey(_ context.Context, ownerID, id string, revokedAt time.Time) error {
+	apiKey, ok := s.keys[id]
+	if !ok || apiKey.OwnerID != ownerID {
+		return errors.New("api key not found")
+	}
+
+	apiKey.RevokedAt = &revokedAt
+	s.keys[id] = apiKey
+
+	return nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	s := fakeKeyStorage{
+		fakeStorage: fakeStorage{user: user},
+		keys:        map[string]storage.APIKey{},
+		accounts:    map[string]storage.ServiceAccount{},
+	}
+
+	u := Usecase{Storage: s}
+
+	accountID, err := u.CreateServiceAccount(ctx, "ci", "deploys", []string{"deployer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+
+	userKey, _, err := u.CreateAPIKey(ctx, constant.OwnerUser, user.ID, "laptop", []string{"read"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountKey, _, err := u.CreateAPIKey(ctx, constant.OwnerServiceAccount, accountID, "pipeline", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredKey, expiredAPIKey, err := u.CreateAPIKey(ctx, constant.OwnerUser, user.ID, "old", nil, &later)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key outlives its expiry in the storage
+	past := time.Now().Add(-time.Minute)
+	expiredAPIKey.ExpiresAt = &past
+	s.keys[expiredAPIKey.ID] = expiredAPIKey
+
+	revokedKey, revokedAPIKey, err := u.CreateAPIKey(ctx, constant.OwnerUser, user.ID, "revoked", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.RevokeAPIKey(ctx, user.ID, revokedAPIKey.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := u.CreateAPIKey(ctx, constant.OwnerServiceAccount, "missing", "orphan", nil, nil); err == nil {
+		t.Errorf("Expected an error for a missing owner, got nil")
+	}
+
+	data := []struct {
+		name   string
+		key    string
+		valid  bool
+		userID string
+		roles  []string
+		scope  string
+	}{
+		{
+			name:   "user key",
+			key:    userKey,
+			valid:  true,
+			userID: user.ID,
+			scope:  "read",
+		},
+		{
+			name:   "service account key",
+			key:    accountKey,
+			valid:  true,
+			userID: accountID,
+			roles:  []string{"deployer"},
+		},
+		{
+			name: "wrong secret",
+			key:  userKey[:len(userKey)-1] + "x",
+		},
+		{
+			name: "unknown id",
+			key:  constant.APIKeyPrefix + "0000000000000000_" + strings.Repeat("a", 43),
+		},
+		{
+			name: "expired key",
+			key:  expiredKey,
+		},
+		{
+			name: "revoked key",
+			key:  revokedKey,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			result, err := u.VerifyToken(context.Background(), nil, d.key)
+			if !d.valid {
+				if err == nil {
+					t.Errorf("Expected an error, got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			claims := result.(*UserClaims)
+
+			if claims.UserID() != d.userID {
+				t.Errorf("Expected %v, got %v", d.userID, claims.UserID())
+			}
+
+			if strings.Join(claims.Roles(), ",") != strings.Join(d.roles, ",") {
+				t.Errorf("Expected %v, got %v", d.roles, claims.Roles())
+			}
+
+			if claims.APIKeyID() == "" {
+				t.Errorf("Expected the api key id in the claims")
+			}
+
+			if d.scope != "" && !claims.HasScope(d.scope) {
+				t.Errorf("Expected scope %v, got %v", d.scope, claims.UserInfo)
+			}
+
+			if claims.HasScope(constant.ScopeAdmin) {
+				t.Errorf("Expected no %v scope", constant.ScopeAdmin)
+			}
+		})
+	}
+}
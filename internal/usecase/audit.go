@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"io"
+)
+
+// audit records the outcome of the action, err is the error the action returned
+func (u Usecase) audit(ctx context.Context, action, target string, err error) {
+	if u.auditLog == nil {
+		return
+	}
+
+	entry := storage.AuditEntry{
+		Action:  action,
+		Outcome: constant.AuditSuccess,
+		Target:  target,
+	}
+
+	if err != nil {
+		entry.Outcome = constant.AuditFailure
+		entry.Error = err.Error()
+	}
+
+	u.auditLog.Record(ctx, entry)
+}
+
+// auditFailure records the failed action, the success is recorded where the action completes
+func (u Usecase) auditFailure(ctx context.Context, action, target string, err error) {
+	if err != nil {
+		u.audit(ctx, action, target, err)
+	}
+}
+
+func (u Usecase) AuditEntries(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEntry, error) {
+	const op = "usecase.audit.AuditEntries"
+
+	if filter.Limit <= 0 {
+		filter.Limit = constant.DefaultPageLimit
+	}
+
+	if filter.Limit > constant.MaxPageLimit {
+		filter.Limit = constant.MaxPageLimit
+	}
+
+	if u.auditLog == nil {
+		return nil, nil
+	}
+
+	entries, err := u.auditLog.Entries(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// ExportAudit writes the whole log matching the filter as json lines
+func (u Usecase) ExportAudit(ctx context.Context, filter storage.AuditFilter, w io.Writer) error {
+	const op = "usecase.audit.ExportAudit"
+
+	if u.auditLog == nil {
+		return nil
+	}
+
+	if err := u.auditLog.Export(ctx, filter, w); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// VerifyAudit checks the hash chain of the log and returns the number of valid entries
+func (u Usecase) VerifyAudit(ctx context.Context) (int64, error) {
+	const op = "usecase.audit.VerifyAudit"
+
+	if u.auditLog == nil {
+		return 0, nil
+	}
+
+	checked, err := u.auditLog.Verify(ctx)
+	if err != nil {
+		return checked, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checked, nil
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/audit"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type fakeAuditStorage struct {
+	entries []storage.AuditEntry
+}
+
+func (s *fakeAuditStorage) LastAuditEntry(_ context.Context) (storage.AuditEntry, error) {
+	if len(s.entries) == 0 {
+		return storage.AuditEntry{}, nil
+	}
+
+	return s.entries[len(s.entries)-1], nil
+}
+
+func (s *fakeAuditStorage) AddAuditEntry(_ context.Context, entry storage.AuditEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *fakeAuditStorage) AuditEntries(_ context.Context, _ storage.AuditFilter) ([]storage.AuditEntry, error) {
+	return s.entries, nil
+}
+
+func TestAudit(t *testing.T) {
+	var cfg config.Config
+
+	cfg.SigningKey = []byte("secret")
+	cfg.AccessDuration = time.Minute
+	cfg.RefreshDuration = 10 * time.Minute
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	auditStorage := &fakeAuditStorage{}
+
+	u := Usecase{
+		Storage: fakeKeyStorage{
+			fakeStorage: fakeStorage{user: user},
+			keys:        map[string]storage.APIKey{},
+			accounts:    map[string]storage.ServiceAccount{},
+		},
+		auditLog: audit.New(slog.New(slog.NewTextHandler(io.Discard, nil)), auditStorage),
+	}
+
+	ctx := audit.WithRequest(context.Background(), audit.Request{IP: "10.0.0.1", RequestID: "req-1"})
+
+	if _, err := u.issueTokens(ctx, cfg, *user); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := u.CreateAPIKey(audit.WithActor(ctx, user.ID, ""), constant.OwnerUser, user.ID, "", nil, nil); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+
+	data := []struct {
+		action  string
+		outcome string
+		actorID string
+		target  string
+	}{
+		{constant.AuditSignIn, constant.AuditSuccess, user.ID, user.Email},
+		{constant.AuditAPIKeyCreate, constant.AuditFailure, user.ID, user.ID},
+	}
+
+	if len(auditStorage.entries) != len(data) {
+		t.Fatalf("Expected %d entries, got %d", len(data), len(auditStorage.entries))
+	}
+
+	for i, d := range data {
+		e := auditStorage.entries[i]
+
+		if e.Action != d.action || e.Outcome != d.outcome || e.ActorID != d.actorID || e.Target != d.target {
+			t.Errorf("Expected %s %s by %s on %s, got %s %s by %s on %s",
+				d.action, d.outcome, d.actorID, d.target, e.Action, e.Outcome, e.ActorID, e.Target)
+		}
+
+		if e.IP != "10.0.0.1" || e.RequestID != "req-1" {
+			t.Errorf("Expected the request fields, got %s %s", e.IP, e.RequestID)
+		}
+	}
+
+	if auditStorage.entries[1].Error == "" {
+		t.Errorf("Expected the error of the failed action")
+	}
+
+	if checked, err := u.VerifyAudit(ctx); err != nil || checked != 2 {
+		t.Errorf("Expected 2 valid entries, got %d %v", checked, err)
+	}
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/directory"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+// Directory checks passwords instead of the local password hashes, e.g. in ldap
+type Directory interface {
+	Authenticate(ctx context.Context, email, password string) (directory.Entry, error)
+}
+
+// directorySignin checks the password in the directory and caches the directory user locally
+func (u Usecase) directorySignin(ctx context.Context, cfg config.Config, email, password, ip string) (Tokens, error) {
+	const op = "usecase.directory.directorySignin"
+
+	entry, err := u.directory.Authenticate(ctx, email, password)
+	if errors.Is(err, directory.ErrInvalidCredentials) {
+		// the directory owns the account, so there is no unlock email
+		if err := u.registerFailure(ctx, cfg, email, ip, false); err != nil {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("password or email is not correct"))
+	}
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(email)); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.cacheExternalUser(ctx, email, entry.UserInfo, entry.Roles, cfg.LDAP.GroupRoles)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := u.firstFactorTokens(ctx, cfg, email, user)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// cacheExternalUser creates or updates the local user with the attributes and roles from the directory
+// or the identity provider which own the account. Only the roles of groupRoles are managed by them, the roles
+// given to the user in gas are kept
+func (u Usecase) cacheExternalUser(
+	ctx context.Context,
+	email string,
+	userInfo map[string]interface{},
+	roles []string,
+	groupRoles map[string]string,
+) (storage.User, error) {
+	user, err := u.Storage.UserByEmail(ctx, email)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		// the local password is never used for the account
+		password, err := randomString(32)
+		if err != nil {
+			return storage.User{}, err
+		}
+
+		if user.ID, err = u.CreateUser(ctx, email, password, userInfo); err != nil {
+			return storage.User{}, err
+		}
+	case err != nil:
+		return storage.User{}, err
+	default:
+		if err := u.Storage.UpdateUserInfo(ctx, user.ID, userInfo); err != nil {
+			return storage.User{}, err
+		}
+	}
+
+	if err := u.Storage.SetRoles(ctx, user.ID, mergeRoles(user.Roles, roles, groupRoles)); err != nil {
+		return storage.User{}, err
+	}
+
+	return u.Storage.UserByID(ctx, user.ID)
+}
+
+// mergeRoles replaces the roles of groupRoles in the current roles with the mapped ones
+func mergeRoles(current, mapped []string, groupRoles map[string]string) []string {
+	managed := make(map[string]bool, len(groupRoles))
+	for _, role := range groupRoles {
+		managed[role] = true
+	}
+
+	roles := make([]string, 0, len(current)+len(mapped))
+	seen := make(map[string]bool, len(current)+len(mapped))
+
+	for _, role := range current {
+		if !managed[role] && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	for _, role := range mapped {
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
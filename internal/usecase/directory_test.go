@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"reflect"
+	"testing"
+)
+
+// unavailableStorage fails the lookups, the other methods panic through the nil interface
+type unavailableStorage struct {
+	storage.Storage
+}
+
+func (unavailableStorage) UserByEmail(_ context.Context, _ string) (storage.User, error) {
+	return storage.User{}, errors.New("connection refused")
+}
+
+func Test_mergeRoles(t *testing.T) {
+	groupRoles := map[string]string{"ops": "operator", "devs": "developer"}
+
+	data := []struct {
+		name     string
+		current  []string
+		mapped   []string
+		expected []string
+	}{
+		{"new user", nil, []string{"operator"}, []string{"operator"}},
+		{"local roles are kept", []string{constant.AdminRole}, []string{}, []string{constant.AdminRole}},
+		{"mapped role is added", []string{constant.AdminRole}, []string{"developer"}, []string{constant.AdminRole, "developer"}},
+		{"unmapped role is removed", []string{constant.AdminRole, "operator"}, []string{"developer"}, []string{constant.AdminRole, "developer"}},
+		{"no duplicates", []string{"operator"}, []string{"operator"}, []string{"operator"}},
+	}
+
+	for _, d := range data {
+		if roles := mergeRoles(d.current, d.mapped, groupRoles); !reflect.DeepEqual(roles, d.expected) {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, roles)
+		}
+	}
+}
+
+func TestCacheExternalUser(t *testing.T) {
+	ctx := context.Background()
+
+	groupRoles := map[string]string{"ops": "operator"}
+
+	u := Usecase{Storage: memory.New()}
+
+	id, err := u.CreateUser(ctx, "rupychman@mail.ru", "password", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.Storage.SetRoles(ctx, id, []string{constant.AdminRole}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := u.cacheExternalUser(ctx, "rupychman@mail.ru", map[string]interface{}{"name": "Rupych"}, []string{}, groupRoles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != id || !reflect.DeepEqual(user.Roles, []string{constant.AdminRole}) {
+		t.Errorf("Expected user %s with roles %v, got %s %v", id, []string{constant.AdminRole}, user.ID, user.Roles)
+	}
+
+	// a failed lookup must not be taken for a missing user
+	u.Storage = unavailableStorage{}
+
+	if _, err := u.cacheExternalUser(ctx, "rupychman@mail.ru", nil, nil, groupRoles); err == nil {
+		t.Error("Expected the storage error, got nil")
+	}
+}
@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"context"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+)
+
+// publish hands the event to the publisher if there is one, inside a transaction the event is written with the change
+func (u Usecase) publish(ctx context.Context, eventType string, data map[string]interface{}) error {
+	if u.events == nil {
+		return nil
+	}
+
+	return u.events.Publish(ctx, events.New(eventType, data))
+}
+
+// transaction runs fn in a storage transaction when the storage supports them, the storage calls and the events
+// published with the ctx passed to fn are then saved together or not at all
+func (u Usecase) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if t, ok := u.Storage.(storage.Transactor); ok {
+		return t.Transaction(ctx, fn)
+	}
+
+	return fn(ctx)
+}
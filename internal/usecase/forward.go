@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"net"
+	"strings"
+)
+
+var ErrAccessDenied = errors.New("access denied")
+
+// Authorize verifies the access token or the api key of a request to the host behind the proxy,
+// it is the check of forward-auth
+func (u Usecase) Authorize(ctx context.Context, cfg config.Config, host, token string) (*UserClaims, error) {
+	const op = "usecase.forward.Authorize"
+
+	data, err := u.VerifyToken(ctx, cfg.SigningKey, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, ok := data.(*UserClaims)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, errors.New("unexpected claims type"))
+	}
+
+	if !hasRequiredRole(cfg.ForwardAuth, host, claims.Roles()) {
+		return nil, fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	}
+
+	return claims, nil
+}
+
+// AuthorizeRefresh is the check of forward-auth for browsers that send only the refresh cookie.
+// The refresh token lives much longer than an access token, so the user is loaded on every request
+// like on refresh, and a lock or a role change applies at once
+func (u Usecase) AuthorizeRefresh(ctx context.Context, cfg config.Config, host, refreshToken string) (*UserClaims, error) {
+	const op = "usecase.forward.AuthorizeRefresh"
+
+	claims, err := u.verifyJWT(ctx, cfg.SigningKey, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.Storage.UserByID(ctx, claims.UserID())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkUserState(user); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userInfo, err := jwtValues(userClaims(user))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims = &UserClaims{StandardClaims: claims.StandardClaims, UserInfo: userInfo}
+
+	if !hasRequiredRole(cfg.ForwardAuth, host, claims.Roles()) {
+		return nil, fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	}
+
+	return claims, nil
+}
+
+func hasRequiredRole(cfg config.ForwardAuthSettings, host string, roles []string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	required, ok := cfg.Hosts[strings.ToLower(host)]
+	if !ok {
+		return !cfg.DenyUnlisted
+	}
+
+	if len(required) == 0 {
+		return true
+	}
+
+	for _, role := range roles {
+		for _, r := range required {
+			if role == r {
+				return true
+			}
+		}
+	}
+
+	return false
+}
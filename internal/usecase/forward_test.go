@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/cache"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+func TestAuthorize(t *testing.T) {
+	var cfg config.Config
+
+	cfg.SigningKey = []byte("secret")
+	cfg.ForwardAuth.Hosts = map[string][]string{
+		"grafana.example.com": {"admin", "ops"},
+	}
+
+	token := func(roles ...string) string {
+		info := map[string]interface{}{"_id": "1", "email": "rupychman@mail.ru", "roles": roles}
+
+		s, err := generateToken(info, time.Minute, cfg.SigningKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	}
+
+	u := Usecase{revocations: memory.NewRevocationStorage()}
+
+	data := []struct {
+		name  string
+		host  string
+		token string
+		err   error
+	}{
+		{
+			name:  "unlisted host",
+			host:  "blog.example.com",
+			token: token(),
+		},
+		{
+			name:  "one of the roles",
+			host:  "grafana.example.com",
+			token: token("ops"),
+		},
+		{
+			name:  "host with port",
+			host:  "Grafana.example.com:443",
+			token: token("admin"),
+		},
+		{
+			name:  "missing role",
+			host:  "grafana.example.com",
+			token: token("user"),
+			err:   ErrAccessDenied,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			claims, err := u.Authorize(context.Background(), cfg, d.host, d.token)
+			if !errors.Is(err, d.err) {
+				t.Fatalf("Expected %v, got %v", d.err, err)
+			}
+
+			if err == nil && claims.Email() != "rupychman@mail.ru" {
+				t.Errorf("Expected %v, got %v", "rupychman@mail.ru", claims.Email())
+			}
+		})
+	}
+
+	if _, err := u.Authorize(context.Background(), cfg, "blog.example.com", "not a token"); err == nil || errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected a token error, got %v", err)
+	}
+	cfg.ForwardAuth.DenyUnlisted = true
+
+	if _, err := u.Authorize(context.Background(), cfg, "blog.example.com", token("admin")); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected %v for the unlisted host, got %v", ErrAccessDenied, err)
+	}
+}
+
+func TestAuthorizeRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	var cfg config.Config
+
+	cfg.SigningKey = []byte("secret")
+	cfg.AccessDuration = time.Minute
+	cfg.RefreshDuration = time.Hour
+	cfg.ForwardAuth.Hosts = map[string][]string{
+		"grafana.example.com": {"admin"},
+	}
+
+	u := Usecase{
+		Storage:     memory.New(),
+		revocations: memory.NewRevocationStorage(),
+		tokens:      cache.New(10, time.Minute),
+	}
+
+	id, err := u.CreateUser(ctx, "rupychman@mail.ru", "password", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.Storage.SetRoles(ctx, id, []string{"admin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := u.Storage.UserByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := u.issueTokens(ctx, cfg, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := u.AuthorizeRefresh(ctx, cfg, "grafana.example.com", tokens.Refresh)
+	if err != nil {
+		t.Fatalf("Expected the refresh token to pass, got %v", err)
+	}
+
+	if claims.UserID() != id {
+		t.Errorf("Expected %v, got %v", id, claims.UserID())
+	}
+
+	// the roles signed into the refresh token are not trusted
+	if err := u.Storage.SetRoles(ctx, id, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.AuthorizeRefresh(ctx, cfg, "grafana.example.com", tokens.Refresh); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected %v after the role is removed, got %v", ErrAccessDenied, err)
+	}
+
+	if err := u.Storage.SetLocked(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.AuthorizeRefresh(ctx, cfg, "blog.example.com", tokens.Refresh); err == nil {
+		t.Error("Expected the refresh token of the disabled user to be rejected")
+	}
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const unlockPurpose = "unlock"
+
+var ErrTemporarilyLocked = errors.New("too many failed attempts, try again later")
+
+// Unlock resets the failed attempts of the account the unlock token was sent to
+func (u Usecase) Unlock(ctx context.Context, cfg config.Config, token string) (err error) {
+	const op = "usecase.lockout.Unlock"
+
+	var email string
+
+	defer func() { u.audit(ctx, constant.AuditUnlock, email, err) }()
+
+	email, err = parsePurposeToken(cfg.SigningKey, unlockPurpose, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(email)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.publish(ctx, constant.EventUserUnlocked, map[string]interface{}{"email": email}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnlockUser is the admin way to reset the failed attempts of the account
+func (u Usecase) UnlockUser(ctx context.Context, id string) (err error) {
+	const op = "usecase.lockout.UnlockUser"
+
+	defer func() { u.audit(ctx, constant.AuditUserUnlock, id, err) }()
+
+	user, err := u.Storage.UserByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(user.Email)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.publish(ctx, constant.EventUserUnlocked, map[string]interface{}{"id": id, "email": user.Email}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// checkLockout returns the max number of recent failures for the account and the ip
+func (u Usecase) checkLockout(ctx context.Context, email, ip string) (int, error) {
+	var failures int
+
+	for _, key := range []string{accountAttemptsKey(email), ipAttemptsKey(ip)} {
+		attempts, err := u.attempts.FailedAttempts(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+
+		if attempts.LockedUntil.After(time.Now()) {
+			return 0, ErrTemporarilyLocked
+		}
+
+		if attempts.Failures > failures {
+			failures = attempts.Failures
+		}
+	}
+
+	return failures, nil
+}
+
+// registerFailure counts the failed attempt and locks the account or the ip when the threshold is reached,
+// userExists is used to send an unlock email only to real accounts
+func (u Usecase) registerFailure(ctx context.Context, cfg config.Config, email, ip string, userExists bool) error {
+	settings := cfg.Lockout
+
+	accountAttempts, err := u.attempts.AddFailedAttempt(ctx, accountAttemptsKey(email), settings.Window)
+	if err != nil {
+		return err
+	}
+
+	ipAttempts, err := u.attempts.AddFailedAttempt(ctx, ipAttemptsKey(ip), settings.Window)
+	if err != nil {
+		return err
+	}
+
+	lockedUntil := time.Now().Add(settings.LockDuration)
+
+	if settings.IPThreshold > 0 && ipAttempts.Failures >= settings.IPThreshold {
+		if err := u.attempts.LockAttempts(ctx, ipAttemptsKey(ip), lockedUntil); err != nil {
+			return err
+		}
+	}
+
+	if settings.AccountThreshold > 0 && accountAttempts.Failures >= settings.AccountThreshold {
+		if err := u.attempts.LockAttempts(ctx, accountAttemptsKey(email), lockedUntil); err != nil {
+			return err
+		}
+
+		// notify only once, when the account is locked right now
+		if userExists && accountAttempts.Failures == settings.AccountThreshold {
+			if err := u.publish(ctx, constant.EventUserLocked, map[string]interface{}{
+				"email":        email,
+				"locked_until": lockedUntil.UTC(),
+			}); err != nil {
+				return err
+			}
+
+			return u.sendUnlockEmail(ctx, cfg, email)
+		}
+	}
+
+	return nil
+}
+
+func (u Usecase) sendUnlockEmail(ctx context.Context, cfg config.Config, email string) error {
+	if cfg.Lockout.UnlockURL == "" {
+		return nil
+	}
+
+	token, err := generatePurposeToken(cfg.SigningKey, unlockPurpose, email, cfg.Lockout.UnlockDuration)
+	if err != nil {
+		return err
+	}
+
+	link, err := url.Parse(cfg.Lockout.UnlockURL)
+	if err != nil {
+		return err
+	}
+
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+
+	body := fmt.Sprintf(
+		"Your account was locked after too many failed sign in attempts.\n\nFollow the link to unlock it: %s\n",
+		link.String(),
+	)
+
+	return u.mailer.Send(ctx, email, "Your account is locked", body)
+}
+
+// loginDelay doubles the delay with every recent failure up to the max delay
+func loginDelay(settings config.LockoutSettings, failures int) time.Duration {
+	if failures == 0 || settings.DelayStep <= 0 {
+		return 0
+	}
+
+	delay := settings.DelayStep
+	for i := 1; i < failures && delay < settings.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > settings.MaxDelay {
+		return settings.MaxDelay
+	}
+
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func accountAttemptsKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func Test_loginDelay(t *testing.T) {
+	settings := config.LockoutSettings{
+		DelayStep: 250 * time.Millisecond,
+		MaxDelay:  4 * time.Second,
+	}
+
+	data := []struct {
+		name     string
+		failures int
+		expected time.Duration
+	}{
+		{"no failures", 0, 0},
+		{"first failure", 1, 250 * time.Millisecond},
+		{"third failure", 3, time.Second},
+		{"capped", 10, 4 * time.Second},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if delay := loginDelay(settings, d.failures); delay != d.expected {
+				t.Errorf("Expected %v, got %v", d.expected, delay)
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: time.Hour,
+		},
+		Lockout: config.LockoutSettings{
+			AccountThreshold: 3,
+			IPThreshold:      5,
+			Window:           time.Minute,
+			LockDuration:     200 * time.Millisecond,
+			UnlockURL:        "http://localhost:3000/unlock",
+			UnlockDuration:   time.Minute,
+		},
+	}
+
+	var body string
+
+	u := Usecase{
+		Storage:  memory.New(),
+		attempts: memory.NewAttemptsStorage(),
+		mailer:   fakeMailer{body: &body},
+	}
+
+	const email, password = "rupychman@mail.ru", "password"
+
+	id, err := u.CreateUser(ctx, email, password, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		ok     = "ok"
+		failed = "failed"
+		locked = "locked"
+	)
+
+	signin := func(email, password, ip string) string {
+		_, err := u.Signin(ctx, cfg, email, password, ip)
+
+		switch {
+		case err == nil:
+			return ok
+		case errors.Is(err, ErrTemporarilyLocked):
+			return locked
+		default:
+			return failed
+		}
+	}
+
+	// the steps run in order, each one sees the counters left by the previous ones
+	data := []struct {
+		name     string
+		do       func() string
+		expected string
+	}{
+		{"first failure", func() string { return signin(email, "wrong", "10.0.0.1") }, failed},
+		{"second failure", func() string { return signin(email, "wrong", "10.0.0.2") }, failed},
+		{"success resets the account", func() string { return signin(email, password, "10.0.0.3") }, ok},
+		{"failure after reset", func() string { return signin(email, "wrong", "10.0.0.1") }, failed},
+		{"second failure after reset", func() string { return signin(email, "wrong", "10.0.0.2") }, failed},
+		{"account threshold", func() string { return signin(email, "wrong", "10.0.0.3") }, failed},
+		{"account locked from any ip", func() string { return signin(email, password, "10.0.0.4") }, locked},
+		{"unlock token", func() string {
+			token := regexp.MustCompile(`http://\S+`).FindString(body)
+
+			link, err := url.Parse(token)
+			if err != nil {
+				return failed
+			}
+
+			if err := u.Unlock(ctx, cfg, link.Query().Get("token")); err != nil {
+				return failed
+			}
+
+			return ok
+		}, ok},
+		{"unlocked by token", func() string { return signin(email, password, "10.0.0.4") }, ok},
+		{"lock again", func() string {
+			for i := 0; i < cfg.Lockout.AccountThreshold; i++ {
+				signin(email, "wrong", "10.0.0.5")
+			}
+
+			return signin(email, password, "10.0.0.6")
+		}, locked},
+		{"admin unlock", func() string {
+			if err := u.UnlockUser(ctx, id); err != nil {
+				return failed
+			}
+
+			return signin(email, password, "10.0.0.6")
+		}, ok},
+		{"lock expires", func() string {
+			for i := 0; i < cfg.Lockout.AccountThreshold; i++ {
+				signin(email, "wrong", "10.0.0.7")
+			}
+
+			time.Sleep(2 * cfg.Lockout.LockDuration)
+
+			return signin(email, password, "10.0.0.8")
+		}, ok},
+		{"ip threshold", func() string {
+			for i := 0; i < cfg.Lockout.IPThreshold; i++ {
+				// different accounts, so that only the ip reaches its threshold
+				signin(fmt.Sprintf("unknown%d@mail.ru", i), "wrong", "10.0.0.9")
+			}
+
+			return signin(email, password, "10.0.0.9")
+		}, locked},
+		{"other ip", func() string { return signin(email, password, "10.0.0.10") }, ok},
+		{"unknown admin unlock", func() string {
+			if err := u.UnlockUser(ctx, "404"); err != nil {
+				return failed
+			}
+
+			return ok
+		}, failed},
+	}
+
+	for _, d := range data {
+		if result := d.do(); result != d.expected {
+			t.Errorf("%s: expected %v, got %v", d.name, d.expected, result)
+		}
+	}
+}
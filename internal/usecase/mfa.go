@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/totp"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/skip2/go-qrcode"
+	"strings"
+	"time"
+)
+
+// ErrPasskeyRequired means the second factor of the user is a passkey, the sign in is completed by the webauthn steps
+var ErrPasskeyRequired = errors.New("second factor is a passkey")
+
+const (
+	mfaPurpose = "mfa"
+
+	qrCodeSize = 256
+)
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+	// QRCode is a png image of the uri
+	QRCode []byte
+}
+
+// EnrollTOTP generates a new pending secret, two-factor authentication is enabled after ConfirmTOTP
+func (u Usecase) EnrollTOTP(ctx context.Context, cfg config.Config, userID string) (TOTPEnrollment, error) {
+	const op = "usecase.mfa.EnrollTOTP"
+
+	user, err := u.Storage.UserByID(ctx, userID)
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.MFAEnabled {
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, errors.New("two-factor authentication is already enabled"))
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.Storage.SetPendingTOTPSecret(ctx, userID, secret); err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	uri := totp.URI(cfg.MFA.Issuer, user.Email, secret)
+
+	qrCode, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return TOTPEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: qrCode,
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication and returns recovery codes, they are shown only once
+func (u Usecase) ConfirmTOTP(ctx context.Context, cfg config.Config, userID, code string) (codes []string, err error) {
+	const op = "usecase.mfa.ConfirmTOTP"
+
+	defer func() { u.audit(ctx, constant.AuditUserEnableMFA, userID, err) }()
+
+	user, err := u.Storage.UserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PendingTOTPSecret == "" {
+		return nil, fmt.Errorf("%s: %w", op, errors.New("two-factor authentication enrollment is not started"))
+	}
+
+	step, ok := totp.ValidateStep(user.PendingTOTPSecret, code, time.Now(), cfg.MFA.Skew)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, errors.New("code is not valid"))
+	}
+
+	codes, hashes, err := generateRecoveryCodes(cfg.MFA.RecoveryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.EnableTOTP(ctx, userID, user.PendingTOTPSecret, hashes); err != nil {
+			return err
+		}
+
+		// the confirmation code must not sign in again
+		if err := u.Storage.UseTOTPStep(ctx, userID, step); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserMFAEnabled, map[string]interface{}{
+			"id":     userID,
+			"method": constant.MFAMethodTOTP,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+// VerifyMFA completes the sign in started by Signin, the code is either a totp code or a recovery code
+func (u Usecase) VerifyMFA(ctx context.Context, cfg config.Config, mfaToken, code, ip string) (tokens Tokens, err error) {
+	const op = "usecase.mfa.VerifyMFA"
+
+	var email string
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, email, err) }()
+
+	email, err = parsePurposeToken(cfg.SigningKey, mfaPurpose, mfaToken)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := u.checkLockout(ctx, email, ip); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.Storage.UserByEmail(ctx, email)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.MFAMethod == constant.MFAMethodWebAuthn {
+		return Tokens{}, fmt.Errorf("%s: %w", op, ErrPasskeyRequired)
+	}
+
+	valid, err := u.useTOTPCode(ctx, cfg, user, code)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !valid {
+		if err := u.Storage.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code)); err != nil {
+			if err := u.registerFailure(ctx, cfg, email, ip, true); err != nil {
+				return Tokens{}, fmt.Errorf("%s: %w", op, err)
+			}
+
+			return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("code is not valid"))
+		}
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(email)); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkUserState(user); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err = u.issueTokens(ctx, cfg, user)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// useTOTPCode accepts the totp code once, the code of the time step accepted last or an earlier one is a replay
+func (u Usecase) useTOTPCode(ctx context.Context, cfg config.Config, user storage.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+
+	step, ok := totp.ValidateStep(user.TOTPSecret, code, time.Now(), cfg.MFA.Skew)
+	if !ok {
+		return false, nil
+	}
+
+	if err := u.Storage.UseTOTPStep(ctx, user.ID, step); err != nil {
+		if errors.Is(err, storage.ErrTOTPStepUsed) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// generateRecoveryCodes returns codes formatted as xxxx-xxxx and their hashes
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	for i := 0; i < n; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode uses sha256 since the codes are random and long enough to not need a slow hash
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
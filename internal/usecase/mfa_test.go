@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/totp"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+// emptyKeyCode is the totp code anyone computes for the empty secret
+func emptyKeyCode(t time.Time) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(totp.Step(t)))
+
+	mac := hmac.New(sha1.New, nil)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestVerifyMFA(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: time.Hour,
+		},
+		MFA: config.MFASettings{
+			Issuer:            "gas",
+			ChallengeDuration: time.Minute,
+			Skew:              1,
+			RecoveryCodes:     2,
+		},
+	}
+
+	u := Usecase{
+		Storage:  memory.New(),
+		attempts: memory.NewAttemptsStorage(),
+	}
+
+	const password = "password"
+
+	enrolled, err := u.CreateUser(ctx, "rupychman@mail.ru", password, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment, err := u.EnrollTOTP(ctx, cfg, enrolled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	current, err := totp.Code(enrollment.Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := totp.Code(enrollment.Secret, now.Add(totp.Period))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.ConfirmTOTP(ctx, cfg, enrolled, current); err != nil {
+		t.Fatal(err)
+	}
+
+	// totp asked for without a secret, the way the second factor used to be turned on for a passkey
+	noSecret, err := u.CreateUser(ctx, "degeboman@mail.ru", password, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.Storage.SetMFAMethod(ctx, noSecret, constant.MFAMethodTOTP); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		name  string
+		email string
+		code  string
+		valid bool
+	}{
+		{"confirmation code replayed", "rupychman@mail.ru", current, false},
+		{"next step", "rupychman@mail.ru", next, true},
+		{"next step replayed", "rupychman@mail.ru", next, false},
+		{"earlier step", "rupychman@mail.ru", current, false},
+		{"empty secret", "degeboman@mail.ru", emptyKeyCode(now), false},
+	}
+
+	for _, d := range data {
+		tokens, err := u.Signin(ctx, cfg, d.email, password, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tokens.MFA == "" {
+			t.Fatalf("%s: expected the mfa challenge", d.name)
+		}
+
+		_, err = u.VerifyMFA(ctx, cfg, tokens.MFA, d.code, "10.0.0.1")
+		if (err == nil) != d.valid {
+			t.Errorf("%s: expected valid %v, got %v", d.name, d.valid, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"net/url"
+)
+
+// SendPasswordReset emails a single use link to set a new password, unknown emails are silently ignored
+func (u Usecase) SendPasswordReset(ctx context.Context, cfg config.Config, email string) error {
+	const op = "usecase.password.SendPasswordReset"
+
+	if cfg.PasswordReset.URL == "" {
+		return fmt.Errorf("%s: %w", op, errors.New("password reset url is not configured"))
+	}
+
+	user, err := u.Storage.UserByEmail(ctx, email)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := cfg.PasswordReset.Duration
+
+	if err := u.challenges.SaveChallenge(ctx, passwordResetChallengeKey(token), []byte(user.ID), ttl); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	link, err := url.Parse(cfg.PasswordReset.URL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+
+	body := fmt.Sprintf(
+		"Follow the link to set a new password: %s\n\nThe link expires in %s. Ignore this email if you didn't ask for it.\n",
+		link.String(),
+		ttl,
+	)
+
+	if err := u.mailer.Send(ctx, user.Email, "Reset your password", body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets the password of the user the reset link was sent to, it clears the reset required by
+// an admin and the failed sign in attempts of the account
+func (u Usecase) ResetPassword(ctx context.Context, token, password string) (err error) {
+	const op = "usecase.password.ResetPassword"
+
+	var id string
+
+	defer func() { u.audit(ctx, constant.AuditPasswordReset, id, err) }()
+
+	if len(password) < 6 {
+		return fmt.Errorf("%s: %w", op, ErrPasswordTooShort)
+	}
+
+	data, err := u.challenges.TakeChallenge(ctx, passwordResetChallengeKey(token))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	id = string(data)
+
+	user, err := u.Storage.UserByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.SetPassword(ctx, id, hashPassword(password)); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserPasswordChanged, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(user.Email)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func passwordResetChallengeKey(token string) string {
+	return "password-reset:" + hashSecret(token)
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: time.Hour,
+		},
+		PasswordReset: config.PasswordResetSettings{
+			URL:      "http://localhost:3000/password-reset",
+			Duration: time.Minute,
+		},
+	}
+
+	var (
+		body      string
+		published []events.Event
+	)
+
+	u := Usecase{
+		Storage:    memory.New(),
+		attempts:   memory.NewAttemptsStorage(),
+		challenges: memory.NewChallengeStorage(),
+		mailer:     fakeMailer{body: &body},
+		events:     fakePublisher{events: &published},
+	}
+
+	const email, password = "rupychman@mail.ru", "password"
+
+	id, err := u.CreateUser(ctx, email, password, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.ForcePasswordReset(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.Signin(ctx, cfg, email, password, "10.0.0.1"); err == nil {
+		t.Fatal("Expected the sign in to be refused until the password is reset")
+	}
+
+	body = ""
+
+	if err := u.SendPasswordReset(ctx, cfg, "unknown@mail.ru"); err != nil || body != "" {
+		t.Errorf("Expected unknown emails to be ignored, got %v %q", err, body)
+	}
+
+	if err := u.SendPasswordReset(ctx, cfg, email); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := url.Parse(regexp.MustCompile(`http://\S+`).FindString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := link.Query().Get("token")
+
+	data := []struct {
+		name     string
+		token    string
+		password string
+		ok       bool
+	}{
+		{"short password", token, "short", false},
+		{"wrong token", "wrong", "new password", false},
+		{"reset", token, "new password", true},
+		{"token used", token, "other password", false},
+	}
+
+	for _, d := range data {
+		if err := u.ResetPassword(ctx, d.token, d.password); (err == nil) != d.ok {
+			t.Errorf("%s: expected %v, got %v", d.name, d.ok, err)
+		}
+	}
+
+	if _, err := u.Signin(ctx, cfg, email, password, "10.0.0.1"); err == nil {
+		t.Error("Expected the old password to be refused")
+	}
+
+	if _, err := u.Signin(ctx, cfg, email, "new password", "10.0.0.1"); err != nil {
+		t.Errorf("Expected the sign in with the new password, got %v", err)
+	}
+
+	changed := false
+
+	for _, event := range published {
+		if event.Type == constant.EventUserPasswordChanged && event.Data["id"] == id {
+			changed = true
+		}
+	}
+
+	if !changed {
+		t.Errorf("Expected the %s event, got %v", constant.EventUserPasswordChanged, published)
+	}
+}
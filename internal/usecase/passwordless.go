@@ -0,0 +1,337 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const otpDigits = 6
+
+// passwordlessChallenge is kept in the challenge storage until the link is followed or the code is entered
+type passwordlessChallenge struct {
+	Email string `json:"email"`
+	// BindingHash ties the challenge to the browser that requested it
+	BindingHash string    `json:"binding_hash"`
+	CodeHash    string    `json:"code_hash,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// SendMagicLink emails a single use sign in link, unknown emails are silently ignored unless accounts are auto created
+func (u Usecase) SendMagicLink(ctx context.Context, cfg config.Config, email, binding string) error {
+	const op = "usecase.passwordless.SendMagicLink"
+
+	if cfg.Passwordless.MagicLinkURL == "" {
+		return fmt.Errorf("%s: %w", op, errors.New("magic link url is not configured"))
+	}
+
+	accepts, err := u.acceptsPasswordless(ctx, cfg, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !accepts {
+		return nil
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := cfg.Passwordless.MagicLinkDuration
+
+	err = u.savePasswordlessChallenge(ctx, magicLinkChallengeKey(token), passwordlessChallenge{
+		Email:       email,
+		BindingHash: hashSecret(binding),
+		ExpiresAt:   time.Now().Add(ttl),
+	}, ttl)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	link, err := url.Parse(cfg.Passwordless.MagicLinkURL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+
+	body := fmt.Sprintf(
+		"Follow the link to sign in: %s\n\nThe link expires in %s and works only in the browser it was requested from.\n",
+		link.String(),
+		ttl,
+	)
+
+	if err := u.mailer.Send(ctx, email, "Your sign in link", body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (u Usecase) SigninWithMagicLink(ctx context.Context, cfg config.Config, token, binding string) (tokens Tokens, err error) {
+	const op = "usecase.passwordless.SigninWithMagicLink"
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, "", err) }()
+
+	challenge, err := u.takePasswordlessChallenge(ctx, magicLinkChallengeKey(token))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !sameSecret(challenge.BindingHash, binding) {
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("link was requested from another browser"))
+	}
+
+	tokens, err = u.passwordlessTokens(ctx, cfg, challenge.Email)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// SendOTP emails a one time code, a new code replaces the previous one requested from the same browser
+func (u Usecase) SendOTP(ctx context.Context, cfg config.Config, email, binding string) error {
+	const op = "usecase.passwordless.SendOTP"
+
+	accepts, err := u.acceptsPasswordless(ctx, cfg, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !accepts {
+		return nil
+	}
+
+	code, err := randomDigits(otpDigits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := cfg.Passwordless.OTPDuration
+
+	err = u.savePasswordlessChallenge(ctx, otpChallengeKey(binding), passwordlessChallenge{
+		Email:       email,
+		BindingHash: hashSecret(binding),
+		CodeHash:    hashSecret(code),
+		ExpiresAt:   time.Now().Add(ttl),
+	}, ttl)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	body := fmt.Sprintf("Your sign in code is %s\n\nThe code expires in %s.\n", code, ttl)
+
+	if err := u.mailer.Send(ctx, email, "Your sign in code", body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SigninWithOTP counts wrong codes against the account and the ip like a wrong password,
+// and against the email within the code lifetime, so requesting a new code does not give new attempts
+func (u Usecase) SigninWithOTP(ctx context.Context, cfg config.Config, code, binding, ip string) (tokens Tokens, err error) {
+	const op = "usecase.passwordless.SigninWithOTP"
+
+	var email string
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, email, err) }()
+
+	key := otpChallengeKey(binding)
+
+	challenge, err := u.takePasswordlessChallenge(ctx, key)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email = challenge.Email
+
+	if _, err := u.checkLockout(ctx, email, ip); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	attempts, err := u.attempts.FailedAttempts(ctx, otpAttemptsKey(email))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if attempts.Failures >= cfg.Passwordless.OTPAttempts {
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("too many wrong codes"))
+	}
+
+	if !sameSecret(challenge.CodeHash, code) {
+		if err := u.registerOTPFailure(ctx, cfg, key, challenge, ip); err != nil {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("code is not valid"))
+	}
+
+	for _, key := range []string{otpAttemptsKey(email), accountAttemptsKey(email)} {
+		if err := u.attempts.ResetAttempts(ctx, key); err != nil {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	tokens, err = u.passwordlessTokens(ctx, cfg, email)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// registerOTPFailure counts the wrong code and puts the challenge back until the email runs out of attempts
+func (u Usecase) registerOTPFailure(ctx context.Context, cfg config.Config, key string, challenge passwordlessChallenge, ip string) error {
+	_, err := u.Storage.UserByEmail(ctx, challenge.Email)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	if err := u.registerFailure(ctx, cfg, challenge.Email, ip, err == nil); err != nil {
+		return err
+	}
+
+	attempts, err := u.attempts.AddFailedAttempt(ctx, otpAttemptsKey(challenge.Email), cfg.Passwordless.OTPDuration)
+	if err != nil {
+		return err
+	}
+
+	if ttl := time.Until(challenge.ExpiresAt); attempts.Failures < cfg.Passwordless.OTPAttempts && ttl > 0 {
+		return u.savePasswordlessChallenge(ctx, key, challenge, ttl)
+	}
+
+	return nil
+}
+
+// passwordlessTokens signs in the owner of the email, creating the account if it is allowed
+func (u Usecase) passwordlessTokens(ctx context.Context, cfg config.Config, email string) (Tokens, error) {
+	user, err := u.Storage.UserByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) || !cfg.Passwordless.AutoCreate {
+			return Tokens{}, err
+		}
+
+		// nobody knows the password, the account is used only without it
+		password, err := randomString(32)
+		if err != nil {
+			return Tokens{}, err
+		}
+
+		if _, err := u.CreateUser(ctx, email, password, nil); err != nil {
+			return Tokens{}, err
+		}
+
+		if user, err = u.Storage.UserByEmail(ctx, email); err != nil {
+			return Tokens{}, err
+		}
+	}
+
+	return u.firstFactorTokens(ctx, cfg, email, user)
+}
+
+func (u Usecase) acceptsPasswordless(ctx context.Context, cfg config.Config, email string) (bool, error) {
+	if !isValidEmail(email) {
+		return false, nil
+	}
+
+	if cfg.Passwordless.AutoCreate {
+		return true, nil
+	}
+
+	_, err := u.Storage.UserByEmail(ctx, email)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (u Usecase) savePasswordlessChallenge(ctx context.Context, key string, challenge passwordlessChallenge, ttl time.Duration) error {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return u.challenges.SaveChallenge(ctx, key, data, ttl)
+}
+
+func (u Usecase) takePasswordlessChallenge(ctx context.Context, key string) (passwordlessChallenge, error) {
+	data, err := u.challenges.TakeChallenge(ctx, key)
+	if err != nil {
+		return passwordlessChallenge{}, err
+	}
+
+	var challenge passwordlessChallenge
+
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return passwordlessChallenge{}, err
+	}
+
+	return challenge, nil
+}
+
+func magicLinkChallengeKey(token string) string {
+	return "magic-link:" + hashSecret(token)
+}
+
+func otpChallengeKey(binding string) string {
+	return "otp:" + hashSecret(binding)
+}
+
+func otpAttemptsKey(email string) string {
+	return "otp:" + strings.ToLower(email)
+}
+
+func hashSecret(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sameSecret(hash, s string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(hashSecret(s))) == 1
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomDigits(n int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < n; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", n, v), nil
+}
@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// fakeMailer keeps the last message body
+type fakeMailer struct {
+	body *string
+}
+
+func (m fakeMailer) Send(_ context.Context, _, _, body string) error {
+	*m.body = body
+	return nil
+}
+
+func TestPasswordless(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: 7 * 24 * time.Hour,
+		},
+		Passwordless: config.PasswordlessSettings{
+			MagicLinkURL:      "http://localhost:3000/magic-link",
+			MagicLinkDuration: time.Minute,
+			OTPDuration:       time.Minute,
+			OTPAttempts:       2,
+		},
+	}
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	const ip = "127.0.0.1"
+
+	var body string
+
+	u := Usecase{
+		Storage:    fakeStorage{user: user},
+		attempts:   memory.NewAttemptsStorage(),
+		challenges: memory.NewChallengeStorage(),
+		mailer:     fakeMailer{body: &body},
+	}
+
+	if err := u.SendMagicLink(ctx, cfg, "unknown@mail.ru", "browser"); err != nil || body != "" {
+		t.Errorf("Expected unknown email to be silently ignored, got %v and %q", err, body)
+	}
+
+	if err := u.SendMagicLink(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.SigninWithMagicLink(ctx, cfg, magicLinkToken(t, body), "another"); err == nil {
+		t.Error("Expected the link opened in another browser to be rejected")
+	}
+
+	if err := u.SendMagicLink(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	token := magicLinkToken(t, body)
+
+	if _, err := u.SigninWithMagicLink(ctx, cfg, token, "browser"); err != nil {
+		t.Fatalf("Expected sign in with the link to succeed, got %s", err)
+	}
+
+	if _, err := u.SigninWithMagicLink(ctx, cfg, token, "browser"); err == nil {
+		t.Error("Expected the used link to be rejected")
+	}
+
+	if err := u.SendOTP(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	code := regexp.MustCompile(`\d{6}`).FindString(body)
+
+	if _, err := u.SigninWithOTP(ctx, cfg, "wrong", "browser", ip); err == nil {
+		t.Error("Expected the wrong code to be rejected")
+	}
+
+	tokens, err := u.SigninWithOTP(ctx, cfg, code, "browser", ip)
+	if err != nil {
+		t.Fatalf("Expected sign in with the code to succeed, got %s", err)
+	}
+
+	if tokens.Access == "" || tokens.Refresh == "" {
+		t.Error("Expected access and refresh tokens")
+	}
+
+	if err := u.SendOTP(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	code = regexp.MustCompile(`\d{6}`).FindString(body)
+
+	for i := 0; i < cfg.Passwordless.OTPAttempts; i++ {
+		_, _ = u.SigninWithOTP(ctx, cfg, "wrong", "browser", ip)
+	}
+
+	if _, err := u.SigninWithOTP(ctx, cfg, code, "browser", ip); err == nil {
+		t.Error("Expected the code to be discarded after too many attempts")
+	}
+
+	if err := u.SendOTP(ctx, cfg, user.Email, "another"); err != nil {
+		t.Fatal(err)
+	}
+
+	code = regexp.MustCompile(`\d{6}`).FindString(body)
+
+	if _, err := u.SigninWithOTP(ctx, cfg, code, "another", ip); err == nil {
+		t.Error("Expected a new code not to give new attempts")
+	}
+}
+
+func TestOTPLockout(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: time.Hour,
+		},
+		Lockout: config.LockoutSettings{
+			AccountThreshold: 3,
+			IPThreshold:      5,
+			Window:           time.Minute,
+			LockDuration:     time.Minute,
+		},
+		Passwordless: config.PasswordlessSettings{
+			OTPDuration: time.Minute,
+			OTPAttempts: 10,
+			AutoCreate:  true,
+		},
+	}
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	var body string
+
+	u := Usecase{
+		Storage:    fakeStorage{user: user},
+		attempts:   memory.NewAttemptsStorage(),
+		challenges: memory.NewChallengeStorage(),
+		mailer:     fakeMailer{body: &body},
+	}
+
+	if err := u.SendOTP(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	code := regexp.MustCompile(`\d{6}`).FindString(body)
+
+	for i := 0; i < cfg.Lockout.AccountThreshold; i++ {
+		_, _ = u.SigninWithOTP(ctx, cfg, "wrong", "browser", "127.0.0.1")
+	}
+
+	if _, err := u.SigninWithOTP(ctx, cfg, code, "browser", "127.0.0.1"); !errors.Is(err, ErrTemporarilyLocked) {
+		t.Errorf("Expected %v, got %v", ErrTemporarilyLocked, err)
+	}
+
+	// the account is locked, other ips are rejected too
+	if err := u.SendOTP(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	code = regexp.MustCompile(`\d{6}`).FindString(body)
+
+	if _, err := u.SigninWithOTP(ctx, cfg, code, "browser", "10.0.0.1"); !errors.Is(err, ErrTemporarilyLocked) {
+		t.Errorf("Expected %v, got %v", ErrTemporarilyLocked, err)
+	}
+
+	// the ip is locked for other accounts after its threshold
+	for i := 0; i < cfg.Lockout.IPThreshold; i++ {
+		if err := u.SendOTP(ctx, cfg, fmt.Sprintf("unknown%d@mail.ru", i), "browser"); err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = u.SigninWithOTP(ctx, cfg, "wrong", "browser", "10.0.0.2")
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(user.Email)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.SendOTP(ctx, cfg, user.Email, "browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	code = regexp.MustCompile(`\d{6}`).FindString(body)
+
+	if _, err := u.SigninWithOTP(ctx, cfg, code, "browser", "10.0.0.2"); !errors.Is(err, ErrTemporarilyLocked) {
+		t.Errorf("Expected %v, got %v", ErrTemporarilyLocked, err)
+	}
+}
+
+func magicLinkToken(t *testing.T, body string) string {
+	t.Helper()
+
+	link, err := url.Parse(regexp.MustCompile(`http\S+`).FindString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return link.Query().Get("token")
+}
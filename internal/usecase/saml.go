@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/sso"
+	"github.com/degeboman/gas/internal/storage"
+	"strings"
+	"time"
+)
+
+var ErrSAMLDisabled = errors.New("saml sign in is not enabled")
+
+type SAMLProvider interface {
+	Metadata() ([]byte, error)
+	AuthnRequest(ctx context.Context, relayState string) (string, string, error)
+	ParseResponse(ctx context.Context, samlResponse string, requestIDs []string) (sso.Assertion, error)
+}
+
+// SAMLMetadata is the service provider metadata the identity provider is configured with
+func (u Usecase) SAMLMetadata() ([]byte, error) {
+	const op = "usecase.saml.SAMLMetadata"
+
+	if u.saml == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrSAMLDisabled)
+	}
+
+	metadata, err := u.saml.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return metadata, nil
+}
+
+// BeginSAMLLogin returns the url of the identity provider the user is redirected to,
+// the relay state sent along is used to find the request id when the response comes back
+func (u Usecase) BeginSAMLLogin(ctx context.Context, cfg config.Config) (string, error) {
+	const op = "usecase.saml.BeginSAMLLogin"
+
+	if u.saml == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrSAMLDisabled)
+	}
+
+	relayState, err := randomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	redirectURL, requestID, err := u.saml.AuthnRequest(ctx, relayState)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = u.challenges.SaveChallenge(ctx, samlRequestKey(relayState), []byte(requestID), cfg.SAML.RequestDuration)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return redirectURL, nil
+}
+
+// FinishSAMLLogin validates the response posted to the acs endpoint and signs in the user it asserts
+func (u Usecase) FinishSAMLLogin(ctx context.Context, cfg config.Config, samlResponse, relayState string) (tokens Tokens, err error) {
+	const op = "usecase.saml.FinishSAMLLogin"
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, "", err) }()
+
+	if u.saml == nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, ErrSAMLDisabled)
+	}
+
+	var requestIDs []string
+
+	if relayState != "" {
+		requestID, err := u.challenges.TakeChallenge(ctx, samlRequestKey(relayState))
+		if err != nil && !cfg.SAML.AllowIDPInitiated {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err == nil {
+			requestIDs = append(requestIDs, string(requestID))
+		}
+	}
+
+	assertion, err := u.saml.ParseResponse(ctx, samlResponse, requestIDs)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.useAssertion(ctx, assertion); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email, userInfo, roles := mapAssertion(cfg.SAML, assertion)
+	if !isValidEmail(email) {
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("assertion has no valid email"))
+	}
+
+	user, err := u.cacheExternalUser(ctx, email, userInfo, roles, cfg.SAML.GroupRoles)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err = u.firstFactorTokens(ctx, cfg, email, user)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// useAssertion remembers the id of the assertion while it is valid, so that a response captured on the way
+// can't be posted again, the request id does not protect idp initiated sign in
+func (u Usecase) useAssertion(ctx context.Context, assertion sso.Assertion) error {
+	if assertion.ID == "" {
+		return errors.New("assertion has no id")
+	}
+
+	ttl := time.Until(assertion.ExpiresAt)
+	if ttl <= 0 {
+		return errors.New("assertion has expired")
+	}
+
+	err := u.challenges.SaveChallengeOnce(ctx, samlAssertionKey(assertion.ID), []byte(assertion.ID), ttl)
+	if errors.Is(err, storage.ErrChallengeExists) {
+		return errors.New("assertion is already used")
+	}
+
+	return err
+}
+
+// mapAssertion returns the email, user info and roles of the asserted user
+func mapAssertion(settings config.SAMLSettings, assertion sso.Assertion) (string, map[string]interface{}, []string) {
+	email := assertion.NameID
+	if settings.EmailAttribute != "" {
+		email = first(assertion.Attributes[settings.EmailAttribute])
+	}
+
+	userInfo := make(map[string]interface{}, len(settings.Attributes))
+
+	for attribute, field := range settings.Attributes {
+		if value := first(assertion.Attributes[attribute]); value != "" {
+			userInfo[field] = value
+		}
+	}
+
+	roles := []string{}
+
+	for _, group := range assertion.Attributes[settings.GroupAttribute] {
+		for name, role := range settings.GroupRoles {
+			if strings.EqualFold(group, name) {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	return email, userInfo, roles
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func samlRequestKey(relayState string) string {
+	return "saml:" + hashSecret(relayState)
+}
+
+func samlAssertionKey(id string) string {
+	return "saml-assertion:" + hashSecret(id)
+}
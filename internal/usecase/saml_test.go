@@ -0,0 +1,264 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/sso"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func (s fakeStorage) UpdateUserInfo(_ context.Context, _ string, userInfo interface{}) error {
+	s.user.UserInfo = userInfo
+	return nil
+}
+
+func (s fakeStorage) SetRoles(_ context.Context, _ string, roles []string) error {
+	s.user.Roles = roles
+	return nil
+}
+
+type fakeServiceProviders struct {
+	metadata *saml.EntityDescriptor
+}
+
+func (p fakeServiceProviders) GetServiceProvider(_ *http.Request, _ string) (*saml.EntityDescriptor, error) {
+	return p.metadata, nil
+}
+
+func selfSignedCertificate(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, certificate
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSAMLLogin(t *testing.T) {
+	ctx := context.Background()
+
+	idpKey, idpCertificate := selfSignedCertificate(t, "idp")
+	spKey, spCertificate := selfSignedCertificate(t, "gas")
+
+	idpMetadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	idpSSOURL, _ := url.Parse("https://idp.example.com/sso")
+
+	idp := &saml.IdentityProvider{
+		Key:         idpKey,
+		Certificate: idpCertificate,
+		MetadataURL: *idpMetadataURL,
+		SSOURL:      *idpSSOURL,
+	}
+
+	idpMetadata, err := xml.Marshal(idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: 7 * 24 * time.Hour,
+		},
+		SAML: config.SAMLSettings{
+			MetadataURL: "http://localhost:2023/api/v1/auth/saml/metadata",
+			ACSURL:      "http://localhost:2023/api/v1/auth/saml/acs",
+			CertificateFile: writeFile(t, "gas.crt", pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: spCertificate.Raw,
+			})),
+			KeyFile: writeFile(t, "gas.key", pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(spKey),
+			})),
+			IDPMetadataFile: writeFile(t, "idp.xml", idpMetadata),
+			RequestDuration: time.Minute,
+			Attributes:      map[string]string{"cn": "name"},
+			GroupAttribute:  "eduPersonAffiliation",
+			GroupRoles:      map[string]string{"Admins": "admin"},
+		},
+	}
+
+	sp, err := sso.New(cfg.SAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spMetadata, err := sp.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spDescriptor saml.EntityDescriptor
+	if err := xml.Unmarshal(spMetadata, &spDescriptor); err != nil {
+		t.Fatal(err)
+	}
+
+	idp.ServiceProviderProvider = fakeServiceProviders{metadata: &spDescriptor}
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	u := Usecase{
+		Storage:    fakeStorage{user: user},
+		attempts:   memory.NewAttemptsStorage(),
+		challenges: memory.NewChallengeStorage(),
+		saml:       sp,
+	}
+
+	// respond does what the identity provider does after the user signs in there
+	respond := func(redirectURL string) (string, string) {
+		r, err := http.NewRequest(http.MethodGet, redirectURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := saml.NewIdpAuthnRequest(idp, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := req.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		err = saml.DefaultAssertionMaker{}.MakeAssertion(req, &saml.Session{
+			ID:             "session",
+			CreateTime:     time.Now(),
+			ExpireTime:     time.Now().Add(time.Hour),
+			NameID:         user.Email,
+			UserCommonName: "Rupych Man",
+			Groups:         []string{"admins", "guests"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := req.MakeAssertionEl(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := req.MakeResponse(); err != nil {
+			t.Fatal(err)
+		}
+
+		doc := etree.NewDocument()
+		doc.SetRoot(req.ResponseEl)
+
+		response, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return base64.StdEncoding.EncodeToString(response), r.URL.Query().Get("RelayState")
+	}
+
+	redirectURL, err := u.BeginSAMLLogin(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, relayState := respond(redirectURL)
+
+	tokens, err := u.FinishSAMLLogin(ctx, cfg, response, relayState)
+	if err != nil {
+		t.Fatalf("Expected sign in to succeed, got %s", err)
+	}
+
+	if tokens.Access == "" || tokens.Refresh == "" {
+		t.Error("Expected access and refresh tokens")
+	}
+
+	if !reflect.DeepEqual(user.Roles, []string{"admin"}) {
+		t.Errorf("Expected %v, got %v", []string{"admin"}, user.Roles)
+	}
+
+	expectedUserInfo := map[string]interface{}{"name": "Rupych Man"}
+	if !reflect.DeepEqual(user.UserInfo, expectedUserInfo) {
+		t.Errorf("Expected %v, got %v", expectedUserInfo, user.UserInfo)
+	}
+
+	if _, err := u.FinishSAMLLogin(ctx, cfg, response, relayState); err == nil {
+		t.Error("Expected the replayed response to be rejected")
+	}
+
+	// without the request id only the assertion id tells the replay apart
+	idpInitiated := cfg
+	idpInitiated.SAML.AllowIDPInitiated = true
+
+	if u.saml, err = sso.New(idpInitiated.SAML); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.FinishSAMLLogin(ctx, idpInitiated, response, ""); err == nil {
+		t.Error("Expected the replayed response to be rejected when idp initiated sign in is allowed")
+	}
+
+	u.saml = sp
+
+	// the response is signed by a key the identity provider metadata does not have
+	idp.Key, idp.Certificate = selfSignedCertificate(t, "intruder")
+
+	redirectURL, err = u.BeginSAMLLogin(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, relayState = respond(redirectURL)
+
+	if _, err := u.FinishSAMLLogin(ctx, cfg, response, relayState); err == nil {
+		t.Error("Expected the response signed by an unknown key to be rejected")
+	}
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"strings"
+	"time"
+)
+
+func (u Usecase) CreateServiceAccount(ctx context.Context, name, description string, roles []string) (id string, err error) {
+	const op = "usecase.serviceaccounts.CreateServiceAccount"
+
+	defer func() { u.audit(ctx, constant.AuditServiceAccountCreate, name, err) }()
+
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("%s: %w", op, errors.New("name is required"))
+	}
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		id, err = u.Storage.CreateServiceAccount(ctx, storage.ServiceAccount{
+			Name:        name,
+			Description: description,
+			Roles:       roles,
+			CreatedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventServiceAccountCreated, map[string]interface{}{"id": id, "name": name})
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (u Usecase) ServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error) {
+	const op = "usecase.serviceaccounts.ServiceAccounts"
+
+	accounts, err := u.Storage.ServiceAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accounts, nil
+}
+
+func (u Usecase) ServiceAccount(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	const op = "usecase.serviceaccounts.ServiceAccount"
+
+	account, err := u.Storage.ServiceAccountByID(ctx, id)
+	if err != nil {
+		return storage.ServiceAccount{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return account, nil
+}
+
+func (u Usecase) DeleteServiceAccount(ctx context.Context, id string) (err error) {
+	const op = "usecase.serviceaccounts.DeleteServiceAccount"
+
+	defer func() { u.audit(ctx, constant.AuditServiceAccountDelete, id, err) }()
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.DeleteServiceAccount(ctx, id); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventServiceAccountDeleted, map[string]interface{}{"id": id})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/oauth"
+	"github.com/degeboman/gas/internal/storage"
+	"golang.org/x/oauth2"
+	"time"
+)
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// oauthState is kept in the challenge storage while the user is at the provider
+type oauthState struct {
+	Provider    string `json:"provider"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	BindingHash string `json:"binding_hash"`
+}
+
+// BeginSocialLogin returns the url of the provider the user is redirected to
+func (u Usecase) BeginSocialLogin(ctx context.Context, cfg config.Config, providerName, binding string) (string, error) {
+	const op = "usecase.social.BeginSocialLogin"
+
+	provider, ok := u.providers[providerName]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, ErrUnknownProvider)
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := oauthState{
+		Provider:    providerName,
+		Nonce:       nonce,
+		Verifier:    oauth2.GenerateVerifier(),
+		BindingHash: hashSecret(binding),
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, s.Nonce, s.Verifier)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.challenges.SaveChallenge(ctx, oauthStateKey(state), data, cfg.OAuth.StateDuration); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return authURL, nil
+}
+
+// FinishSocialLogin signs in the user the provider account is linked to,
+// the account is linked or created by the verified email when it is allowed
+func (u Usecase) FinishSocialLogin(ctx context.Context, cfg config.Config, providerName, code, state, binding string) (tokens Tokens, err error) {
+	const op = "usecase.social.FinishSocialLogin"
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, "", err) }()
+
+	provider, ok := u.providers[providerName]
+	if !ok {
+		return Tokens{}, fmt.Errorf("%s: %w", op, ErrUnknownProvider)
+	}
+
+	data, err := u.challenges.TakeChallenge(ctx, oauthStateKey(state))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var s oauthState
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if s.Provider != providerName || !sameSecret(s.BindingHash, binding) {
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("state was issued for another sign in"))
+	}
+
+	identity, err := provider.Exchange(ctx, code, s.Nonce, s.Verifier)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.linkedUser(ctx, cfg, providerName, identity)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the identity may have been linked just now, the token carries the linked identities
+	if user, err = u.Storage.UserByID(ctx, user.ID); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err = u.firstFactorTokens(ctx, cfg, user.Email, user)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// linkedUser finds the user linked to the identity, or links the identity to the user with the same email
+// when the provider has verified that the email belongs to its account
+func (u Usecase) linkedUser(ctx context.Context, cfg config.Config, providerName string, identity oauth.Identity) (storage.User, error) {
+	user, err := u.Storage.UserByIdentity(ctx, providerName, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return storage.User{}, err
+	}
+
+	if !identity.EmailVerified {
+		return storage.User{}, errors.New("provider account has no verified email")
+	}
+
+	user, err = u.Storage.UserByEmail(ctx, identity.Email)
+	if err == nil {
+		if !cfg.OAuth.LinkByEmail {
+			return storage.User{}, storage.ErrEmailTaken
+		}
+
+		return user, u.linkIdentity(ctx, user.ID, providerName, identity)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return storage.User{}, err
+	}
+
+	if !cfg.OAuth.AutoCreate {
+		return storage.User{}, errors.New("no account is linked to the provider account")
+	}
+
+	// nobody knows the password, the account is used only with the provider
+	password, err := randomString(32)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	id, err := u.CreateUser(ctx, identity.Email, password, nil)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	if err := u.linkIdentity(ctx, id, providerName, identity); err != nil {
+		return storage.User{}, err
+	}
+
+	return u.Storage.UserByID(ctx, id)
+}
+
+func (u Usecase) linkIdentity(ctx context.Context, id, providerName string, identity oauth.Identity) error {
+	return u.Storage.AddIdentity(ctx, id, storage.Identity{
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now().UTC(),
+	})
+}
+
+func oauthStateKey(state string) string {
+	return "oauth:" + hashSecret(state)
+}
@@ -0,0 +1,278 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/oauth"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/dgrijalva/jwt-go/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func (s fakeStorage) UserByIdentity(_ context.Context, provider, subject string) (storage.User, error) {
+	for _, identity := range s.user.Identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return *s.user, nil
+		}
+	}
+
+	return storage.User{}, storage.ErrNotFound
+}
+
+func (s fakeStorage) AddIdentity(_ context.Context, _ string, identity storage.Identity) error {
+	s.user.Identities = append(s.user.Identities, identity)
+	return nil
+}
+
+// fakeOIDCProvider implements discovery, jwks and the token endpoint,
+// the user is signed in with the subject and email set before the authorization
+type fakeOIDCProvider struct {
+	*httptest.Server
+
+	key      *rsa.PrivateKey
+	clientID string
+	subject  string
+	email    string
+	// emailUnverified makes the provider report the email as not verified
+	emailUnverified bool
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+type authorization struct {
+	nonce         string
+	codeChallenge string
+}
+
+func newFakeOIDCProvider(t *testing.T, clientID string) *fakeOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &fakeOIDCProvider{
+		key:      key,
+		clientID: clientID,
+		codes:    make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   b64(key.PublicKey.N.Bytes()),
+				"e":   b64(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", p.token)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+// authorize is what the provider does after the user signs in at the page of authURL
+func (p *fakeOIDCProvider) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+
+	code = primitive.NewObjectID().Hex()
+
+	p.mu.Lock()
+	p.codes[code] = authorization{nonce: q.Get("nonce"), codeChallenge: q.Get("code_challenge")}
+	p.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+func (p *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	auth, ok := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.URL,
+		"aud":            p.clientID,
+		"sub":            p.subject,
+		"email":          p.email,
+		"email_verified": !p.emailUnverified,
+		"nonce":          auth.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = "test"
+
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSocialLogin(t *testing.T) {
+	ctx := context.Background()
+
+	provider := newFakeOIDCProvider(t, "gas")
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: 7 * 24 * time.Hour,
+		},
+		OAuth: config.OAuthSettings{
+			StateDuration: time.Minute,
+			LinkByEmail:   true,
+			Providers: map[string]config.OAuthProvider{
+				"fake": {
+					ClientID:    "gas",
+					RedirectURL: "http://localhost:2023/api/v1/auth/oauth/fake/callback",
+					Issuer:      provider.URL,
+				},
+			},
+		},
+	}
+
+	providers, err := oauth.NewProviders(cfg.OAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	u := Usecase{
+		Storage:    fakeStorage{user: user},
+		challenges: memory.NewChallengeStorage(),
+		providers:  providers,
+	}
+
+	provider.subject = "fake-user"
+	provider.email = user.Email
+
+	signin := func(beginBinding, finishBinding string) (Tokens, error) {
+		authURL, err := u.BeginSocialLogin(ctx, cfg, "fake", beginBinding)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, state := provider.authorize(t, authURL)
+
+		return u.FinishSocialLogin(ctx, cfg, "fake", code, state, finishBinding)
+	}
+
+	if _, err := u.BeginSocialLogin(ctx, cfg, "unknown", "browser"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected %v, got %v", ErrUnknownProvider, err)
+	}
+
+	if _, err := signin("browser", "another"); err == nil {
+		t.Error("Expected the callback in another browser to be rejected")
+	}
+
+	provider.emailUnverified = true
+
+	if _, err := signin("browser", "browser"); err == nil {
+		t.Error("Expected the account with the email not verified by the provider not to be linked")
+	}
+
+	provider.emailUnverified = false
+
+	cfg.OAuth.LinkByEmail = false
+
+	if _, err := signin("browser", "browser"); !errors.Is(err, storage.ErrEmailTaken) {
+		t.Errorf("Expected %v without linking by email, got %v", storage.ErrEmailTaken, err)
+	}
+
+	cfg.OAuth.LinkByEmail = true
+
+	tokens, err := signin("browser", "browser")
+	if err != nil {
+		t.Fatalf("Expected sign in to succeed, got %s", err)
+	}
+
+	if tokens.Access == "" || tokens.Refresh == "" {
+		t.Error("Expected access and refresh tokens")
+	}
+
+	if len(user.Identities) != 1 || user.Identities[0].Subject != provider.subject {
+		t.Fatalf("Expected the identity to be linked, got %v", user.Identities)
+	}
+
+	// the linked identity is found by the subject even after the email changes at the provider
+	provider.email = "changed@mail.ru"
+
+	if _, err := signin("browser", "browser"); err != nil {
+		t.Errorf("Expected sign in with the linked identity to succeed, got %s", err)
+	}
+
+	provider.subject = "another-user"
+
+	if _, err := signin("browser", "browser"); err == nil {
+		t.Error("Expected the unknown account to be rejected without auto creation")
+	}
+}
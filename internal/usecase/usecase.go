@@ -1,99 +1,329 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/degeboman/gas/constant"
 	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/audit"
+	"github.com/degeboman/gas/internal/lib/cache"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/lib/jwks"
+	"github.com/degeboman/gas/internal/lib/mailer"
+	"github.com/degeboman/gas/internal/lib/oauth"
 	"github.com/degeboman/gas/internal/storage"
-	"github.com/degeboman/gas/internal/storage/mongodb"
 	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/go-jose/go-jose/v3"
 	"golang.org/x/crypto/bcrypt"
 	"net/mail"
+	"strings"
 	"time"
 )
 
+var (
+	ErrEmailNotValid    = errors.New("email is not valid")
+	ErrPasswordTooShort = errors.New("password is too short")
+)
+
 //TODO разбить на отдельные файлы как у handler
 
 type Usecase struct {
 	storage.Storage
+	attempts    storage.AttemptsStorage
+	challenges  storage.ChallengeStorage
+	revocations storage.RevocationStorage
+	keys        *jwks.KeySet
+	mailer      mailer.Sender
+	providers   map[string]oauth.Provider
+	directory   Directory
+	saml        SAMLProvider
+	events      events.Publisher
+	auditLog    *audit.Log
+	// tokens keeps the claims of the verified tokens by the hash of the token, the revocation is still
+	// checked on every verification
+	tokens *cache.Cache
 }
 
-func (u Usecase) RefreshToken(cfg config.Config, refreshToken string) (string, error) {
+// RefreshToken issues a new access token with the current user info of the refresh token owner
+func (u Usecase) RefreshToken(ctx context.Context, cfg config.Config, refreshToken string) (string, error) {
 	const op = "usecase.usecase.RefreshToken"
 
-	_, err := parseToken(cfg.SigningKey, refreshToken)
+	claims, err := u.verifyJWT(ctx, cfg.SigningKey, refreshToken)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.Storage.UserByID(ctx, claims.UserID())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the refresh token outlives a lock of the user, the state is checked again on every refresh
+	if err := checkUserState(user); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, err := u.signToken(cfg, userClaims(user), cfg.AccessDuration)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, nil
+}
+
+// SignOut revokes the refresh token, the access tokens issued by it live until they expire
+func (u Usecase) SignOut(ctx context.Context, cfg config.Config, refreshToken string) (err error) {
+	const op = "usecase.usecase.SignOut"
+
+	var userID string
+
+	defer func() { u.audit(audit.WithActor(ctx, userID, ""), constant.AuditSignOut, userID, err) }()
+
+	claims, err := u.verifyJWT(ctx, cfg.SigningKey, refreshToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID = claims.UserID()
+
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return fmt.Errorf("%s: %w", op, errors.New("token can not be revoked"))
+	}
+
+	if err := u.revocations.Revoke(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	// TODO get user info
+	u.tokens.Delete(hashSecret(refreshToken))
 
-	//accessToken, err := generateToken(userInfo, cfg.AccessDuration, cfg.SigningKey)
-	//if err != nil {
-	//	// TODO handling error with defer
-	//	return "", fmt.Errorf("%s: %w", op, err)
-	//}
+	if err := u.publish(ctx, constant.EventSessionRevoked, map[string]interface{}{
+		"user_id":  claims.UserID(),
+		"token_id": claims.ID,
+	}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// JWKS is the public key set the clients verify access tokens by, it is empty when the tokens
+// are signed with the shared key
+func (u Usecase) JWKS() jose.JSONWebKeySet {
+	if u.keys == nil {
+		return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	}
+
+	return u.keys.Public()
+}
+
+// VerifyToken accepts both access tokens and api keys, the claims have the same shape
+func (u Usecase) VerifyToken(ctx context.Context, signingKey []byte, token string) (interface{}, error) {
+	if strings.HasPrefix(token, constant.APIKeyPrefix) {
+		return u.verifyAPIKey(ctx, token)
+	}
 
-	return "accessToken", nil
+	return u.verifyJWT(ctx, signingKey, token)
 }
 
-func (u Usecase) VerifyToken(signingKey []byte, token string) (interface{}, error) {
-	return parseToken(signingKey, token)
+// verifyJWT parses the token and rejects it when it has been revoked by sign out
+func (u Usecase) verifyJWT(ctx context.Context, signingKey []byte, token string) (*UserClaims, error) {
+	claims, err := u.parseJWT(signingKey, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" {
+		return claims, nil
+	}
+
+	revoked, err := u.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New("token is revoked")
+	}
+
+	return claims, nil
 }
 
-func (u Usecase) Signin(cfg config.Config, email, password string) (access string, refresh string, err error) {
+// parseJWT checks the signature of the token once and takes the claims from the cache until the token expires,
+// the claims are signed into the token so a change of the user does not make them stale
+func (u Usecase) parseJWT(signingKey []byte, token string) (*UserClaims, error) {
+	key := hashSecret(token)
+
+	if claims, ok := u.tokens.Get(key); ok {
+		return claims.(*UserClaims), nil
+	}
+
+	keyfunc := hmacKeyfunc(signingKey)
+
+	if u.keys != nil {
+		// the tokens signed with the shared key before the key set was configured stay valid
+		keyfunc = func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+				return signingKey, nil
+			}
+
+			return u.keys.Keyfunc(token)
+		}
+	}
+
+	claims, err := parseClaims(token, keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	// a token is never taken from the cache after it expires, the tokens without expiration are not cached
+	if claims.ExpiresAt != nil {
+		if ttl := time.Until(claims.ExpiresAt.Time); ttl > 0 {
+			u.tokens.SetWithTTL(key, claims, ttl)
+		}
+	}
+
+	return claims, nil
+}
+
+// Tokens are returned by sign in, either the access and refresh pair or the mfa challenge token
+// when the user has two-factor authentication enabled
+type Tokens struct {
+	Access  string
+	Refresh string
+	MFA     string
+	// MFAMethod is the second factor the mfa challenge is completed with
+	MFAMethod string
+}
+
+func (u Usecase) Signin(ctx context.Context, cfg config.Config, email, password, ip string) (tokens Tokens, err error) {
 	const op = "usecase.usecase.Signin"
 
-	userInfo, err := u.Storage.UserByEmail(email)
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, email, err) }()
+
+	failures, err := u.checkLockout(ctx, email, ip)
 	if err != nil {
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := comparePassword(userInfo.(map[string]interface{})["password"].(string), password); err != nil {
-		return "", "", fmt.Errorf("%s: %w", op, errors.New("password or email is not correct"))
+	if err := sleep(ctx, loginDelay(cfg.Lockout, failures)); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	accessToken, err := generateToken(userInfo, cfg.AccessDuration, cfg.SigningKey)
+	if u.directory != nil {
+		return u.directorySignin(ctx, cfg, email, password, ip)
+	}
+
+	user, err := u.Storage.UserByEmail(ctx, email)
 	if err != nil {
-		// TODO handling error with defer
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		if err := u.registerFailure(ctx, cfg, email, ip, false); err != nil {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	refreshToken, err := generateToken(userInfo, cfg.RefreshDuration, cfg.SigningKey)
+	if err := comparePassword(user.PasswordHash, password); err != nil {
+		if err := u.registerFailure(ctx, cfg, email, ip, true); err != nil {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("password or email is not correct"))
+	}
+
+	if err := u.attempts.ResetAttempts(ctx, accountAttemptsKey(email)); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err = u.firstFactorTokens(ctx, cfg, email, user)
 	if err != nil {
-		// TODO handling error with defer
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return accessToken, refreshToken, nil
+	return tokens, nil
 }
 
-func (u Usecase) CreateUser(email, password string, userInfo interface{}) (string, error) {
+func (u Usecase) CreateUser(ctx context.Context, email, password string, userInfo interface{}) (id string, err error) {
 	const op = "usecase.usecase.CreateUser"
 
+	defer func() { u.audit(ctx, constant.AuditUserCreate, email, err) }()
+
 	if !isValidEmail(email) {
-		return "0", fmt.Errorf("%s: %w", op, errors.New("email is not valid"))
+		return "0", fmt.Errorf("%s: %w", op, ErrEmailNotValid)
 	}
 
 	if len(password) < 6 {
-		return "0", fmt.Errorf("%s: %w", op, errors.New("password is too short"))
+		return "0", fmt.Errorf("%s: %w", op, ErrPasswordTooShort)
 	}
 
-	if err := u.Storage.DoesEmailExist(email); err != nil {
+	if err := u.Storage.DoesEmailExist(ctx, email); err != nil {
 		return "0", fmt.Errorf("%s: %w", op, err)
 	}
 
 	passwordHash := hashPassword(password)
 
-	return u.Storage.CreateUser(email, passwordHash, userInfo)
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		if id, err = u.Storage.CreateUser(ctx, email, passwordHash, userInfo); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserCreated, map[string]interface{}{
+			"id":    id,
+			"email": email,
+		})
+	})
+	if err != nil {
+		return "0", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
 }
 
-func New(storage *mongodb.UsersStorage) Usecase {
+func New(
+	storage storage.Storage,
+	attempts storage.AttemptsStorage,
+	challenges storage.ChallengeStorage,
+	revocations storage.RevocationStorage,
+	keys *jwks.KeySet,
+	mailSender mailer.Sender,
+	providers map[string]oauth.Provider,
+	directory Directory,
+	saml SAMLProvider,
+	publisher events.Publisher,
+	auditLog *audit.Log,
+	tokens *cache.Cache,
+) Usecase {
 	return Usecase{
-		Storage: storage,
+		Storage:     storage,
+		attempts:    attempts,
+		challenges:  challenges,
+		revocations: revocations,
+		keys:        keys,
+		mailer:      mailSender,
+		providers:   providers,
+		directory:   directory,
+		saml:        saml,
+		events:      publisher,
+		auditLog:    auditLog,
+		tokens:      tokens,
 	}
 }
 
+// checkUserState refuses sign in of disabled users and users that must reset the password
+func checkUserState(user storage.User) error {
+	if user.Locked {
+		return errors.New("user is disabled")
+	}
+
+	if user.PasswordResetRequired {
+		return errors.New("password reset is required")
+	}
+
+	return nil
+}
+
 func isValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
@@ -108,24 +338,165 @@ func comparePassword(hashed string, normal string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(normal))
 }
 
+// firstFactorTokens completes a sign in by the first factor, users with two-factor authentication get
+// the mfa challenge token instead of the access and refresh pair
+func (u Usecase) firstFactorTokens(ctx context.Context, cfg config.Config, email string, user storage.User) (Tokens, error) {
+	if err := checkUserState(user); err != nil {
+		return Tokens{}, err
+	}
+
+	if user.MFAEnabled {
+		mfaToken, err := generatePurposeToken(cfg.SigningKey, mfaPurpose, email, cfg.MFA.ChallengeDuration)
+		if err != nil {
+			return Tokens{}, err
+		}
+
+		return Tokens{MFA: mfaToken, MFAMethod: user.MFAMethod}, nil
+	}
+
+	return u.issueTokens(ctx, cfg, user)
+}
+
+// issueTokens generates the access and refresh pair for the user
+func (u Usecase) issueTokens(ctx context.Context, cfg config.Config, user storage.User) (Tokens, error) {
+	claims := userClaims(user)
+
+	accessToken, err := u.signToken(cfg, claims, cfg.AccessDuration)
+	if err != nil {
+		// TODO handling error with defer
+		return Tokens{}, err
+	}
+
+	refreshToken, err := u.signToken(cfg, claims, cfg.RefreshDuration)
+	if err != nil {
+		// TODO handling error with defer
+		return Tokens{}, err
+	}
+
+	if err := u.publish(ctx, constant.EventUserSignedIn, map[string]interface{}{
+		"id":    user.ID,
+		"email": user.Email,
+	}); err != nil {
+		return Tokens{}, err
+	}
+
+	// nobody is authenticated yet, the actor of the sign in is the user
+	u.audit(audit.WithActor(ctx, user.ID, ""), constant.AuditSignIn, user.Email, nil)
+
+	return Tokens{
+		Access:  accessToken,
+		Refresh: refreshToken,
+	}, nil
+}
+
+// userClaims is the token payload of the user, it is readable by anyone, so the secrets are left out.
+// The keys are the fields of the user document the clients of the tokens read
+func userClaims(user storage.User) map[string]interface{} {
+	return map[string]interface{}{
+		"_id":                     user.ID,
+		"email":                   user.Email,
+		"user_info":               user.UserInfo,
+		"roles":                   user.Roles,
+		"verified":                user.Verified,
+		"locked":                  user.Locked,
+		"password_reset_required": user.PasswordResetRequired,
+		"mfa_enabled":             user.MFAEnabled,
+		"identities":              user.Identities,
+		"created_at":              user.CreatedAt,
+	}
+}
+
+// signToken signs the user claims with the key set when gas has one, so the clients can verify
+// the tokens by the published keys, and with the shared signing key otherwise
+func (u Usecase) signToken(cfg config.Config, userInfo interface{}, duration time.Duration) (string, error) {
+	if u.keys == nil {
+		return generateToken(userInfo, duration, cfg.SigningKey)
+	}
+
+	claims, err := newUserClaims(userInfo, duration)
+	if err != nil {
+		return "", err
+	}
+
+	return u.keys.Sign(claims)
+}
+
 func generateToken(userInfo interface{}, duration time.Duration, signingKey []byte) (token string, err error) {
-	claims := UserClaims{
+	claims, err := newUserClaims(userInfo, duration)
+	if err != nil {
+		return "", err
+	}
+
+	ss := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return ss.SignedString(signingKey)
+}
+
+func newUserClaims(userInfo interface{}, duration time.Duration) (UserClaims, error) {
+	// the id lets a single token be revoked
+	id, err := randomString(16)
+	if err != nil {
+		return UserClaims{}, err
+	}
+
+	return UserClaims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: jwt.At(time.Now().Add(duration * time.Second)),
+			ExpiresAt: jwt.At(time.Now().Add(duration)),
+			ID:        id,
 		},
 		UserInfo: userInfo,
+	}, nil
+}
+
+// generatePurposeToken issues a short-living token for a single purpose, e.g. unlocking an account,
+// the audience claim keeps it from being accepted as an access token by parseToken
+func generatePurposeToken(signingKey []byte, purpose, subject string, duration time.Duration) (string, error) {
+	claims := jwt.StandardClaims{
+		Audience:  jwt.ClaimStrings{purpose},
+		Subject:   subject,
+		ExpiresAt: jwt.At(time.Now().Add(duration)),
 	}
 
-	ss := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(signingKey)
+}
 
-	return ss.SignedString(signingKey)
+// parsePurposeToken returns the subject of a token issued by generatePurposeToken
+func parsePurposeToken(signingKey []byte, purpose, token string) (string, error) {
+	var claims jwt.StandardClaims
+
+	data, err := jwt.ParseWithClaims(token, &claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return signingKey, nil
+		}, jwt.WithAudience(purpose))
+	if err != nil {
+		return "", err
+	}
+
+	if !data.Valid {
+		return "", errors.New("token is not valid")
+	}
+
+	return claims.Subject, nil
 }
 
 func parseToken(key []byte, token string) (*UserClaims, error) {
-	data, err := jwt.ParseWithClaims(token, &UserClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return key, nil
-		})
+	return parseClaims(token, hmacKeyfunc(key))
+}
+
+// hmacKeyfunc accepts only the tokens signed with the shared key, it keeps a public key
+// from being used as an hmac secret
+func hmacKeyfunc(key []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key, nil
+	}
+}
+
+func parseClaims(token string, keyfunc jwt.Keyfunc) (*UserClaims, error) {
+	data, err := jwt.ParseWithClaims(token, &UserClaims{}, keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -147,3 +518,71 @@ type UserClaims struct {
 	jwt.StandardClaims
 	UserInfo interface{}
 }
+
+func (c *UserClaims) UserID() string {
+	id, _ := c.field("_id").(string)
+	return id
+}
+
+func (c *UserClaims) Email() string {
+	email, _ := c.field("email").(string)
+	return email
+}
+
+func (c *UserClaims) Roles() []string {
+	return c.strings("roles")
+}
+
+// APIKeyID is set when the claims come from an api key
+func (c *UserClaims) APIKeyID() string {
+	id, _ := c.field("api_key_id").(string)
+	return id
+}
+
+// ServiceAccount tells if the claims come from an api key of a service account, the user id is the account id then
+func (c *UserClaims) ServiceAccount() bool {
+	serviceAccount, _ := c.field("service_account").(bool)
+	return serviceAccount
+}
+
+// HasScope tells if the api key has the scope, access tokens are not limited by scopes
+func (c *UserClaims) HasScope(scope string) bool {
+	if c.APIKeyID() == "" {
+		return true
+	}
+
+	for _, s := range c.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scopes are the scopes of an api key, access tokens have none and are not limited by scopes
+func (c *UserClaims) Scopes() []string {
+	return c.strings("scopes")
+}
+
+func (c *UserClaims) strings(key string) []string {
+	values, _ := c.field(key).([]interface{})
+
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+func (c *UserClaims) field(key string) interface{} {
+	info, ok := c.UserInfo.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	return info[key]
+}
@@ -1,7 +1,17 @@
 package usecase
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/cache"
+	"github.com/degeboman/gas/internal/lib/jwks"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
 	"github.com/dgrijalva/jwt-go/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"testing"
 	"time"
 )
@@ -67,3 +77,130 @@ func Test_parseToken(t *testing.T) {
 		})
 	}
 }
+
+func TestSignOut(t *testing.T) {
+	var cfg config.Config
+
+	cfg.SigningKey = []byte("secret")
+	cfg.AccessDuration = time.Minute
+	cfg.RefreshDuration = 10 * time.Minute
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	// the refresh token is verified and cached by the refresh, the sign out must still reject it
+	u := Usecase{
+		Storage:     fakeStorage{user: user},
+		revocations: memory.NewRevocationStorage(),
+		tokens:      cache.New(10, time.Minute),
+	}
+
+	tokens, err := u.issueTokens(context.Background(), cfg, *user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access, err := u.RefreshToken(context.Background(), cfg, tokens.Refresh)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	claims, err := u.VerifyToken(context.Background(), cfg.SigningKey, access)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if id := claims.(*UserClaims).UserID(); id != user.ID {
+		t.Errorf("Expected %v, got %v", user.ID, id)
+	}
+
+	if err := u.SignOut(context.Background(), cfg, tokens.Refresh); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := u.tokens.Get(hashSecret(tokens.Refresh)); ok {
+		t.Errorf("Expected the revoked token to be dropped from the cache")
+	}
+
+	if _, err := u.RefreshToken(context.Background(), cfg, tokens.Refresh); err == nil {
+		t.Errorf("Expected the revoked token to be rejected, got nil")
+	}
+
+	// the access tokens issued earlier have their own ids
+	if _, err := u.VerifyToken(context.Background(), cfg.SigningKey, tokens.Access); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestKeySetTokens(t *testing.T) {
+	var cfg config.Config
+
+	cfg.SigningKey = []byte("secret")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := jwks.New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := Usecase{revocations: memory.NewRevocationStorage(), keys: keys}
+
+	info := map[string]interface{}{"_id": "1", "email": "rupychman@mail.ru"}
+
+	signed, err := u.signToken(cfg, info, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := generateToken(info, time.Minute, cfg.SigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an hmac token keyed by the public key must not pass for a token of the key set
+	publicDER := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	confused, err := generateToken(info, time.Minute, publicDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{
+			name:  "signed by the key set",
+			token: signed,
+			valid: true,
+		},
+		{
+			name:  "signed with the shared key",
+			token: shared,
+			valid: true,
+		},
+		{
+			name:  "hmac with the public key",
+			token: confused,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := u.VerifyToken(context.Background(), cfg.SigningKey, d.token)
+			if (err == nil) != d.valid {
+				t.Errorf("Expected valid %v, got %v", d.valid, err)
+			}
+		})
+	}
+
+	if n := len(u.JWKS().Keys); n != 1 {
+		t.Errorf("Expected %v, got %v", 1, n)
+	}
+}
@@ -0,0 +1,445 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"time"
+)
+
+var ErrPasskeyCloned = errors.New("passkey sign count did not grow, it may be cloned")
+
+// WebAuthnCeremony is returned by the begin steps, Options are passed to navigator.credentials on the client
+// and SessionID is sent back with the credential to the finish step
+type WebAuthnCeremony struct {
+	SessionID string
+	Options   interface{}
+}
+
+type webAuthnUser struct {
+	storage.User
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.ID)
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.Email
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	return u.Email
+}
+
+func (u webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.User.WebAuthnCredentials))
+
+	for _, c := range u.User.WebAuthnCredentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.ID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
+
+// BeginWebAuthnRegistration starts adding a passkey to the signed in user
+func (u Usecase) BeginWebAuthnRegistration(ctx context.Context, cfg config.Config, userID string) (WebAuthnCeremony, error) {
+	const op = "usecase.webauthn.BeginWebAuthnRegistration"
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.Storage.UserByID(ctx, userID)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	wUser := webAuthnUser{User: user}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.WebAuthnCredentials))
+	for _, c := range wUser.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := w.BeginRegistration(wUser,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessionID, err := u.saveWebAuthnSession(ctx, cfg, session)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return WebAuthnCeremony{
+		SessionID: sessionID,
+		Options:   creation,
+	}, nil
+}
+
+// FinishWebAuthnRegistration stores the passkey, with secondFactor the passkey becomes the second factor
+// required after the password instead of totp
+func (u Usecase) FinishWebAuthnRegistration(
+	ctx context.Context,
+	cfg config.Config,
+	userID, sessionID string,
+	credential []byte,
+	secondFactor bool,
+) (err error) {
+	const op = "usecase.webauthn.FinishWebAuthnRegistration"
+
+	defer func() {
+		if secondFactor {
+			u.audit(ctx, constant.AuditUserEnableMFA, userID, err)
+		}
+	}()
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := u.takeWebAuthnSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.Storage.UserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(credential))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	created, err := w.CreateCredential(webAuthnUser{User: user}, session, parsed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	transports := make([]string, 0, len(created.Transport))
+	for _, t := range created.Transport {
+		transports = append(transports, string(t))
+	}
+
+	err = u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.Storage.AddWebAuthnCredential(ctx, userID, storage.WebAuthnCredential{
+			ID:              created.ID,
+			PublicKey:       created.PublicKey,
+			AttestationType: created.AttestationType,
+			Transports:      transports,
+			AAGUID:          created.Authenticator.AAGUID,
+			SignCount:       created.Authenticator.SignCount,
+			CreatedAt:       time.Now().UTC(),
+		}); err != nil {
+			return err
+		}
+
+		if !secondFactor {
+			return nil
+		}
+
+		// the passkey is stored in the same transaction, so the factor asked for is always usable
+		if err := u.Storage.SetMFAMethod(ctx, userID, constant.MFAMethodWebAuthn); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, constant.EventUserMFAEnabled, map[string]interface{}{
+			"id":     userID,
+			"method": constant.MFAMethodWebAuthn,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// BeginWebAuthnLogin starts the passwordless sign in, without email any discoverable passkey is accepted
+func (u Usecase) BeginWebAuthnLogin(ctx context.Context, cfg config.Config, email string) (WebAuthnCeremony, error) {
+	const op = "usecase.webauthn.BeginWebAuthnLogin"
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+	)
+
+	if email == "" {
+		assertion, session, err = w.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	} else {
+		var user storage.User
+
+		user, err = u.Storage.UserByEmail(ctx, email)
+		if err != nil {
+			return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		assertion, session, err = w.BeginLogin(webAuthnUser{User: user},
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	}
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessionID, err := u.saveWebAuthnSession(ctx, cfg, session)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return WebAuthnCeremony{
+		SessionID: sessionID,
+		Options:   assertion,
+	}, nil
+}
+
+// FinishWebAuthnLogin completes the passwordless sign in, a verified passkey counts as both factors
+func (u Usecase) FinishWebAuthnLogin(ctx context.Context, cfg config.Config, sessionID string, credential []byte) (tokens Tokens, err error) {
+	const op = "usecase.webauthn.FinishWebAuthnLogin"
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, "", err) }()
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := u.takeWebAuthnSession(ctx, sessionID)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(credential))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		user      storage.User
+		validated *webauthn.Credential
+	)
+
+	if session.UserID == nil {
+		validated, err = w.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			user, err = u.Storage.UserByID(ctx, string(userHandle))
+			return webAuthnUser{User: user}, err
+		}, session, parsed)
+	} else {
+		user, err = u.Storage.UserByID(ctx, string(session.UserID))
+		if err != nil {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		validated, err = w.ValidateLogin(webAuthnUser{User: user}, session, parsed)
+	}
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err = u.completeWebAuthnLogin(ctx, cfg, user, validated)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// BeginWebAuthnMFA starts the second factor step of the sign in with a passkey of the user
+func (u Usecase) BeginWebAuthnMFA(ctx context.Context, cfg config.Config, mfaToken string) (WebAuthnCeremony, error) {
+	const op = "usecase.webauthn.BeginWebAuthnMFA"
+
+	email, err := parsePurposeToken(cfg.SigningKey, mfaPurpose, mfaToken)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ceremony, err := u.BeginWebAuthnLogin(ctx, cfg, email)
+	if err != nil {
+		return WebAuthnCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ceremony, nil
+}
+
+// FinishWebAuthnMFA completes the sign in started by Signin with a passkey instead of a totp code
+func (u Usecase) FinishWebAuthnMFA(ctx context.Context, cfg config.Config, mfaToken, sessionID string, credential []byte) (tokens Tokens, err error) {
+	const op = "usecase.webauthn.FinishWebAuthnMFA"
+
+	defer func() { u.auditFailure(ctx, constant.AuditSignIn, "", err) }()
+
+	email, err := parsePurposeToken(cfg.SigningKey, mfaPurpose, mfaToken)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := u.takeWebAuthnSession(ctx, sessionID)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := u.Storage.UserByEmail(ctx, email)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the session must be started for the user the mfa token was issued to
+	if !bytes.Equal(session.UserID, []byte(user.ID)) {
+		return Tokens{}, fmt.Errorf("%s: %w", op, errors.New("session does not belong to the user"))
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(credential))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	validated, err := w.ValidateLogin(webAuthnUser{User: user}, session, parsed)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err = u.completeWebAuthnLogin(ctx, cfg, user, validated)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// completeWebAuthnLogin refuses the passkey whose sign count did not grow, two copies of it may be in use.
+// The passkeys that always sign with zero don't have a counter and are not checked
+func (u Usecase) completeWebAuthnLogin(
+	ctx context.Context,
+	cfg config.Config,
+	user storage.User,
+	credential *webauthn.Credential,
+) (Tokens, error) {
+	if credential.Authenticator.CloneWarning {
+		return Tokens{}, ErrPasskeyCloned
+	}
+
+	if signCount := credential.Authenticator.SignCount; signCount != 0 {
+		err := u.Storage.UpdateWebAuthnSignCount(ctx, user.ID, credential.ID, signCount)
+		if errors.Is(err, storage.ErrSignCountUsed) {
+			return Tokens{}, ErrPasskeyCloned
+		}
+		if err != nil {
+			return Tokens{}, err
+		}
+	}
+
+	// the stored user has the sign count updated and the current state
+	user, err := u.Storage.UserByID(ctx, user.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := checkUserState(user); err != nil {
+		return Tokens{}, err
+	}
+
+	return u.issueTokens(ctx, cfg, user)
+}
+
+func (u Usecase) saveWebAuthnSession(ctx context.Context, cfg config.Config, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	sessionID := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := u.challenges.SaveChallenge(ctx, webAuthnChallengeKey(sessionID), data, cfg.WebAuthn.Timeout); err != nil {
+		return "", err
+	}
+
+	return sessionID, nil
+}
+
+func (u Usecase) takeWebAuthnSession(ctx context.Context, sessionID string) (webauthn.SessionData, error) {
+	data, err := u.challenges.TakeChallenge(ctx, webAuthnChallengeKey(sessionID))
+	if err != nil {
+		return webauthn.SessionData{}, err
+	}
+
+	var session webauthn.SessionData
+
+	if err := json.Unmarshal(data, &session); err != nil {
+		return webauthn.SessionData{}, err
+	}
+
+	return session, nil
+}
+
+func newWebAuthn(cfg config.Config) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login: webauthn.TimeoutConfig{
+				Enforce: true,
+				Timeout: cfg.WebAuthn.Timeout,
+			},
+			Registration: webauthn.TimeoutConfig{
+				Enforce: true,
+				Timeout: cfg.WebAuthn.Timeout,
+			},
+		},
+	})
+}
+
+func webAuthnChallengeKey(sessionID string) string {
+	return "webauthn:" + sessionID
+}
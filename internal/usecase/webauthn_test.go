@@ -0,0 +1,386 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/memory"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+// fakeStorage keeps a single user, the methods not used by webauthn panic through the nil interface
+type fakeStorage struct {
+	storage.Storage
+	user *storage.User
+}
+
+func (s fakeStorage) UserByEmail(_ context.Context, email string) (storage.User, error) {
+	if email != s.user.Email {
+		return storage.User{}, storage.ErrNotFound
+	}
+
+	return *s.user, nil
+}
+
+func (s fakeStorage) UserByID(_ context.Context, id string) (storage.User, error) {
+	if id != s.user.ID {
+		return storage.User{}, storage.ErrNotFound
+	}
+
+	return *s.user, nil
+}
+
+func (s fakeStorage) AddWebAuthnCredential(_ context.Context, _ string, credential storage.WebAuthnCredential) error {
+	s.user.WebAuthnCredentials = append(s.user.WebAuthnCredentials, credential)
+	return nil
+}
+
+func (s fakeStorage) UpdateWebAuthnSignCount(_ context.Context, _ string, credentialID []byte, signCount uint32) error {
+	for i, c := range s.user.WebAuthnCredentials {
+		if bytes.Equal(c.ID, credentialID) {
+			if signCount <= c.SignCount {
+				return storage.ErrSignCountUsed
+			}
+
+			s.user.WebAuthnCredentials[i].SignCount = signCount
+		}
+	}
+
+	return nil
+}
+
+// softAuthenticator is a platform authenticator with a single P-256 passkey and "none" attestation
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func (a *softAuthenticator) create(t *testing.T, rpID, origin string, options protocol.PublicKeyCredentialCreationOptions) []byte {
+	t.Helper()
+
+	a.userHandle = options.User.ID.(protocol.URLEncodedBase64)
+
+	clientData := clientDataJSON(t, "webauthn.create", options.Challenge, origin)
+
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attestedData := make([]byte, 16) // zero aaguid
+	attestedData = binary.BigEndian.AppendUint16(attestedData, uint16(len(a.credentialID)))
+	attestedData = append(attestedData, a.credentialID...)
+	attestedData = append(attestedData, coseKey...)
+
+	// user present, user verified and attested credential data flags
+	authData := append(a.authData(rpID, 0x45), attestedData...)
+
+	attestation, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credentialJSON(t, a.credentialID, map[string]string{
+		"clientDataJSON":    b64(clientData),
+		"attestationObject": b64(attestation),
+	})
+}
+
+func (a *softAuthenticator) get(t *testing.T, rpID, origin string, options protocol.PublicKeyCredentialRequestOptions) []byte {
+	t.Helper()
+
+	a.signCount++
+
+	clientData := clientDataJSON(t, "webauthn.get", options.Challenge, origin)
+
+	// user present and user verified flags
+	authData := a.authData(rpID, 0x05)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credentialJSON(t, a.credentialID, map[string]string{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	data := append(rpIDHash[:], flags)
+
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func clientDataJSON(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64, origin string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": b64(challenge),
+		"origin":    origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func credentialJSON(t *testing.T, id []byte, response map[string]string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       b64(id),
+		"rawId":    b64(id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestWebAuthn(t *testing.T) {
+	ctx := context.Background()
+
+	const origin = "http://localhost:2023"
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: 7 * 24 * time.Hour,
+		},
+		WebAuthn: config.WebAuthnSettings{
+			RPID:          "localhost",
+			RPDisplayName: "gas",
+			RPOrigins:     []string{origin},
+			Timeout:       time.Minute,
+		},
+	}
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	u := Usecase{
+		Storage:    fakeStorage{user: user},
+		attempts:   memory.NewAttemptsStorage(),
+		challenges: memory.NewChallengeStorage(),
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := &softAuthenticator{key: key, credentialID: []byte("soft-authenticator-credential")}
+
+	registration, err := u.BeginWebAuthnRegistration(ctx, cfg, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := authenticator.create(t, cfg.WebAuthn.RPID, origin, registration.Options.(*protocol.CredentialCreation).Response)
+
+	if err := u.FinishWebAuthnRegistration(ctx, cfg, user.ID, registration.SessionID, credential, false); err != nil {
+		t.Fatalf("Expected registration to succeed, got %s", err)
+	}
+
+	if len(user.WebAuthnCredentials) != 1 {
+		t.Fatalf("Expected 1 stored credential, got %d", len(user.WebAuthnCredentials))
+	}
+
+	login, err := u.BeginWebAuthnLogin(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion := authenticator.get(t, cfg.WebAuthn.RPID, origin, login.Options.(*protocol.CredentialAssertion).Response)
+
+	tokens, err := u.FinishWebAuthnLogin(ctx, cfg, login.SessionID, assertion)
+	if err != nil {
+		t.Fatalf("Expected login to succeed, got %s", err)
+	}
+
+	if tokens.Access == "" || tokens.Refresh == "" {
+		t.Error("Expected access and refresh tokens")
+	}
+
+	if user.WebAuthnCredentials[0].SignCount != authenticator.signCount {
+		t.Errorf("Expected sign count %d, got %d", authenticator.signCount, user.WebAuthnCredentials[0].SignCount)
+	}
+
+	if _, err := u.FinishWebAuthnLogin(ctx, cfg, login.SessionID, assertion); err == nil {
+		t.Error("Expected the replayed assertion to be rejected")
+	}
+
+	// second factor after the password
+	mfaToken, err := generatePurposeToken(cfg.SigningKey, mfaPurpose, user.Email, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfa, err := u.BeginWebAuthnMFA(ctx, cfg, mfaToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion = authenticator.get(t, cfg.WebAuthn.RPID, origin, mfa.Options.(*protocol.CredentialAssertion).Response)
+
+	if _, err := u.FinishWebAuthnMFA(ctx, cfg, "not a token", mfa.SessionID, assertion); err == nil {
+		t.Error("Expected the invalid mfa token to be rejected")
+	}
+
+	mfa, err = u.BeginWebAuthnMFA(ctx, cfg, mfaToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion = authenticator.get(t, cfg.WebAuthn.RPID, origin, mfa.Options.(*protocol.CredentialAssertion).Response)
+
+	if _, err := u.FinishWebAuthnMFA(ctx, cfg, mfaToken, mfa.SessionID, assertion); err != nil {
+		t.Errorf("Expected second factor to succeed, got %s", err)
+	}
+
+	// a copy of the passkey signs with a counter behind the one of the original
+	signCount := user.WebAuthnCredentials[0].SignCount
+	authenticator.signCount = 0
+
+	login, err = u.BeginWebAuthnLogin(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion = authenticator.get(t, cfg.WebAuthn.RPID, origin, login.Options.(*protocol.CredentialAssertion).Response)
+
+	if _, err := u.FinishWebAuthnLogin(ctx, cfg, login.SessionID, assertion); !errors.Is(err, ErrPasskeyCloned) {
+		t.Errorf("Expected %v, got %v", ErrPasskeyCloned, err)
+	}
+
+	if user.WebAuthnCredentials[0].SignCount != signCount {
+		t.Errorf("Expected sign count %d, got %d", signCount, user.WebAuthnCredentials[0].SignCount)
+	}
+}
+
+func TestWebAuthnSecondFactor(t *testing.T) {
+	ctx := context.Background()
+
+	const origin = "http://localhost:2023"
+
+	cfg := config.Config{
+		JwtSettings: config.JwtSettings{
+			SigningKey:      []byte("secret_key"),
+			AccessDuration:  5 * time.Minute,
+			RefreshDuration: time.Hour,
+		},
+		MFA: config.MFASettings{
+			ChallengeDuration: time.Minute,
+			Skew:              1,
+		},
+		WebAuthn: config.WebAuthnSettings{
+			RPID:          "localhost",
+			RPDisplayName: "gas",
+			RPOrigins:     []string{origin},
+			Timeout:       time.Minute,
+		},
+	}
+
+	u := Usecase{
+		Storage:    memory.New(),
+		attempts:   memory.NewAttemptsStorage(),
+		challenges: memory.NewChallengeStorage(),
+	}
+
+	const email, password = "rupychman@mail.ru", "password"
+
+	id, err := u.CreateUser(ctx, email, password, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := &softAuthenticator{key: key, credentialID: []byte("soft-authenticator-credential")}
+
+	registration, err := u.BeginWebAuthnRegistration(ctx, cfg, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := authenticator.create(t, cfg.WebAuthn.RPID, origin, registration.Options.(*protocol.CredentialCreation).Response)
+
+	if err := u.FinishWebAuthnRegistration(ctx, cfg, id, registration.SessionID, credential, true); err != nil {
+		t.Fatalf("Expected registration to succeed, got %s", err)
+	}
+
+	tokens, err := u.Signin(ctx, cfg, email, password, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens.MFA == "" || tokens.MFAMethod != constant.MFAMethodWebAuthn {
+		t.Fatalf("Expected the webauthn challenge, got %q %q", tokens.MFA, tokens.MFAMethod)
+	}
+
+	// the code anyone computes for the missing totp secret must not pass for the passkey
+	if _, err := u.VerifyMFA(ctx, cfg, tokens.MFA, emptyKeyCode(time.Now()), "10.0.0.1"); !errors.Is(err, ErrPasskeyRequired) {
+		t.Errorf("Expected %v, got %v", ErrPasskeyRequired, err)
+	}
+
+	mfa, err := u.BeginWebAuthnMFA(ctx, cfg, tokens.MFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion := authenticator.get(t, cfg.WebAuthn.RPID, origin, mfa.Options.(*protocol.CredentialAssertion).Response)
+
+	if _, err := u.FinishWebAuthnMFA(ctx, cfg, tokens.MFA, mfa.SessionID, assertion); err != nil {
+		t.Errorf("Expected the passkey to complete the sign in, got %s", err)
+	}
+}
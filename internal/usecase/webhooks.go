@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/storage"
+	"net/url"
+	"time"
+)
+
+// CreateWebhook returns the id and the signing secret of the webhook, the secret is shown only once
+func (u Usecase) CreateWebhook(ctx context.Context, rawURL, description string, eventTypes []string) (id, secret string, err error) {
+	const op = "usecase.webhooks.CreateWebhook"
+
+	defer func() { u.audit(ctx, constant.AuditWebhookCreate, rawURL, err) }()
+
+	if err := validateWebhookURL(rawURL); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := validateEventTypes(eventTypes); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err = randomString(32)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret = constant.WebhookSecretPrefix + secret
+
+	id, err = u.Storage.CreateWebhook(ctx, storage.Webhook{
+		URL:         rawURL,
+		Description: description,
+		Events:      eventTypes,
+		Secret:      secret,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, secret, nil
+}
+
+func (u Usecase) Webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	const op = "usecase.webhooks.Webhooks"
+
+	webhooks, err := u.Storage.Webhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+func (u Usecase) Webhook(ctx context.Context, id string) (storage.Webhook, error) {
+	const op = "usecase.webhooks.Webhook"
+
+	webhook, err := u.Storage.WebhookByID(ctx, id)
+	if err != nil {
+		return storage.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhook, nil
+}
+
+func (u Usecase) DeleteWebhook(ctx context.Context, id string) (err error) {
+	const op = "usecase.webhooks.DeleteWebhook"
+
+	defer func() { u.audit(ctx, constant.AuditWebhookDelete, id, err) }()
+
+	if err := u.Storage.DeleteWebhook(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// WebhookDeliveries returns the delivery log of the webhook, the newest attempts first
+func (u Usecase) WebhookDeliveries(ctx context.Context, id string, limit int64) ([]storage.WebhookDelivery, error) {
+	const op = "usecase.webhooks.WebhookDeliveries"
+
+	if _, err := u.Storage.WebhookByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if limit <= 0 {
+		limit = constant.DefaultPageLimit
+	}
+
+	if limit > constant.MaxPageLimit {
+		limit = constant.MaxPageLimit
+	}
+
+	deliveries, err := u.Storage.WebhookDeliveries(ctx, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.New("url is not valid")
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return errors.New("url must be an absolute http or https url")
+	}
+
+	return nil
+}
+
+func validateEventTypes(eventTypes []string) error {
+	if len(eventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+
+	for _, eventType := range eventTypes {
+		if eventType != constant.EventAll && !isEventType(eventType) {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+
+	return nil
+}
+
+func isEventType(eventType string) bool {
+	for _, t := range constant.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/config"
+	"github.com/degeboman/gas/internal/lib/events"
+	"github.com/degeboman/gas/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+type fakePublisher struct {
+	events *[]events.Event
+}
+
+func (p fakePublisher) Publish(_ context.Context, event events.Event) error {
+	*p.events = append(*p.events, event)
+	return nil
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	u := Usecase{}
+
+	data := []struct {
+		name   string
+		url    string
+		events []string
+	}{
+		{"relative url", "/hooks", []string{constant.EventUserCreated}},
+		{"ftp url", "ftp://example.com/hooks", []string{constant.EventUserCreated}},
+		{"no events", "https://example.com/hooks", nil},
+		{"unknown event", "https://example.com/hooks", []string{"user.teleported"}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if _, _, err := u.CreateWebhook(context.Background(), d.url, "", d.events); err == nil {
+				t.Errorf("Expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+
+	var cfg config.Config
+
+	cfg.SigningKey = []byte("secret")
+	cfg.AccessDuration = time.Minute
+	cfg.RefreshDuration = 10 * time.Minute
+
+	user := &storage.User{
+		ID:    primitive.NewObjectID().Hex(),
+		Email: "rupychman@mail.ru",
+	}
+
+	var published []events.Event
+
+	u := Usecase{
+		Storage: fakeKeyStorage{
+			fakeStorage: fakeStorage{user: user},
+			keys:        map[string]storage.APIKey{},
+			accounts:    map[string]storage.ServiceAccount{},
+		},
+		events: fakePublisher{events: &published},
+	}
+
+	_, apiKey, err := u.CreateAPIKey(ctx, constant.OwnerUser, user.ID, "laptop", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.RevokeAPIKey(ctx, user.ID, apiKey.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.issueTokens(ctx, cfg, *user); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{constant.EventAPIKeyCreated, constant.EventAPIKeyRevoked, constant.EventUserSignedIn}
+
+	if len(published) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(published))
+	}
+
+	for i, eventType := range expected {
+		if published[i].Type != eventType {
+			t.Errorf("Expected %s, got %s", eventType, published[i].Type)
+		}
+	}
+
+	if id := published[2].Data["id"]; id != user.ID {
+		t.Errorf("Expected user id %s, got %v", user.ID, id)
+	}
+}
@@ -2,20 +2,98 @@ package main
 
 import (
 	"context"
+	"fmt"
+	gasv1 "github.com/degeboman/gas/api/gas/v1"
 	"github.com/degeboman/gas/constant"
 	"github.com/degeboman/gas/internal/config"
+	grpcAuth "github.com/degeboman/gas/internal/grpc-server/auth"
+	"github.com/degeboman/gas/internal/grpc-server/extauthz"
+	auditExport "github.com/degeboman/gas/internal/http-server/handlers/admin/audit/export"
+	auditList "github.com/degeboman/gas/internal/http-server/handlers/admin/audit/list"
+	auditVerify "github.com/degeboman/gas/internal/http-server/handlers/admin/audit/verify"
+	saCreate "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/apikeys/create"
+	saAPIKeys "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/apikeys/list"
+	saRevoke "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/apikeys/revoke"
+	serviceAccountCreate "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/create"
+	serviceAccountGet "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/get"
+	serviceAccountList "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/list"
+	serviceAccountRemove "github.com/degeboman/gas/internal/http-server/handlers/admin/serviceaccounts/remove"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/create"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/disable"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/enable"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/get"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/list"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/remove"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/resetpassword"
+	adminUnlock "github.com/degeboman/gas/internal/http-server/handlers/admin/users/unlock"
+	"github.com/degeboman/gas/internal/http-server/handlers/admin/users/update"
+	webhookCreate "github.com/degeboman/gas/internal/http-server/handlers/admin/webhooks/create"
+	webhookDeliveries "github.com/degeboman/gas/internal/http-server/handlers/admin/webhooks/deliveries"
+	webhookGet "github.com/degeboman/gas/internal/http-server/handlers/admin/webhooks/get"
+	webhookList "github.com/degeboman/gas/internal/http-server/handlers/admin/webhooks/list"
+	webhookRemove "github.com/degeboman/gas/internal/http-server/handlers/admin/webhooks/remove"
+	apiKeyCreate "github.com/degeboman/gas/internal/http-server/handlers/apikeys/create"
+	apiKeyList "github.com/degeboman/gas/internal/http-server/handlers/apikeys/list"
+	apiKeyRevoke "github.com/degeboman/gas/internal/http-server/handlers/apikeys/revoke"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/forward"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/introspect"
+	magicLinkSend "github.com/degeboman/gas/internal/http-server/handlers/auth/magiclink/send"
+	magicLinkVerify "github.com/degeboman/gas/internal/http-server/handlers/auth/magiclink/verify"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/mfa/beginwebauthn"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/mfa/confirm"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/mfa/enroll"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/mfa/finishwebauthn"
+	mfaVerify "github.com/degeboman/gas/internal/http-server/handlers/auth/mfa/verify"
+	oauthBegin "github.com/degeboman/gas/internal/http-server/handlers/auth/oauth/begin"
+	oauthCallback "github.com/degeboman/gas/internal/http-server/handlers/auth/oauth/callback"
+	otpSend "github.com/degeboman/gas/internal/http-server/handlers/auth/otp/send"
+	otpVerify "github.com/degeboman/gas/internal/http-server/handlers/auth/otp/verify"
+	passwordResetConfirm "github.com/degeboman/gas/internal/http-server/handlers/auth/passwordreset/confirm"
+	passwordResetSend "github.com/degeboman/gas/internal/http-server/handlers/auth/passwordreset/send"
 	"github.com/degeboman/gas/internal/http-server/handlers/auth/refresh"
+	samlACS "github.com/degeboman/gas/internal/http-server/handlers/auth/saml/acs"
+	samlLogin "github.com/degeboman/gas/internal/http-server/handlers/auth/saml/login"
+	samlMetadata "github.com/degeboman/gas/internal/http-server/handlers/auth/saml/metadata"
 	"github.com/degeboman/gas/internal/http-server/handlers/auth/signin"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/signout"
 	"github.com/degeboman/gas/internal/http-server/handlers/auth/signup"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/unlock"
 	"github.com/degeboman/gas/internal/http-server/handlers/auth/verify"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/webauthn/beginlogin"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/webauthn/beginregistration"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/webauthn/finishlogin"
+	"github.com/degeboman/gas/internal/http-server/handlers/auth/webauthn/finishregistration"
+	jwksHandler "github.com/degeboman/gas/internal/http-server/handlers/jwks"
+	mwAudit "github.com/degeboman/gas/internal/http-server/middleware/audit"
+	mwAuth "github.com/degeboman/gas/internal/http-server/middleware/auth"
 	mwLogger "github.com/degeboman/gas/internal/http-server/middleware/logger"
+	mwRateLimit "github.com/degeboman/gas/internal/http-server/middleware/ratelimit"
+	"github.com/degeboman/gas/internal/lib/audit"
+	"github.com/degeboman/gas/internal/lib/cache"
+	"github.com/degeboman/gas/internal/lib/directory"
+	"github.com/degeboman/gas/internal/lib/jwks"
 	"github.com/degeboman/gas/internal/lib/logger"
 	"github.com/degeboman/gas/internal/lib/logger/sl"
+	"github.com/degeboman/gas/internal/lib/mailer"
+	"github.com/degeboman/gas/internal/lib/oauth"
+	"github.com/degeboman/gas/internal/lib/outbox"
+	"github.com/degeboman/gas/internal/lib/sso"
+	"github.com/degeboman/gas/internal/lib/webhook"
+	"github.com/degeboman/gas/internal/storage"
+	"github.com/degeboman/gas/internal/storage/cached"
+	"github.com/degeboman/gas/internal/storage/memory"
 	"github.com/degeboman/gas/internal/storage/mongodb"
+	"github.com/degeboman/gas/internal/storage/postgres"
+	"github.com/degeboman/gas/internal/storage/redis"
+	"github.com/degeboman/gas/internal/storage/sqlite"
 	"github.com/degeboman/gas/internal/usecase"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -43,26 +121,179 @@ func main() {
 		slog.String("version", "1"),
 	)
 
-	storage := mongodb.New(cfg.MongoConnectionString)
+	db := openStorage(log, cfg)
 
 	log.Info(
 		"storage is running",
+		slog.String("backend", cfg.Storage.Backend),
 	)
 
-	u := usecase.New(&storage)
+	rdb := openRedis(log, cfg)
+
+	providers, err := oauth.NewProviders(cfg.OAuth)
+	if err != nil {
+		log.Error("failed to set up identity providers", sl.Err(err))
+		os.Exit(1)
+	}
+
+	samlProvider, err := serviceProvider(cfg)
+	if err != nil {
+		log.Error("failed to set up saml", sl.Err(err))
+		os.Exit(1)
+	}
+
+	keys, err := tokenKeys(cfg)
+	if err != nil {
+		log.Error("failed to load token signing key", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if !db.SupportsTransactions() {
+		log.Warn("storage does not support transactions, events are written to the outbox apart from the changes")
+	}
+
+	sinks, err := outboxSinks(cfg, webhook.New(log, cfg.Webhooks, db))
+	if err != nil {
+		log.Error("failed to set up outbox sinks", sl.Err(err))
+		os.Exit(1)
+	}
+
+	relay := outbox.NewRelay(log, cfg.Outbox, db, sinks)
+	relay.Start()
+
+	// the replicas drop the users changed by the others through redis when gas has it
+	var broadcaster cached.Broadcaster
+	if rdb != nil {
+		broadcaster = rdb
+	}
+
+	users := cached.New(db, cache.New(cfg.Cache.Size, cfg.Cache.TTL), broadcaster)
+	users.Start()
+
+	u := usecase.New(
+		users,
+		attemptsStorage(cfg, db, rdb),
+		challengeStorage(cfg, db, rdb),
+		revocationStorage(cfg, db, rdb),
+		keys,
+		mailer.New(log, cfg.Mail),
+		providers,
+		userDirectory(cfg),
+		samlProvider,
+		outbox.NewPublisher(db),
+		audit.New(log, db),
+		cache.New(cfg.Cache.Size, cfg.Cache.TTL),
+	)
 
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
+	if cfg.TrustProxyHeaders {
+		router.Use(middleware.RealIP)
+	}
+	router.Use(mwAudit.New())
 	router.Use(middleware.Logger)
 	router.Use(mwLogger.New(log))
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
+	if cfg.RateLimit.Enabled {
+		router.Use(mwRateLimit.New(log, cfg, rateLimitStorage(cfg, rdb), u))
+	}
+
+	router.Get(constant.JWKSRoute, jwksHandler.New(log, u))
 
 	router.Route(constant.AuthRoute, func(r chi.Router) {
 		r.Post(constant.SignUpRoute, signup.New(log, u))
 		r.Post(constant.SignInRoute, signin.New(log, cfg, u))
 		r.Post(constant.VerifyRoute, verify.New(log, cfg, u))
 		r.Post(constant.RefreshRoute, refresh.New(log, cfg, u))
+		r.Post(constant.SignOutRoute, signout.New(log, cfg, u))
+		r.Post(constant.IntrospectRoute, introspect.New(log, cfg, u))
+		r.Post(constant.UnlockRoute, unlock.New(log, cfg, u))
+		r.Post(constant.PasswordResetRoute, passwordResetSend.New(log, cfg, u))
+		r.Post(constant.PasswordResetConfirmRoute, passwordResetConfirm.New(log, u))
+		// the proxies keep the method of the original request
+		r.HandleFunc(constant.ForwardRoute, forward.New(log, cfg, u))
+		r.Post(constant.MagicLinkRoute, magicLinkSend.New(log, cfg, u))
+		r.Post(constant.MagicLinkVerifyRoute, magicLinkVerify.New(log, cfg, u))
+		r.Post(constant.OTPRoute, otpSend.New(log, cfg, u))
+		r.Post(constant.OTPVerifyRoute, otpVerify.New(log, cfg, u))
+		r.Get(constant.OAuthRoute, oauthBegin.New(log, cfg, u))
+		r.Get(constant.OAuthCallbackRoute, oauthCallback.New(log, cfg, u))
+
+		r.Route(constant.SAMLRoute, func(r chi.Router) {
+			r.Get(constant.SAMLMetadataRoute, samlMetadata.New(log, u))
+			r.Get(constant.SAMLLoginRoute, samlLogin.New(log, cfg, u))
+			r.Post(constant.SAMLACSRoute, samlACS.New(log, cfg, u))
+		})
+
+		r.Route(constant.MFARoute, func(r chi.Router) {
+			r.Post(constant.MFAVerifyRoute, mfaVerify.New(log, cfg, u))
+			r.Post(constant.MFAWebAuthnBeginRoute, beginwebauthn.New(log, cfg, u))
+			r.Post(constant.MFAWebAuthnFinishRoute, finishwebauthn.New(log, cfg, u))
+
+			r.Group(func(r chi.Router) {
+				r.Use(mwAuth.New(log, cfg, u))
+				r.Use(mwAuth.UserOnly(log))
+
+				r.Post(constant.TOTPEnrollRoute, enroll.New(log, cfg, u))
+				r.Post(constant.TOTPConfirmRoute, confirm.New(log, cfg, u))
+			})
+		})
+
+		r.Route(constant.WebAuthnRoute, func(r chi.Router) {
+			r.Post(constant.WebAuthnBeginLoginRoute, beginlogin.New(log, cfg, u))
+			r.Post(constant.WebAuthnFinishLoginRoute, finishlogin.New(log, cfg, u))
+
+			r.Group(func(r chi.Router) {
+				r.Use(mwAuth.New(log, cfg, u))
+				r.Use(mwAuth.UserOnly(log))
+
+				r.Post(constant.WebAuthnBeginRegistrationRoute, beginregistration.New(log, cfg, u))
+				r.Post(constant.WebAuthnFinishRegistrationRoute, finishregistration.New(log, cfg, u))
+			})
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(mwAuth.New(log, cfg, u))
+			r.Use(mwAuth.UserOnly(log))
+
+			r.Get(constant.APIKeysRoute, apiKeyList.New(log, u))
+			r.Post(constant.APIKeysRoute, apiKeyCreate.New(log, u))
+			r.Delete(constant.APIKeyRoute, apiKeyRevoke.New(log, u))
+		})
+	})
+
+	router.Route(constant.AdminRoute, func(r chi.Router) {
+		r.Use(mwAuth.New(log, cfg, u))
+		r.Use(mwAuth.Admin(log, cfg))
+
+		r.Get(constant.UsersRoute, list.New(log, u))
+		r.Post(constant.UsersRoute, create.New(log, u))
+		r.Get(constant.UserRoute, get.New(log, u))
+		r.Patch(constant.UserRoute, update.New(log, u))
+		r.Delete(constant.UserRoute, remove.New(log, u))
+		r.Post(constant.UserDisableRoute, disable.New(log, u))
+		r.Post(constant.UserEnableRoute, enable.New(log, u))
+		r.Post(constant.UserResetPasswordRoute, resetpassword.New(log, u))
+		r.Post(constant.UserUnlockRoute, adminUnlock.New(log, u))
+
+		r.Get(constant.ServiceAccountsRoute, serviceAccountList.New(log, u))
+		r.Post(constant.ServiceAccountsRoute, serviceAccountCreate.New(log, u))
+		r.Get(constant.ServiceAccountRoute, serviceAccountGet.New(log, u))
+		r.Delete(constant.ServiceAccountRoute, serviceAccountRemove.New(log, u))
+		r.Get(constant.ServiceAccountAPIKeysRoute, saAPIKeys.New(log, u))
+		r.Post(constant.ServiceAccountAPIKeysRoute, saCreate.New(log, u))
+		r.Delete(constant.ServiceAccountAPIKeyRoute, saRevoke.New(log, u))
+
+		r.Get(constant.WebhooksRoute, webhookList.New(log, u))
+		r.Post(constant.WebhooksRoute, webhookCreate.New(log, u))
+		r.Get(constant.WebhookRoute, webhookGet.New(log, u))
+		r.Delete(constant.WebhookRoute, webhookRemove.New(log, u))
+		r.Get(constant.WebhookDeliveriesRoute, webhookDeliveries.New(log, u))
+
+		r.Get(constant.AuditRoute, auditList.New(log, u))
+		r.Get(constant.AuditExportRoute, auditExport.New(log, u))
+		r.Get(constant.AuditVerifyRoute, auditVerify.New(log, u))
 	})
 
 	done := make(chan os.Signal, 1)
@@ -82,9 +313,27 @@ func main() {
 		}
 	}()
 
+	var grpcServers []*grpc.Server
+
+	if cfg.GRPCServer.Enabled {
+		grpcServers = append(grpcServers, serveGRPC(log, cfg.GRPCServer.Address, func(s *grpc.Server) {
+			gasv1.RegisterAuthServiceServer(s, grpcAuth.New(log, cfg, u))
+		}))
+	}
+
+	if cfg.ExtAuthz.Enabled {
+		grpcServers = append(grpcServers, serveGRPC(log, cfg.ExtAuthz.Address, func(s *grpc.Server) {
+			authv3.RegisterAuthorizationServer(s, extauthz.New(log, cfg, u))
+		}))
+	}
+
 	<-done
 	log.Info("stopping server")
 
+	for _, s := range grpcServers {
+		s.GracefulStop()
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -94,5 +343,221 @@ func main() {
 		return
 	}
 
+	// the events not relayed yet stay in the outbox and are sent after the restart,
+	// the batch in flight is finished
+	relay.Stop()
+	users.Stop()
+
+	for _, sink := range sinks {
+		if c, ok := sink.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+
+	if c, ok := db.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Error("failed to close storage", sl.Err(err))
+		}
+	}
+
+	if rdb != nil {
+		if err := rdb.Close(); err != nil {
+			log.Error("failed to close redis", sl.Err(err))
+		}
+	}
+
 	log.Info("server stopped")
 }
+
+// serveGRPC starts a grpc server with the services registered by register, the caller stops it
+func serveGRPC(log *slog.Logger, address string, register func(s *grpc.Server)) *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(audit.UnaryServerInterceptor))
+	register(s)
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Error("failed to listen grpc address", slog.String("address", address), sl.Err(err))
+		os.Exit(1)
+	}
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Error("failed to start grpc server", slog.String("address", address), sl.Err(err))
+		}
+	}()
+
+	return s
+}
+
+// database is everything gas keeps in the storage backend
+type database interface {
+	storage.Storage
+	storage.AttemptsStorage
+	storage.ChallengeStorage
+	storage.RevocationStorage
+	storage.OutboxStorage
+	storage.AuditStorage
+	storage.Transactor
+	SupportsTransactions() bool
+}
+
+func openStorage(log *slog.Logger, cfg config.Config) database {
+	switch cfg.Storage.Backend {
+	case constant.StorePostgres:
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		s, err := postgres.New(ctx, cfg.PostgresConnectionString)
+		if err != nil {
+			log.Error("failed to open postgres", sl.Err(err))
+			os.Exit(1)
+		}
+
+		return s
+	case constant.StoreSQLite:
+		s, err := sqlite.New(context.Background(), cfg.Storage.SQLitePath)
+		if err != nil {
+			log.Error("failed to open sqlite", slog.String("path", cfg.Storage.SQLitePath), sl.Err(err))
+			os.Exit(1)
+		}
+
+		return s
+	case constant.StoreMemory:
+		s, err := memory.Open(cfg.Storage.SnapshotPath, cfg.Storage.SnapshotInterval)
+		if err != nil {
+			log.Error("failed to load memory snapshot", slog.String("path", cfg.Storage.SnapshotPath), sl.Err(err))
+			os.Exit(1)
+		}
+
+		return s
+	default:
+		s, err := mongodb.New(cfg.MongoConnectionString)
+		if err != nil {
+			log.Error("failed to connect to mongodb", sl.Err(err))
+			os.Exit(1)
+		}
+
+		return &s
+	}
+}
+
+// openRedis connects to redis when any store is kept there, it returns nil otherwise
+func openRedis(log *slog.Logger, cfg config.Config) *redis.Storage {
+	stores := []string{cfg.Lockout.Store, cfg.WebAuthn.SessionStore, cfg.RevocationStore}
+	if cfg.RateLimit.Enabled {
+		stores = append(stores, cfg.RateLimit.Store)
+	}
+
+	used := false
+	for _, store := range stores {
+		used = used || store == constant.StoreRedis
+	}
+
+	if !used {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s, err := redis.New(ctx, cfg.Redis)
+	if err != nil {
+		log.Error("failed to connect to redis", slog.String("address", cfg.Redis.Address), sl.Err(err))
+		os.Exit(1)
+	}
+
+	log.Info("redis is running", slog.Bool("embedded", cfg.Redis.Embedded))
+
+	return s
+}
+
+func attemptsStorage(cfg config.Config, db database, rdb *redis.Storage) storage.AttemptsStorage {
+	switch cfg.Lockout.Store {
+	case cfg.Storage.Backend:
+		return db
+	case constant.StoreRedis:
+		return rdb
+	}
+
+	return memory.NewAttemptsStorage()
+}
+
+func challengeStorage(cfg config.Config, db database, rdb *redis.Storage) storage.ChallengeStorage {
+	switch cfg.WebAuthn.SessionStore {
+	case cfg.Storage.Backend:
+		return db
+	case constant.StoreRedis:
+		return rdb
+	}
+
+	return memory.NewChallengeStorage()
+}
+
+func revocationStorage(cfg config.Config, db database, rdb *redis.Storage) storage.RevocationStorage {
+	switch cfg.RevocationStore {
+	case cfg.Storage.Backend:
+		return db
+	case constant.StoreRedis:
+		return rdb
+	}
+
+	return memory.NewRevocationStorage()
+}
+
+func rateLimitStorage(cfg config.Config, rdb *redis.Storage) storage.RateLimitStorage {
+	if cfg.RateLimit.Store == constant.StoreRedis {
+		return rdb
+	}
+
+	return memory.NewRateLimitStorage()
+}
+
+func outboxSinks(cfg config.Config, webhooks *webhook.Dispatcher) (map[string]outbox.Sink, error) {
+	sinks := make(map[string]outbox.Sink, len(cfg.Outbox.Sinks))
+
+	for _, name := range cfg.Outbox.Sinks {
+		switch name {
+		case constant.SinkWebhooks:
+			sinks[name] = webhooks
+		case constant.SinkStdout:
+			sinks[name] = outbox.NewWriterSink(os.Stdout)
+		case constant.SinkKafka:
+			sinks[name] = outbox.NewKafkaSink(cfg.Outbox.Kafka)
+		case constant.SinkNATS:
+			sink, err := outbox.NewNATSSink(cfg.Outbox.NATS)
+			if err != nil {
+				return nil, err
+			}
+
+			sinks[name] = sink
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+
+	return sinks, nil
+}
+
+func tokenKeys(cfg config.Config) (*jwks.KeySet, error) {
+	if cfg.PrivateKeyFile == "" {
+		return nil, nil
+	}
+
+	return jwks.Load(cfg.PrivateKeyFile)
+}
+
+func userDirectory(cfg config.Config) usecase.Directory {
+	if cfg.LDAP.Enabled {
+		return directory.New(cfg.LDAP)
+	}
+
+	return nil
+}
+
+func serviceProvider(cfg config.Config) (usecase.SAMLProvider, error) {
+	if !cfg.SAML.Enabled {
+		return nil, nil
+	}
+
+	return sso.New(cfg.SAML)
+}
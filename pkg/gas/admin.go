@@ -0,0 +1,309 @@
+package gas
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIKeys lists the api keys of the user of the client token
+func (c *Client) APIKeys(ctx context.Context) ([]APIKey, error) {
+	var resp struct {
+		APIKeys []APIKey `json:"api_keys"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AuthRoute+constant.APIKeysRoute, nil, nil, &resp)
+
+	return resp.APIKeys, err
+}
+
+// CreateAPIKey creates an api key of the user of the client token, an api key can't create api keys
+func (c *Client) CreateAPIKey(ctx context.Context, key NewAPIKey) (CreatedAPIKey, error) {
+	var resp CreatedAPIKey
+
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.APIKeysRoute, nil, key, &resp)
+
+	return resp, err
+}
+
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, constant.AuthRoute+route(constant.APIKeyRoute, id), nil, nil, nil)
+
+	return err
+}
+
+// Users returns the page of users and the number of the users matching the filter
+func (c *Client) Users(ctx context.Context, filter UserFilter) ([]User, int64, error) {
+	var resp struct {
+		Users []User `json:"users"`
+		Total int64  `json:"total"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+constant.UsersRoute, filter.query(), nil, &resp)
+
+	return resp.Users, resp.Total, err
+}
+
+func (c *Client) User(ctx context.Context, id string) (User, error) {
+	var resp User
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+route(constant.UserRoute, id), nil, nil, &resp)
+
+	return resp, err
+}
+
+// CreateUser creates the user on behalf of an admin and returns its id
+func (c *Client) CreateUser(ctx context.Context, email, password string, userInfo map[string]interface{}) (string, error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+
+	_, err := c.do(ctx, http.MethodPost, constant.AdminRoute+constant.UsersRoute, nil, map[string]interface{}{
+		"email":     email,
+		"password":  password,
+		"user_info": userInfo,
+	}, &resp)
+
+	return resp.ID, err
+}
+
+func (c *Client) UpdateUserInfo(ctx context.Context, id string, userInfo map[string]interface{}) error {
+	_, err := c.do(ctx, http.MethodPatch, constant.AdminRoute+route(constant.UserRoute, id), nil, map[string]interface{}{
+		"user_info": userInfo,
+	}, nil)
+
+	return err
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, constant.AdminRoute+route(constant.UserRoute, id), nil, nil, nil)
+
+	return err
+}
+
+func (c *Client) DisableUser(ctx context.Context, id string) error {
+	return c.userAction(ctx, constant.UserDisableRoute, id)
+}
+
+func (c *Client) EnableUser(ctx context.Context, id string) error {
+	return c.userAction(ctx, constant.UserEnableRoute, id)
+}
+
+// ResetPassword makes the user set a new password before the next sign in
+func (c *Client) ResetPassword(ctx context.Context, id string) error {
+	return c.userAction(ctx, constant.UserResetPasswordRoute, id)
+}
+
+func (c *Client) UnlockUser(ctx context.Context, id string) error {
+	return c.userAction(ctx, constant.UserUnlockRoute, id)
+}
+
+func (c *Client) ServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	var resp struct {
+		ServiceAccounts []ServiceAccount `json:"service_accounts"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+constant.ServiceAccountsRoute, nil, nil, &resp)
+
+	return resp.ServiceAccounts, err
+}
+
+func (c *Client) ServiceAccount(ctx context.Context, id string) (ServiceAccount, error) {
+	var resp ServiceAccount
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+route(constant.ServiceAccountRoute, id), nil, nil, &resp)
+
+	return resp, err
+}
+
+// CreateServiceAccount returns the id of the new service account
+func (c *Client) CreateServiceAccount(ctx context.Context, name, description string, roles []string) (string, error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+
+	_, err := c.do(ctx, http.MethodPost, constant.AdminRoute+constant.ServiceAccountsRoute, nil, map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"roles":       roles,
+	}, &resp)
+
+	return resp.ID, err
+}
+
+func (c *Client) DeleteServiceAccount(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, constant.AdminRoute+route(constant.ServiceAccountRoute, id), nil, nil, nil)
+
+	return err
+}
+
+func (c *Client) ServiceAccountAPIKeys(ctx context.Context, id string) ([]APIKey, error) {
+	var resp struct {
+		APIKeys []APIKey `json:"api_keys"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+route(constant.ServiceAccountAPIKeysRoute, id), nil, nil, &resp)
+
+	return resp.APIKeys, err
+}
+
+func (c *Client) CreateServiceAccountAPIKey(ctx context.Context, id string, key NewAPIKey) (CreatedAPIKey, error) {
+	var resp CreatedAPIKey
+
+	_, err := c.do(ctx, http.MethodPost, constant.AdminRoute+route(constant.ServiceAccountAPIKeysRoute, id), nil, key, &resp)
+
+	return resp, err
+}
+
+func (c *Client) RevokeServiceAccountAPIKey(ctx context.Context, id, keyID string) error {
+	_, err := c.do(ctx, http.MethodDelete, constant.AdminRoute+route(constant.ServiceAccountAPIKeyRoute, id, keyID), nil, nil, nil)
+
+	return err
+}
+
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var resp struct {
+		Webhooks []Webhook `json:"webhooks"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+constant.WebhooksRoute, nil, nil, &resp)
+
+	return resp.Webhooks, err
+}
+
+func (c *Client) Webhook(ctx context.Context, id string) (Webhook, error) {
+	var resp Webhook
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+route(constant.WebhookRoute, id), nil, nil, &resp)
+
+	return resp, err
+}
+
+// CreateWebhook returns the id of the webhook and the secret its payloads are signed with, gas shows it only once
+func (c *Client) CreateWebhook(ctx context.Context, endpoint, description string, events []string) (string, string, error) {
+	var resp struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}
+
+	_, err := c.do(ctx, http.MethodPost, constant.AdminRoute+constant.WebhooksRoute, nil, map[string]interface{}{
+		"url":         endpoint,
+		"description": description,
+		"events":      events,
+	}, &resp)
+
+	return resp.ID, resp.Secret, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, constant.AdminRoute+route(constant.WebhookRoute, id), nil, nil, nil)
+
+	return err
+}
+
+// WebhookDeliveries returns the last delivery attempts of the webhook, the newest first
+func (c *Client) WebhookDeliveries(ctx context.Context, id string, limit int64) ([]WebhookDelivery, error) {
+	var resp struct {
+		Deliveries []WebhookDelivery `json:"deliveries"`
+	}
+
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.FormatInt(limit, 10))
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+route(constant.WebhookDeliveriesRoute, id), query, nil, &resp)
+
+	return resp.Deliveries, err
+}
+
+// AuditEntries returns a page of the audit log, the newest entries first unless filter.After is set
+func (c *Client) AuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	var resp struct {
+		Entries []AuditEntry `json:"entries"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, constant.AdminRoute+constant.AuditRoute, filter.query(), nil, &resp)
+
+	return resp.Entries, err
+}
+
+func (c *Client) userAction(ctx context.Context, pattern, id string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AdminRoute+route(pattern, id), nil, nil, nil)
+
+	return err
+}
+
+func (f UserFilter) query() url.Values {
+	q := url.Values{}
+
+	if f.EmailPrefix != "" {
+		q.Set("email_prefix", f.EmailPrefix)
+	}
+
+	if f.Offset != 0 {
+		q.Set("offset", strconv.FormatInt(f.Offset, 10))
+	}
+
+	if f.Limit != 0 {
+		q.Set("limit", strconv.FormatInt(f.Limit, 10))
+	}
+
+	if !f.CreatedAfter.IsZero() {
+		q.Set("created_after", f.CreatedAfter.Format(time.RFC3339))
+	}
+
+	if !f.CreatedBefore.IsZero() {
+		q.Set("created_before", f.CreatedBefore.Format(time.RFC3339))
+	}
+
+	if f.Verified != nil {
+		q.Set("verified", strconv.FormatBool(*f.Verified))
+	}
+
+	if f.Locked != nil {
+		q.Set("locked", strconv.FormatBool(*f.Locked))
+	}
+
+	return q
+}
+
+func (f AuditFilter) query() url.Values {
+	q := url.Values{}
+
+	for key, v := range map[string]string{
+		"action":   f.Action,
+		"actor_id": f.ActorID,
+		"target":   f.Target,
+		"outcome":  f.Outcome,
+	} {
+		if v != "" {
+			q.Set(key, v)
+		}
+	}
+
+	if !f.Since.IsZero() {
+		q.Set("since", f.Since.Format(time.RFC3339))
+	}
+
+	if !f.Until.IsZero() {
+		q.Set("until", f.Until.Format(time.RFC3339))
+	}
+
+	if f.After != 0 {
+		q.Set("after", strconv.FormatInt(f.After, 10))
+	}
+
+	if f.Before != 0 {
+		q.Set("before", strconv.FormatInt(f.Before, 10))
+	}
+
+	if f.Limit != 0 {
+		q.Set("limit", strconv.FormatInt(f.Limit, 10))
+	}
+
+	return q
+}
@@ -0,0 +1,188 @@
+package gas
+
+import (
+	"context"
+	"github.com/degeboman/gas/constant"
+	"net/http"
+)
+
+type signInResponse struct {
+	AccessToken string `json:"access_token"`
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	MFAMethod   string `json:"mfa_method"`
+}
+
+// SignUp registers the user, userInfo may be nil
+func (c *Client) SignUp(ctx context.Context, email, password string, userInfo map[string]interface{}) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.SignUpRoute, nil, map[string]interface{}{
+		"email":     email,
+		"password":  password,
+		"user_info": userInfo,
+	}, nil)
+
+	return err
+}
+
+func (c *Client) SignIn(ctx context.Context, email, password string) (Tokens, error) {
+	return c.signIn(ctx, constant.AuthRoute+constant.SignInRoute, map[string]string{
+		"email":    email,
+		"password": password,
+	})
+}
+
+// VerifyMFA completes the sign in of a user with two-factor authentication by a totp or a recovery code
+func (c *Client) VerifyMFA(ctx context.Context, mfaToken, code string) (Tokens, error) {
+	return c.signIn(ctx, constant.AuthRoute+constant.MFARoute+constant.MFAVerifyRoute, map[string]string{
+		"mfa_token": mfaToken,
+		"code":      code,
+	})
+}
+
+// SendMagicLink mails the sign in link, gas answers the same for unknown emails
+func (c *Client) SendMagicLink(ctx context.Context, email string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.MagicLinkRoute, nil, map[string]string{
+		"email": email,
+	}, nil)
+
+	return err
+}
+
+// SignInWithMagicLink signs in by the token of the link, it has to be called by the client that sent the link
+func (c *Client) SignInWithMagicLink(ctx context.Context, token string) (Tokens, error) {
+	return c.signIn(ctx, constant.AuthRoute+constant.MagicLinkVerifyRoute, map[string]string{
+		"token": token,
+	})
+}
+
+// SendOTP mails the one-time code, gas answers the same for unknown emails
+func (c *Client) SendOTP(ctx context.Context, email string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.OTPRoute, nil, map[string]string{
+		"email": email,
+	}, nil)
+
+	return err
+}
+
+// SignInWithOTP signs in by the mailed code, it has to be called by the client that sent the code
+func (c *Client) SignInWithOTP(ctx context.Context, code string) (Tokens, error) {
+	return c.signIn(ctx, constant.AuthRoute+constant.OTPVerifyRoute, map[string]string{
+		"code": code,
+	})
+}
+
+// Refresh returns a new access token
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (string, error) {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.RefreshRoute, nil, map[string]string{
+		"refresh_token": refreshToken,
+	}, &resp)
+
+	return resp.AccessToken, err
+}
+
+// SignOut revokes the refresh token
+func (c *Client) SignOut(ctx context.Context, refreshToken string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.SignOutRoute, nil, map[string]string{
+		"refresh_token": refreshToken,
+	}, nil)
+
+	return err
+}
+
+// Verify returns nil for a valid access token or api key
+func (c *Client) Verify(ctx context.Context, token string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.VerifyRoute, nil, map[string]string{
+		"token": token,
+	}, nil)
+
+	return err
+}
+
+// Introspect describes the access token or the api key, an invalid token is not an error but not active
+func (c *Client) Introspect(ctx context.Context, token string) (Introspection, error) {
+	var resp Introspection
+
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.IntrospectRoute, nil, map[string]string{
+		"token": token,
+	}, &resp)
+
+	return resp, err
+}
+
+// Unlock unlocks the account by the token mailed to the locked user
+func (c *Client) Unlock(ctx context.Context, token string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.UnlockRoute, nil, map[string]string{
+		"token": token,
+	}, nil)
+
+	return err
+}
+
+// SendPasswordReset mails the link to set a new password, gas answers the same for unknown emails
+func (c *Client) SendPasswordReset(ctx context.Context, email string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.PasswordResetRoute, nil, map[string]string{
+		"email": email,
+	}, nil)
+
+	return err
+}
+
+// ConfirmPasswordReset sets the new password by the token of the mailed link
+func (c *Client) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.PasswordResetConfirmRoute, nil, map[string]string{
+		"token":    token,
+		"password": password,
+	}, nil)
+
+	return err
+}
+
+// EnrollTOTP starts the totp enrollment of the user of the client token
+func (c *Client) EnrollTOTP(ctx context.Context) (TOTPEnrollment, error) {
+	var resp TOTPEnrollment
+
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.MFARoute+constant.TOTPEnrollRoute, nil, nil, &resp)
+
+	return resp, err
+}
+
+// ConfirmTOTP enables two-factor authentication by the first code and returns the recovery codes
+func (c *Client) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	var resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	_, err := c.do(ctx, http.MethodPost, constant.AuthRoute+constant.MFARoute+constant.TOTPConfirmRoute, nil, map[string]string{
+		"code": code,
+	}, &resp)
+
+	return resp.RecoveryCodes, err
+}
+
+// signIn reads the access token from the body and the refresh token from the cookie gas sets
+func (c *Client) signIn(ctx context.Context, path string, in interface{}) (Tokens, error) {
+	var body signInResponse
+
+	resp, err := c.do(ctx, http.MethodPost, path, nil, in, &body)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if body.MFARequired {
+		return Tokens{MFAToken: body.MFAToken, MFAMethod: body.MFAMethod}, nil
+	}
+
+	tokens := Tokens{AccessToken: body.AccessToken}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == constant.RefreshTokenCookie {
+			tokens.RefreshToken = cookie.Value
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,80 @@
+package gas
+
+import (
+	"context"
+	"time"
+)
+
+// Claims describe the caller, the same for access tokens and api keys
+type Claims struct {
+	UserID string
+	Email  string
+	Roles  []string
+	// APIKeyID and Scopes are set for api keys only
+	APIKeyID  string
+	Scopes    []string
+	ExpiresAt time.Time
+	UserInfo  map[string]interface{}
+}
+
+func (c *Claims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+// HasScope tells if the api key has the scope, access tokens are not limited by scopes
+func (c *Claims) HasScope(scope string) bool {
+	return c.APIKeyID == "" || contains(c.Scopes, scope)
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims the middleware put in the request context
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// ContextWithClaims is useful for tests of the handlers behind the middleware
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// claimsFromUserInfo reads the fields gas puts in the user info of the token
+func claimsFromUserInfo(userInfo map[string]interface{}, expiresAt time.Time) *Claims {
+	id, _ := userInfo["_id"].(string)
+	email, _ := userInfo["email"].(string)
+	apiKeyID, _ := userInfo["api_key_id"].(string)
+
+	return &Claims{
+		UserID:    id,
+		Email:     email,
+		Roles:     stringsOf(userInfo["roles"]),
+		APIKeyID:  apiKeyID,
+		Scopes:    stringsOf(userInfo["scopes"]),
+		ExpiresAt: expiresAt,
+		UserInfo:  userInfo,
+	}
+}
+
+func stringsOf(v interface{}) []string {
+	values, _ := v.([]interface{})
+
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
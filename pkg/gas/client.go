@@ -0,0 +1,139 @@
+// Package gas is the client of the gas authorization service: a typed client of the http api
+// and a middleware that verifies access tokens and api keys.
+package gas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const statusError = "Error"
+
+// Client calls the gas http api, the zero value is not usable, use NewClient
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+}
+
+// NewClient returns a client of the gas at baseURL, e.g. http://localhost:2023, httpClient may be nil.
+// The client keeps cookies, the passwordless sign in needs the cookie set by the send request.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		jar, _ := cookiejar.New(nil)
+		httpClient = &http.Client{Jar: jar, Timeout: 10 * time.Second}
+	}
+
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    httpClient,
+	}
+}
+
+// WithToken returns a copy of the client that sends the access token or the api key
+// with every request, it is needed by the api keys and the admin methods
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = token
+
+	return &clone
+}
+
+// Error is returned when gas answers with an error
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gas: %d %s", e.StatusCode, e.Message)
+}
+
+type errorResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// do sends in as json and decodes the answer to out, in and out may be nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) (*http.Response, error) {
+	var body io.Reader
+
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// gas answers most errors with 200 and the error status in the body
+	var e errorResponse
+	if len(data) > 0 && json.Unmarshal(data, &e) == nil && e.Status == statusError {
+		return nil, &Error{StatusCode: resp.StatusCode, Message: e.Error}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// route fills the {param} segments of a route pattern in order
+func route(pattern string, params ...string) string {
+	for _, p := range params {
+		start := strings.Index(pattern, "{")
+		end := strings.Index(pattern, "}")
+		if start < 0 || end < start {
+			break
+		}
+
+		pattern = pattern[:start] + url.PathEscape(p) + pattern[end+1:]
+	}
+
+	return pattern
+}
@@ -0,0 +1,63 @@
+package gas
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Middleware authenticates the request by the bearer token and puts the claims in the request context,
+// it is a net/http middleware and can be mounted by chi Router.Use
+func Middleware(v *Verifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || strings.TrimSpace(token) == "" {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+
+				return
+			}
+
+			claims, err := v.Verify(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// RequireRole allows the users with one of the roles, it must be mounted after Middleware
+func RequireRole(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+
+				return
+			}
+
+			for _, role := range roles {
+				if claims.HasRole(role) {
+					next.ServeHTTP(w, r)
+
+					return
+				}
+			}
+
+			writeError(w, http.StatusForbidden, "access denied")
+		})
+	}
+}
+
+// writeError answers in the format of gas
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(errorResponse{Status: statusError, Error: msg})
+}
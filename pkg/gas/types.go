@@ -0,0 +1,164 @@
+package gas
+
+import "time"
+
+// Tokens are returned by sign in, either the access and refresh pair or the mfa token
+// when the user has two-factor authentication enabled
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	MFAToken     string
+	// MFAMethod is the second factor of the user: totp, completed by VerifyMFA, or webauthn,
+	// completed by the passkey in the browser
+	MFAMethod string
+}
+
+// MFARequired tells that the sign in has to be completed with the second factor
+func (t Tokens) MFARequired() bool {
+	return t.MFAToken != ""
+}
+
+type User struct {
+	ID                    string                 `json:"id"`
+	Email                 string                 `json:"email"`
+	UserInfo              map[string]interface{} `json:"user_info,omitempty"`
+	Roles                 []string               `json:"roles,omitempty"`
+	Verified              bool                   `json:"verified"`
+	Locked                bool                   `json:"locked"`
+	PasswordResetRequired bool                   `json:"password_reset_required"`
+	MFAEnabled            bool                   `json:"mfa_enabled"`
+	MFAMethod             string                 `json:"mfa_method,omitempty"`
+	Identities            []Identity             `json:"identities,omitempty"`
+	CreatedAt             time.Time              `json:"created_at"`
+}
+
+// Identity is an account at an external identity provider linked to the user
+type Identity struct {
+	Provider string    `json:"provider"`
+	Subject  string    `json:"subject"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// UserFilter narrows the users list, the zero values are not applied
+type UserFilter struct {
+	EmailPrefix   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Verified      *bool
+	Locked        *bool
+	Offset        int64
+	Limit         int64
+}
+
+type ServiceAccount struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"`
+	OwnerType  string     `json:"owner_type"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey describes the key to create, ExpiresAt may be nil for a key that does not expire
+type NewAPIKey struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey holds the key itself, gas shows it only once
+type CreatedAPIKey struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+// TOTPEnrollment is shown to the user to add gas to an authenticator app
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// QRCode is a data url of the png image
+	QRCode string `json:"qr_code"`
+}
+
+// Introspection describes a token, the other fields are set only for an active token
+type Introspection struct {
+	Active   bool                   `json:"active"`
+	UserID   string                 `json:"user_id,omitempty"`
+	Email    string                 `json:"email,omitempty"`
+	Roles    []string               `json:"roles,omitempty"`
+	APIKeyID string                 `json:"api_key_id,omitempty"`
+	Scopes   []string               `json:"scopes,omitempty"`
+	Expires  *time.Time             `json:"expires_at,omitempty"`
+	UserInfo map[string]interface{} `json:"user_info,omitempty"`
+}
+
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Events      []string  `json:"events"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID         string        `json:"id"`
+	WebhookID  string        `json:"webhook_id"`
+	EventID    string        `json:"event_id"`
+	EventType  string        `json:"event_type"`
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Succeeded  bool          `json:"succeeded"`
+	Duration   time.Duration `json:"duration"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type AuditEntry struct {
+	Seq           int64     `json:"seq"`
+	Time          time.Time `json:"time"`
+	Action        string    `json:"action"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	ActorID       string    `json:"actor_id,omitempty"`
+	ActorAPIKeyID string    `json:"actor_api_key_id,omitempty"`
+	Target        string    `json:"target,omitempty"`
+	IP            string    `json:"ip,omitempty"`
+	UserAgent     string    `json:"user_agent,omitempty"`
+	RequestID     string    `json:"request_id,omitempty"`
+	PrevHash      string    `json:"prev_hash"`
+	Hash          string    `json:"hash"`
+}
+
+// AuditFilter narrows the audit log, the zero values are not applied. The page goes back from Before,
+// or forward from After when it is set
+type AuditFilter struct {
+	Action  string
+	ActorID string
+	Target  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	After   int64
+	Before  int64
+	Limit   int64
+}
+
+// WebhookEvent is the payload gas posts to webhooks
+type WebhookEvent struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
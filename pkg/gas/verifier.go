@@ -0,0 +1,174 @@
+package gas
+
+import (
+	"context"
+	"errors"
+	"github.com/degeboman/gas/constant"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshInterval is how often the verifier fetches the key set in the background
+	DefaultRefreshInterval = 5 * time.Minute
+	// minRefetchInterval limits the fetches caused by tokens with unknown key ids
+	minRefetchInterval = time.Minute
+	leeway             = 30 * time.Second
+)
+
+var ErrInvalidToken = errors.New("gas: token is not valid")
+
+// Verifier checks access tokens locally by the key set gas publishes at /.well-known/jwks.json
+// and asks gas by introspection about the tokens it can't check: api keys and tokens signed
+// with the shared key. Local checks don't see signed out sessions until the access token expires.
+type Verifier struct {
+	client *Client
+
+	mu        sync.RWMutex
+	keys      jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// NewVerifier fetches the key set every refreshInterval until ctx is done, DefaultRefreshInterval when it is 0
+func NewVerifier(ctx context.Context, client *Client, refreshInterval time.Duration) *Verifier {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+
+	v := &Verifier{client: client}
+
+	go v.refresh(ctx, refreshInterval)
+
+	return v
+}
+
+// Verify returns the claims of a valid access token or api key
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	if strings.HasPrefix(token, constant.APIKeyPrefix) {
+		return v.introspect(ctx, token)
+	}
+
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	header := parsed.Headers[0]
+
+	key, ok := v.key(header.KeyID, header.Algorithm)
+	if !ok && header.KeyID != "" && v.refetch(ctx) {
+		key, ok = v.key(header.KeyID, header.Algorithm)
+	}
+
+	if !ok {
+		return v.introspect(ctx, token)
+	}
+
+	var claims struct {
+		jwt.Claims
+		UserInfo map[string]interface{} `json:"UserInfo"`
+	}
+
+	if err := parsed.Claims(key.Key, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{Time: time.Now()}, leeway); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var expiresAt time.Time
+	if claims.Expiry != nil {
+		expiresAt = claims.Expiry.Time()
+	}
+
+	return claimsFromUserInfo(claims.UserInfo, expiresAt), nil
+}
+
+// key finds the public key by id, the algorithm must be the one the key is published with
+func (v *Verifier) key(kid, alg string) (jose.JSONWebKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, k := range v.keys.Key(kid) {
+		if k.Algorithm == alg && k.Use == "sig" {
+			return k, true
+		}
+	}
+
+	return jose.JSONWebKey{}, false
+}
+
+func (v *Verifier) introspect(ctx context.Context, token string) (*Claims, error) {
+	info, err := v.client.Introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Active {
+		return nil, ErrInvalidToken
+	}
+
+	var expiresAt time.Time
+	if info.Expires != nil {
+		expiresAt = *info.Expires
+	}
+
+	claims := claimsFromUserInfo(info.UserInfo, expiresAt)
+	claims.UserID = info.UserID
+	claims.Email = info.Email
+	claims.Roles = info.Roles
+	claims.APIKeyID = info.APIKeyID
+	claims.Scopes = info.Scopes
+
+	return claims, nil
+}
+
+func (v *Verifier) refresh(ctx context.Context, interval time.Duration) {
+	_ = v.fetch(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = v.fetch(ctx)
+		}
+	}
+}
+
+// refetch fetches the key set for a token with an unknown key id, e.g. after a key rotation
+func (v *Verifier) refetch(ctx context.Context) bool {
+	v.mu.RLock()
+	recent := time.Since(v.fetchedAt) < minRefetchInterval
+	v.mu.RUnlock()
+
+	if recent {
+		return false
+	}
+
+	return v.fetch(ctx) == nil
+}
+
+func (v *Verifier) fetch(ctx context.Context) error {
+	var keys jose.JSONWebKeySet
+
+	if _, err := v.client.do(ctx, http.MethodGet, constant.JWKSRoute, nil, nil, &keys); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	return nil
+}
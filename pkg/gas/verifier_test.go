@@ -0,0 +1,262 @@
+package gas
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/degeboman/gas/constant"
+	"github.com/degeboman/gas/internal/lib/jwks"
+	"github.com/dgrijalva/jwt-go/v4"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGas serves the key set and answers introspection for a single api key
+type fakeGas struct {
+	keys       atomic.Pointer[jwks.KeySet]
+	jwksCalls  atomic.Int32
+	introspect atomic.Int32
+}
+
+func (f *fakeGas) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case constant.JWKSRoute:
+		f.jwksCalls.Add(1)
+		_ = json.NewEncoder(w).Encode(f.keys.Load().Public())
+	case constant.AuthRoute + constant.IntrospectRoute:
+		f.introspect.Add(1)
+
+		var req struct {
+			Token string `json:"token"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		if req.Token != "gas_0123_secret" {
+			_ = json.NewEncoder(w).Encode(Introspection{Active: false})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(Introspection{
+			Active:   true,
+			UserID:   "sa",
+			Roles:    []string{"deployer"},
+			APIKeyID: "0123",
+			Scopes:   []string{"read"},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newKeySet(t *testing.T) *jwks.KeySet {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := jwks.New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func sign(t *testing.T, keys *jwks.KeySet, expiresAt time.Time) string {
+	token, err := keys.Sign(jwt.MapClaims{
+		"exp": expiresAt.Unix(),
+		"UserInfo": map[string]interface{}{
+			"_id":   "1",
+			"email": "rupychman@mail.ru",
+			"roles": []string{"admin"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestVerifier(t *testing.T) {
+	gas := &fakeGas{}
+	gas.keys.Store(newKeySet(t))
+
+	srv := httptest.NewServer(gas)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v := NewVerifier(ctx, NewClient(srv.URL, nil), time.Hour)
+
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"UserInfo": map[string]interface{}{}}).
+		SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		name   string
+		token  string
+		valid  bool
+		userID string
+	}{
+		{
+			name:   "signed by the key set",
+			token:  sign(t, gas.keys.Load(), time.Now().Add(time.Minute)),
+			valid:  true,
+			userID: "1",
+		},
+		{
+			name:  "expired",
+			token: sign(t, gas.keys.Load(), time.Now().Add(-time.Hour)),
+		},
+		{
+			name:  "signed by another key",
+			token: sign(t, newKeySet(t), time.Now().Add(time.Minute)),
+		},
+		{
+			name:   "api key",
+			token:  "gas_0123_secret",
+			valid:  true,
+			userID: "sa",
+		},
+		{
+			name:  "signed with the shared key",
+			token: hmac,
+		},
+		{
+			name:  "garbage",
+			token: "not a token",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), d.token)
+			if !d.valid {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", claims)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if claims.UserID != d.userID {
+				t.Errorf("Expected %v, got %v", d.userID, claims.UserID)
+			}
+		})
+	}
+
+	if gas.introspect.Load() != 3 {
+		t.Errorf("Expected %v introspections, got %v", 3, gas.introspect.Load())
+	}
+}
+
+func TestVerifierKeyRotation(t *testing.T) {
+	gas := &fakeGas{}
+	gas.keys.Store(newKeySet(t))
+
+	srv := httptest.NewServer(gas)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v := NewVerifier(ctx, NewClient(srv.URL, nil), time.Hour)
+
+	if _, err := v.Verify(context.Background(), sign(t, gas.keys.Load(), time.Now().Add(time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refetch is not limited right after the start when the key set is fetched long enough ago
+	v.mu.Lock()
+	v.fetchedAt = time.Now().Add(-time.Hour)
+	v.mu.Unlock()
+
+	gas.keys.Store(newKeySet(t))
+
+	claims, err := v.Verify(context.Background(), sign(t, gas.keys.Load(), time.Now().Add(time.Minute)))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !claims.HasRole("admin") {
+		t.Errorf("Expected the admin role, got %v", claims.Roles)
+	}
+
+	if gas.introspect.Load() != 0 {
+		t.Errorf("Expected no introspection, got %v", gas.introspect.Load())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gas := &fakeGas{}
+	gas.keys.Store(newKeySet(t))
+
+	srv := httptest.NewServer(gas)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v := NewVerifier(ctx, NewClient(srv.URL, nil), time.Hour)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		_, _ = w.Write([]byte(claims.Email))
+	})
+
+	data := []struct {
+		name  string
+		role  string
+		token string
+		code  int
+	}{
+		{
+			name: "no token",
+			role: "admin",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name:  "role",
+			role:  "admin",
+			token: sign(t, gas.keys.Load(), time.Now().Add(time.Minute)),
+			code:  http.StatusOK,
+		},
+		{
+			name:  "missing role",
+			role:  "ops",
+			token: sign(t, gas.keys.Load(), time.Now().Add(time.Minute)),
+			code:  http.StatusForbidden,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			h := Middleware(v)(RequireRole(d.role)(ok))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if d.token != "" {
+				r.Header.Set("Authorization", "Bearer "+d.token)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != d.code {
+				t.Errorf("Expected %v, got %v", d.code, w.Code)
+			}
+		})
+	}
+}
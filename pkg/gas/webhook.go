@@ -0,0 +1,64 @@
+package gas
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("gas: invalid webhook signature")
+
+// VerifyWebhook checks the X-Gas-Signature header value against the raw body and decodes the event,
+// the signatures older than tolerance are rejected so that a captured request can't be replayed later
+func VerifyWebhook(secret, signature string, body []byte, tolerance time.Duration) (WebhookEvent, error) {
+	var timestamp, v1 string
+
+	for _, part := range strings.Split(signature, ",") {
+		k, v, _ := strings.Cut(part, "=")
+
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			v1 = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || v1 == "" {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	if tolerance > 0 {
+		if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return WebhookEvent{}, ErrInvalidSignature
+		}
+	}
+
+	expected, err := hex.DecodeString(v1)
+	if err != nil {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return WebhookEvent{}, err
+	}
+
+	return event, nil
+}
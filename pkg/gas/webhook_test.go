@@ -0,0 +1,36 @@
+package gas
+
+import (
+	"github.com/degeboman/gas/internal/lib/webhook"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"user.created","data":{"id":"42"}}`)
+
+	data := []struct {
+		name      string
+		secret    string
+		signature string
+		valid     bool
+	}{
+		{"valid", "whsec_secret", webhook.Sign("whsec_secret", time.Now(), body), true},
+		{"other secret", "whsec_other", webhook.Sign("whsec_secret", time.Now(), body), false},
+		{"expired", "whsec_secret", webhook.Sign("whsec_secret", time.Now().Add(-time.Hour), body), false},
+		{"malformed", "whsec_secret", "v1=abc", false},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			event, err := VerifyWebhook(d.secret, d.signature, body, 5*time.Minute)
+			if (err == nil) != d.valid {
+				t.Fatalf("Expected valid %v, got %v", d.valid, err)
+			}
+
+			if d.valid && event.Type != "user.created" {
+				t.Errorf("Expected user.created, got %s", event.Type)
+			}
+		})
+	}
+}